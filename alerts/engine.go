@@ -0,0 +1,528 @@
+// Package alerts проверяет поток решений о доступе по правилам и создаёт
+// оповещения о подозрительных попытках: много отказов подряд, попытки
+// в нерабочее время, проходы в разных корпусах слишком быстро одна за
+// другой и попытки в другой комнате сразу после отказа
+package alerts
+
+import (
+	"access-control-system/clock"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/stream"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventType — тип события потока о новом оповещении
+const EventType = "alert"
+
+const (
+	gcInterval      = time.Minute
+	defaultCooldown = time.Minute
+	// Решений и оповещений в очереди не больше этого: Observe вызывается
+	// из публичной проверки доступа, и поток запросов не должен
+	// расходовать память без предела
+	queueLimit = 100000
+)
+
+// Часовые пояса комнат, уже загруженные из базы tzdata
+var locations sync.Map
+
+var (
+	fired = metrics.Default.NewCounter("alerts_fired_total",
+		"Срабатывания правил оповещений по правилу и важности", "rule", "severity")
+	queued = metrics.Default.NewGauge("alerts_queue_length",
+		"Решения о доступе и оповещения, ожидающие обработки", "queue")
+	dropped = metrics.Default.NewCounter("alerts_queue_dropped_total",
+		"Решения о доступе и оповещения, отброшенные из-за переполнения очереди", "queue")
+)
+
+// Engine применяет правила к решениям о доступе. События обрабатываются
+// по одному, поэтому состояние правил не требует блокировок. Сохранение
+// оповещений выполняется отдельно и не задерживает проверку правил
+type Engine struct {
+	store   *repository.Store
+	clock   clock.Clock
+	timeout time.Duration
+	emit    func(stream.Event)
+	rules   []*ruleState
+
+	events *queue[stream.Event]
+	alerts *queue[models.Alert]
+}
+
+// NewEngine создаёт движок. timeout ограничивает сохранение оповещения,
+// emit публикует событие о новом оповещении
+func NewEngine(store *repository.Store, clk clock.Clock, rules []models.AlertRule, timeout time.Duration, emit func(stream.Event)) *Engine {
+	e := &Engine{
+		store:   store,
+		clock:   clk,
+		timeout: timeout,
+		emit:    emit,
+		events:  newQueue[stream.Event](queueLimit, queued.With("events"), dropped.With("events")),
+		alerts:  newQueue[models.Alert](queueLimit, queued.With("alerts"), dropped.With("alerts")),
+	}
+	for _, r := range rules {
+		e.rules = append(e.rules, newRuleState(r))
+	}
+	return e
+}
+
+// Observe передаёт решение о доступе правилам. Не блокирует; решения,
+// которые не подходят ни одному правилу, в очередь не ставятся, а сверх
+// queueLimit отбрасываются и учитываются в alerts_queue_dropped_total.
+// Безопасен для nil-движка
+func (e *Engine) Observe(ev stream.Event) {
+	if e == nil || ev.Kind != stream.KindAccess {
+		return
+	}
+	for _, rs := range e.rules {
+		if rs.relevant(ev) {
+			e.events.push(ev)
+			return
+		}
+	}
+}
+
+// Run обрабатывает события до отмены ctx. Решения, поставленные в очередь
+// до остановки, проверяются, а оповещения по ним сохраняются
+func (e *Engine) Run(ctx context.Context) {
+	if len(e.rules) == 0 {
+		<-ctx.Done()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.runFire(stop)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	ticker := time.NewTicker(gcInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, ev := range e.events.take() {
+				e.Process(ev)
+			}
+			return
+		case <-e.events.ready:
+			for _, ev := range e.events.take() {
+				e.Process(ev)
+			}
+		case <-ticker.C:
+			now := e.clock.Now()
+			for _, rs := range e.rules {
+				rs.gc(now)
+			}
+		}
+	}
+}
+
+// runFire сохраняет и публикует оповещения, пока не закрыт stop.
+// Оповещения, созданные до остановки, сохраняются
+func (e *Engine) runFire(stop <-chan struct{}) {
+	for {
+		select {
+		case <-e.alerts.ready:
+			for _, alert := range e.alerts.take() {
+				e.fire(context.Background(), alert)
+			}
+		case <-stop:
+			for _, alert := range e.alerts.take() {
+				e.fire(context.Background(), alert)
+			}
+			return
+		}
+	}
+}
+
+// Process применяет правила к одному решению о доступе и ставит
+// оповещения в очередь на сохранение
+func (e *Engine) Process(ev stream.Event) {
+	if ev.Kind != stream.KindAccess {
+		return
+	}
+	for _, rs := range e.rules {
+		if hit := rs.observe(ev); hit != nil {
+			if alert := e.newAlert(rs.rule, ev, hit); !e.alerts.push(alert) {
+				log.Printf("Очередь оповещений переполнена, оповещение %s потеряно: %s", alert.Rule, alert.Message)
+			}
+		}
+	}
+}
+
+func (e *Engine) newAlert(rule models.AlertRule, ev stream.Event, hit *hit) models.Alert {
+	alert := models.Alert{
+		ID:        primitive.NewObjectID(),
+		Rule:      rule.Name,
+		RuleType:  rule.Type,
+		Severity:  rule.Severity,
+		Message:   hit.message,
+		Room:      ev.Room,
+		Building:  ev.Building,
+		EventIDs:  hit.eventIDs,
+		Status:    models.AlertOpen,
+		CreatedAt: e.clock.Now(),
+	}
+	if id, err := primitive.ObjectIDFromHex(ev.UserID); err == nil && hit.withUser {
+		alert.UserID = id
+	}
+	if !hit.withRoom {
+		alert.Room = ""
+	}
+	return alert
+}
+
+func (e *Engine) fire(ctx context.Context, alert models.Alert) {
+	insertCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	if err := e.store.Alerts.Insert(insertCtx, &alert); err != nil {
+		// Подписчики потока и webhook всё равно получат оповещение
+		log.Printf("Ошибка сохранения оповещения %s: %v", alert.Rule, err)
+	}
+	fired.With(alert.Rule, string(alert.Severity)).Inc()
+	log.Printf("Оповещение %s (%s): %s", alert.Rule, alert.Severity, alert.Message)
+
+	if e.emit != nil {
+		e.emit(AlertEvent(alert, EventType))
+	}
+}
+
+// AlertEvent — событие потока об оповещении
+func AlertEvent(a models.Alert, eventType string) stream.Event {
+	e := stream.Event{
+		Kind:     stream.KindAlert,
+		Type:     eventType,
+		Message:  a.Message,
+		Room:     a.Room,
+		Building: a.Building,
+		AlertID:  a.ID.Hex(),
+		Severity: string(a.Severity),
+		Rule:     a.Rule,
+	}
+	if !a.UserID.IsZero() {
+		e.UserID = a.UserID.Hex()
+	}
+	return e
+}
+
+// hit — срабатывание правила на событии
+type hit struct {
+	message  string
+	eventIDs []uint64
+	withUser bool
+	withRoom bool
+}
+
+// ruleState — правило и накопленные по нему события
+type ruleState struct {
+	rule     models.AlertRule
+	cooldown time.Duration
+	// from и to — границы нерабочего времени в минутах от полуночи
+	from, to int
+
+	windows map[string][]stream.Event
+	// last — последнее событие пользователя: проход для impossible_travel,
+	// отказ для access_after_denial
+	last  map[string]stream.Event
+	fired map[string]time.Time
+}
+
+func newRuleState(r models.AlertRule) *ruleState {
+	rs := &ruleState{
+		rule:     r,
+		cooldown: r.Cooldown,
+		windows:  map[string][]stream.Event{},
+		last:     map[string]stream.Event{},
+		fired:    map[string]time.Time{},
+	}
+	if rs.cooldown <= 0 {
+		rs.cooldown = r.Window
+	}
+	if rs.cooldown <= 0 {
+		rs.cooldown = defaultCooldown
+	}
+	rs.from, rs.to = minuteOfDay(r.From), minuteOfDay(r.To)
+	return rs
+}
+
+func minuteOfDay(hhmm string) int {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+// matches — подходит ли событие под outcome и event_types правила
+func (rs *ruleState) matches(ev stream.Event) bool {
+	if len(rs.rule.EventTypes) > 0 {
+		found := false
+		for _, t := range rs.rule.EventTypes {
+			if t == ev.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	granted := ev.Granted != nil && *ev.Granted
+	switch rs.rule.Outcome {
+	case models.OutcomeGranted:
+		return granted
+	case models.OutcomeDenied:
+		return !granted
+	}
+	return true
+}
+
+// relevant — может ли событие повлиять на правило. Проверяет только
+// настройки правила, а не его состояние, поэтому вызывается вне Run
+func (rs *ruleState) relevant(ev stream.Event) bool {
+	switch rs.rule.Type {
+	case models.RuleAccessAfterDenial:
+		// Отказы запоминаются при любых outcome и event_types
+		return ev.UserID != ""
+	case models.RuleImpossibleTravel:
+		return ev.UserID != "" && ev.Building != "" && rs.matches(ev)
+	case models.RuleThreshold, models.RuleOutOfHours:
+		return rs.groupKey(ev) != "" && rs.matches(ev)
+	}
+	return false
+}
+
+// groupKey — ключ группировки событий. Пустой — событие не учитывается
+func (rs *ruleState) groupKey(ev stream.Event) string {
+	switch rs.rule.GroupBy {
+	case models.GroupByRoom:
+		return ev.Room
+	case models.GroupByBuilding:
+		return ev.Building
+	}
+	return ev.UserID
+}
+
+// cooling проверяет паузу между оповещениями по ключу и, если её нет,
+// отмечает новое срабатывание
+func (rs *ruleState) cooling(key string, at time.Time) bool {
+	if last, ok := rs.fired[key]; ok && at.Sub(last) < rs.cooldown {
+		return true
+	}
+	rs.fired[key] = at
+	return false
+}
+
+func (rs *ruleState) observe(ev stream.Event) *hit {
+	switch rs.rule.Type {
+	case models.RuleThreshold:
+		return rs.threshold(ev)
+	case models.RuleOutOfHours:
+		return rs.outOfHours(ev)
+	case models.RuleImpossibleTravel:
+		return rs.impossibleTravel(ev)
+	case models.RuleAccessAfterDenial:
+		return rs.accessAfterDenial(ev)
+	}
+	return nil
+}
+
+// push добавляет событие в окно ключа и отбрасывает вышедшие из окна
+func (rs *ruleState) push(key string, ev stream.Event) []stream.Event {
+	window := append(rs.windows[key], ev)
+	cutoff := ev.Timestamp.Add(-rs.rule.Window)
+	i := 0
+	for i < len(window)-1 && !window[i].Timestamp.After(cutoff) {
+		i++
+	}
+	window = window[i:]
+	rs.windows[key] = window
+	return window
+}
+
+func (rs *ruleState) threshold(ev stream.Event) *hit {
+	key := rs.groupKey(ev)
+	if key == "" || !rs.matches(ev) {
+		return nil
+	}
+	window := rs.push(key, ev)
+	if len(window) < rs.rule.Count || rs.cooling(key, ev.Timestamp) {
+		return nil
+	}
+	return &hit{
+		message: fmt.Sprintf("%d попыток доступа (%s) за %s: %s",
+			len(window), outcomeLabel(rs.rule.Outcome), rs.rule.Window, groupLabel(rs.rule.GroupBy, key)),
+		eventIDs: eventIDs(window),
+		withUser: rs.rule.GroupBy == models.GroupByUser,
+		withRoom: rs.rule.GroupBy == models.GroupByRoom,
+	}
+}
+
+// inHours — попадает ли местное время события в интервал [from, to).
+// Интервал может переходить через полночь
+func (rs *ruleState) inHours(ev stream.Event) bool {
+	local := ev.Timestamp.In(location(ev.Timezone))
+	m := local.Hour()*60 + local.Minute()
+	if rs.from < rs.to {
+		return m >= rs.from && m < rs.to
+	}
+	return m >= rs.from || m < rs.to
+}
+
+func location(name string) *time.Location {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+func (rs *ruleState) outOfHours(ev stream.Event) *hit {
+	key := rs.groupKey(ev)
+	if key == "" || !rs.matches(ev) || !rs.inHours(ev) {
+		return nil
+	}
+	window := []stream.Event{ev}
+	if rs.rule.Window > 0 {
+		window = rs.push(key, ev)
+	}
+	floors := map[string]bool{}
+	for _, w := range window {
+		if w.Floor != nil {
+			floors[w.Building+"/"+fmt.Sprint(*w.Floor)] = true
+		}
+	}
+	if len(window) < rs.rule.Count || len(floors) < rs.rule.MinFloors || rs.cooling(key, ev.Timestamp) {
+		return nil
+	}
+
+	message := fmt.Sprintf("Попытки доступа в нерабочее время (%s–%s): %d", rs.rule.From, rs.rule.To, len(window))
+	if rs.rule.Window > 0 {
+		message += " за " + rs.rule.Window.String()
+	}
+	if len(floors) > 1 {
+		message += fmt.Sprintf(" на %d этажах", len(floors))
+	}
+	return &hit{
+		message:  message + ", " + groupLabel(rs.rule.GroupBy, key),
+		eventIDs: eventIDs(window),
+		withUser: rs.rule.GroupBy == "" || rs.rule.GroupBy == models.GroupByUser,
+		withRoom: len(window) == 1 || rs.rule.GroupBy == models.GroupByRoom,
+	}
+}
+
+func (rs *ruleState) impossibleTravel(ev stream.Event) *hit {
+	if ev.UserID == "" || ev.Building == "" || !rs.matches(ev) {
+		return nil
+	}
+	prev, ok := rs.last[ev.UserID]
+	rs.last[ev.UserID] = ev
+	if !ok || prev.Building == ev.Building {
+		return nil
+	}
+	elapsed := ev.Timestamp.Sub(prev.Timestamp)
+	if elapsed < 0 || elapsed >= rs.rule.MinTravel || rs.cooling(ev.UserID, ev.Timestamp) {
+		return nil
+	}
+	return &hit{
+		message: fmt.Sprintf("Пользователь %s: попытка в корпусе %s (комната %s) через %s после корпуса %s (комната %s)",
+			ev.UserID, ev.Building, ev.Room, elapsed.Round(time.Second), prev.Building, prev.Room),
+		eventIDs: []uint64{prev.ID, ev.ID},
+		withUser: true,
+		withRoom: true,
+	}
+}
+
+// accessAfterDenial запоминает каждый отказ пользователю. Outcome
+// и event_types правила относятся к следующей попытке
+func (rs *ruleState) accessAfterDenial(ev stream.Event) *hit {
+	if ev.UserID == "" {
+		return nil
+	}
+	denial, ok := rs.last[ev.UserID]
+	if ev.Granted == nil || !*ev.Granted {
+		rs.last[ev.UserID] = ev
+	}
+	if !ok || denial.Room == ev.Room || !rs.matches(ev) {
+		return nil
+	}
+	elapsed := ev.Timestamp.Sub(denial.Timestamp)
+	if elapsed < 0 || elapsed > rs.rule.Window || rs.cooling(ev.UserID, ev.Timestamp) {
+		return nil
+	}
+	return &hit{
+		message: fmt.Sprintf("Пользователь %s: попытка доступа в комнату %s через %s после отказа в комнате %s (%s)",
+			ev.UserID, ev.Room, elapsed.Round(time.Second), denial.Room, denial.Type),
+		eventIDs: []uint64{denial.ID, ev.ID},
+		withUser: true,
+		withRoom: true,
+	}
+}
+
+// gc удаляет состояние, которое уже не может привести к срабатыванию
+func (rs *ruleState) gc(now time.Time) {
+	for key, window := range rs.windows {
+		if now.Sub(window[len(window)-1].Timestamp) > rs.rule.Window {
+			delete(rs.windows, key)
+		}
+	}
+	horizon := rs.rule.Window
+	if rs.rule.Type == models.RuleImpossibleTravel {
+		horizon = rs.rule.MinTravel
+	}
+	for key, ev := range rs.last {
+		if now.Sub(ev.Timestamp) > horizon {
+			delete(rs.last, key)
+		}
+	}
+	for key, at := range rs.fired {
+		if now.Sub(at) >= rs.cooldown {
+			delete(rs.fired, key)
+		}
+	}
+}
+
+func eventIDs(events []stream.Event) []uint64 {
+	ids := make([]uint64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func outcomeLabel(outcome string) string {
+	switch outcome {
+	case models.OutcomeGranted:
+		return "разрешено"
+	case models.OutcomeDenied:
+		return "отказ"
+	}
+	return "любые"
+}
+
+func groupLabel(groupBy, key string) string {
+	switch groupBy {
+	case models.GroupByRoom:
+		return "комната " + key
+	case models.GroupByBuilding:
+		return "корпус " + key
+	}
+	return "пользователь " + key
+}
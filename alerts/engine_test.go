@@ -0,0 +1,115 @@
+package alerts
+
+import (
+	"access-control-system/clock"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/stream"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Всплеск решений больше любого буфера брокера не теряется: каждое
+// решение проверяется правилами, каждое оповещение сохраняется
+func TestEngineObservesEveryDecision(t *testing.T) {
+	const users = 2000
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	store := repository.NewMemoryStore()
+	var emitted int
+	e := NewEngine(store, clock.NewManual(now), []models.AlertRule{{
+		Name: "denials", Type: models.RuleThreshold, Severity: models.SeverityHigh,
+		Outcome: models.OutcomeDenied, GroupBy: models.GroupByUser, Count: 3, Window: time.Minute,
+	}}, time.Second, func(stream.Event) { emitted++ })
+
+	denied := false
+	for i := 0; i < users; i++ {
+		userID := primitive.NewObjectID().Hex()
+		for j := 0; j < 3; j++ {
+			e.Observe(stream.Event{
+				ID: uint64(i*3 + j + 1), Kind: stream.KindAccess, Type: "unauthorized_time_access",
+				UserID: userID, Room: "101", Granted: &denied, Timestamp: now.Add(time.Duration(j) * time.Second),
+			})
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		alerts, err := store.Alerts.List(context.Background(), repository.AlertFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) == users {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("сохранено %d оповещений из %d", len(alerts), users)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if emitted != users {
+		t.Fatalf("опубликовано %d оповещений из %d", emitted, users)
+	}
+}
+
+// Решения, поставленные в очередь до остановки, проверяются, а оповещения
+// по ним сохраняются
+func TestEngineDrainsOnStop(t *testing.T) {
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	store := repository.NewMemoryStore()
+	e := NewEngine(store, clock.NewManual(now), []models.AlertRule{{
+		Name: "denials", Type: models.RuleThreshold, Severity: models.SeverityHigh,
+		Outcome: models.OutcomeDenied, GroupBy: models.GroupByUser, Count: 2, Window: time.Minute,
+	}}, time.Second, nil)
+
+	denied, granted := false, true
+	userID := primitive.NewObjectID().Hex()
+	for i, g := range []*bool{&granted, &denied, &denied} {
+		e.Observe(stream.Event{
+			ID: uint64(i + 1), Kind: stream.KindAccess, Type: "unauthorized_time_access",
+			UserID: userID, Room: "101", Granted: g, Timestamp: now.Add(time.Duration(i) * time.Second),
+		})
+	}
+	// Разрешённый проход не подходит правилу и в очередь не попадает
+	if n := len(e.events.items); n != 2 {
+		t.Fatalf("в очереди %d решений, ожидалось 2", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Run(ctx)
+
+	alerts, err := store.Alerts.List(context.Background(), repository.AlertFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("сохранено %d оповещений, ожидалось 1", len(alerts))
+	}
+}
+
+func TestQueueBounded(t *testing.T) {
+	q := newQueue[int](2, queued.With("test"), dropped.With("test"))
+	for i := 0; i < 3; i++ {
+		if ok := q.push(i); ok != (i < 2) {
+			t.Fatalf("push(%d) = %v", i, ok)
+		}
+	}
+	if items := q.take(); len(items) != 2 || items[0] != 0 || items[1] != 1 {
+		t.Fatalf("take = %v", items)
+	}
+	if !q.push(3) {
+		t.Fatal("после take очередь не освободилась")
+	}
+}
@@ -0,0 +1,50 @@
+package alerts
+
+import (
+	"access-control-system/metrics"
+	"sync"
+)
+
+// queue — ограниченная очередь: push никогда не блокирует, а элементы
+// сверх limit отбрасывает и учитывает в dropped. Получатель ждёт ready
+// и забирает всё накопленное
+type queue[T any] struct {
+	mu      sync.Mutex
+	items   []T
+	limit   int
+	ready   chan struct{}
+	length  *metrics.Gauge
+	dropped *metrics.Counter
+}
+
+func newQueue[T any](limit int, length *metrics.Gauge, dropped *metrics.Counter) *queue[T] {
+	return &queue[T]{limit: limit, ready: make(chan struct{}, 1), length: length, dropped: dropped}
+}
+
+// push добавляет элемент и сообщает, поместился ли он
+func (q *queue[T]) push(item T) bool {
+	q.mu.Lock()
+	if len(q.items) >= q.limit {
+		q.mu.Unlock()
+		q.dropped.Inc()
+		return false
+	}
+	q.items = append(q.items, item)
+	q.length.Set(float64(len(q.items)))
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// take возвращает накопленные элементы в порядке поступления и очищает очередь
+func (q *queue[T]) take() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	q.length.Set(0)
+	return items
+}
@@ -0,0 +1,509 @@
+// Package analytics считает использование комнат для планирования
+// помещений: часы занятий по расписанию против фактических проходов
+// по комнатам, этажам и корпусам, часы пик, неиспользуемые комнаты и
+// пересекающиеся занятия. Проходы считаются агрегацией в базе, готовые
+// отчёты кэшируются
+package analytics
+
+import (
+	"access-control-system/clock"
+	"access-control-system/eventlog"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Группировка отчёта
+const (
+	GroupRoom     = "room"
+	GroupFloor    = "floor"
+	GroupBuilding = "building"
+)
+
+const (
+	// Отчётов в кэше, после которого кэш очищается целиком
+	maxCached = 100
+	// Часов пик в сводке отчёта
+	topPeaks = 10
+)
+
+var cacheRequests = metrics.Default.NewCounter("analytics_cache_total",
+	"Запросы отчёта об использовании комнат: hit — из кэша, miss — построен заново", "result")
+
+// Query — период, разбиение и фильтры отчёта
+type Query struct {
+	From, To time.Time
+	// Unit — интервал: repository.UsageHour, UsageDay, UsageWeek, UsageMonth
+	Unit    string
+	GroupBy string
+	// Фильтры комнат, пустые не ограничивают
+	Building string
+	Floor    *int
+	Room     string
+	// Location — часовой пояс интервалов и часов пик
+	Location *time.Location
+	// RoomLocation — часовой пояс комнаты, по нему читается расписание
+	RoomLocation func(models.Room) *time.Location
+}
+
+func (q Query) key() string {
+	floor := ""
+	if q.Floor != nil {
+		floor = strconv.Itoa(*q.Floor)
+	}
+	return fmt.Sprintf("%d|%d|%s|%s|%s|%s|%s|%s", q.From.UnixNano(), q.To.UnixNano(), q.Unit, q.GroupBy,
+		q.Building, floor, q.Room, q.Location)
+}
+
+// Report — использование комнат за период
+type Report struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Unit        string    `json:"unit"`
+	GroupBy     string    `json:"group_by"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Cached      bool      `json:"cached"`
+	// Groups — итоги по комнатам, этажам или корпусам за весь период
+	Groups []Group `json:"groups"`
+	// Buckets — интервалы, в которых были занятия или проходы
+	Buckets []Bucket `json:"buckets"`
+	// Peaks — дни недели и часы с наибольшим числом проходов
+	Peaks      []Peak      `json:"peaks"`
+	NeverUsed  []RoomUsage `json:"never_used"`
+	Overbooked []Overlap   `json:"overbooked"`
+}
+
+// Group — итоги группы. EntriesPerHour — проходов на час занятий,
+// 0 — занятий не было
+type Group struct {
+	Group          string  `json:"group"`
+	Building       string  `json:"building,omitempty"`
+	Floor          *int    `json:"floor,omitempty"`
+	Rooms          int     `json:"rooms"`
+	ScheduledHours float64 `json:"scheduled_hours"`
+	Entries        int64   `json:"entries"`
+	EntriesPerHour float64 `json:"entries_per_hour"`
+	Peak           *Peak   `json:"peak,omitempty"`
+}
+
+// Bucket — часы занятий и проходы группы за интервал, начинающийся в Start
+type Bucket struct {
+	Group          string    `json:"group"`
+	Start          time.Time `json:"start"`
+	ScheduledHours float64   `json:"scheduled_hours"`
+	Entries        int64     `json:"entries"`
+}
+
+type Peak struct {
+	Group   string `json:"group,omitempty"`
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+	Entries int64  `json:"entries"`
+}
+
+// RoomUsage — комната без единого прохода за период
+type RoomUsage struct {
+	Room           string  `json:"room"`
+	Building       string  `json:"building,omitempty"`
+	Floor          int     `json:"floor"`
+	ScheduledHours float64 `json:"scheduled_hours"`
+}
+
+// Booking — занятие из расписания
+type Booking struct {
+	ScheduleID primitive.ObjectID `json:"schedule_id"`
+	UserID     primitive.ObjectID `json:"user_id"`
+	Name       string             `json:"name"`
+	Subject    string             `json:"subject"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+}
+
+// Overlap — два занятия в одной комнате в один день недели, пересекающиеся
+// по времени
+type Overlap struct {
+	Room    string  `json:"room"`
+	Day     string  `json:"day"`
+	First   Booking `json:"first"`
+	Second  Booking `json:"second"`
+	Minutes int     `json:"minutes"`
+}
+
+type cached struct {
+	report  Report
+	expires time.Time
+}
+
+// Service строит отчёты об использовании комнат и кэширует их на ttl
+type Service struct {
+	store *repository.Store
+	clock clock.Clock
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+func NewService(store *repository.Store, clk clock.Clock, ttl time.Duration) *Service {
+	return &Service{store: store, clock: clk, ttl: ttl, cache: map[string]cached{}}
+}
+
+// Utilization возвращает отчёт из кэша или строит его заново
+func (s *Service) Utilization(ctx context.Context, q Query) (Report, error) {
+	key := q.key()
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	c, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		cacheRequests.With("hit").Inc()
+		c.report.Cached = true
+		return c.report, nil
+	}
+	cacheRequests.With("miss").Inc()
+
+	report, err := s.build(ctx, q)
+	if err != nil || s.ttl <= 0 {
+		return report, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, c := range s.cache {
+		if !now.Before(c.expires) {
+			delete(s.cache, k)
+		}
+	}
+	if len(s.cache) >= maxCached {
+		s.cache = map[string]cached{}
+	}
+	s.cache[key] = cached{report: report, expires: now.Add(s.ttl)}
+	return report, nil
+}
+
+func (s *Service) build(ctx context.Context, q Query) (Report, error) {
+	report := Report{
+		From: q.From, To: q.To, Unit: q.Unit, GroupBy: q.GroupBy, GeneratedAt: s.clock.Now(),
+		Groups: []Group{}, Buckets: []Bucket{}, Peaks: []Peak{}, NeverUsed: []RoomUsage{}, Overbooked: []Overlap{},
+	}
+
+	rooms, err := s.store.Rooms.List(ctx)
+	if err != nil {
+		return report, err
+	}
+	selected := map[string]models.Room{}
+	for _, room := range rooms {
+		if (q.Building == "" || room.Building == q.Building) &&
+			(q.Floor == nil || room.Floor == *q.Floor) &&
+			(q.Room == "" || room.RoomNumber == q.Room) {
+			selected[room.RoomNumber] = room
+		}
+	}
+	schedules, err := s.store.Schedules.List(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	usage := repository.UsageQuery{
+		From: q.From, To: q.To, Unit: q.Unit, Location: q.Location,
+		EventTypes: []string{eventlog.TypeAccessGranted, eventlog.TypeStaleOpen},
+	}
+	online, err := s.store.Logs.Usage(ctx, usage)
+	if err != nil {
+		return report, err
+	}
+	offline, err := s.store.Access.Usage(ctx, usage)
+	if err != nil {
+		return report, err
+	}
+
+	a := newAggregator(q, selected)
+	for _, stats := range []repository.UsageStats{online, offline} {
+		for _, c := range stats.Counts {
+			a.entries(c)
+		}
+		for _, p := range stats.Peaks {
+			a.peak(p)
+		}
+	}
+	for _, sch := range schedules {
+		if room, ok := selected[sch.RoomNumber]; ok {
+			if err := a.schedule(sch, q.RoomLocation(room)); err != nil {
+				log.Printf("Расписание %s пропущено в отчёте об использовании комнат: %v", sch.ID.Hex(), err)
+			}
+		}
+	}
+
+	report.Groups, report.Buckets, report.Peaks, report.NeverUsed = a.result()
+	report.Overbooked = overbooked(schedules, selected)
+	return report, nil
+}
+
+type bucketKey struct {
+	group string
+	start int64
+}
+
+type peakKey struct {
+	group   string
+	weekday time.Weekday
+	hour    int
+}
+
+// aggregator сводит проходы и часы занятий комнат в группы
+type aggregator struct {
+	q         Query
+	rooms     map[string]models.Room
+	groups    map[string]*Group
+	buckets   map[bucketKey]*Bucket
+	peaks     map[peakKey]int64
+	roomHours map[string]float64
+	used      map[string]bool
+}
+
+func newAggregator(q Query, rooms map[string]models.Room) *aggregator {
+	a := &aggregator{
+		q: q, rooms: rooms,
+		groups:    map[string]*Group{},
+		buckets:   map[bucketKey]*Bucket{},
+		peaks:     map[peakKey]int64{},
+		roomHours: map[string]float64{},
+		used:      map[string]bool{},
+	}
+	for _, room := range rooms {
+		a.group(room).Rooms++
+	}
+	return a
+}
+
+func (a *aggregator) group(room models.Room) *Group {
+	g := Group{Group: room.RoomNumber}
+	switch a.q.GroupBy {
+	case GroupFloor:
+		floor := room.Floor
+		g.Group, g.Building, g.Floor = strconv.Itoa(floor), room.Building, &floor
+		if room.Building != "" {
+			g.Group = room.Building + "/" + g.Group
+		}
+	case GroupBuilding:
+		g.Group, g.Building = room.Building, room.Building
+	}
+	if existing, ok := a.groups[g.Group]; ok {
+		return existing
+	}
+	a.groups[g.Group] = &g
+	return &g
+}
+
+func (a *aggregator) bucket(group string, start time.Time) *Bucket {
+	key := bucketKey{group, start.UnixNano()}
+	b := a.buckets[key]
+	if b == nil {
+		b = &Bucket{Group: group, Start: start}
+		a.buckets[key] = b
+	}
+	return b
+}
+
+// entries учитывает проходы в комнату за интервал. Проходы в комнаты,
+// не попавшие в отчёт или удалённые, не учитываются
+func (a *aggregator) entries(c repository.UsageCount) {
+	room, ok := a.rooms[c.Room]
+	if !ok {
+		return
+	}
+	g := a.group(room)
+	g.Entries += c.Entries
+	a.bucket(g.Group, c.Start).Entries += c.Entries
+	a.used[c.Room] = true
+}
+
+func (a *aggregator) peak(p repository.UsagePeak) {
+	if room, ok := a.rooms[p.Room]; ok {
+		a.peaks[peakKey{a.group(room).Group, p.Weekday, p.Hour}] += p.Entries
+	}
+}
+
+// schedule добавляет часы занятий по расписанию sch, разбивая каждое
+// занятие по интервалам отчёта
+func (a *aggregator) schedule(sch models.Schedule, loc *time.Location) error {
+	g := a.group(a.rooms[sch.RoomNumber])
+	first := a.q.From.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(a.q.To); day = day.AddDate(0, 0, 1) {
+		if day.Weekday().String() != sch.Day {
+			continue
+		}
+		start, end, err := sch.Interval(day)
+		if err != nil {
+			return err
+		}
+		if start.Before(a.q.From) {
+			start = a.q.From
+		}
+		if end.After(a.q.To) {
+			end = a.q.To
+		}
+		for t := start; t.Before(end); {
+			bucketStart := repository.UsageStart(t, a.q.Unit, a.q.Location)
+			next := repository.NextUsageStart(bucketStart, a.q.Unit)
+			if next.After(end) {
+				next = end
+			}
+			hours := next.Sub(t).Hours()
+			a.bucket(g.Group, bucketStart).ScheduledHours += hours
+			g.ScheduledHours += hours
+			a.roomHours[sch.RoomNumber] += hours
+			t = next
+		}
+	}
+	return nil
+}
+
+func (a *aggregator) result() (groups []Group, buckets []Bucket, peaks []Peak, neverUsed []RoomUsage) {
+	best := map[string]Peak{}
+	peaks = make([]Peak, 0, len(a.peaks))
+	for k, n := range a.peaks {
+		p := Peak{Group: k.group, Weekday: k.weekday.String(), Hour: k.hour, Entries: n}
+		peaks = append(peaks, p)
+		if b, ok := best[k.group]; !ok || peakBefore(p, b) {
+			best[k.group] = p
+		}
+	}
+	sort.Slice(peaks, func(i, j int) bool { return peakBefore(peaks[i], peaks[j]) })
+	if len(peaks) > topPeaks {
+		peaks = peaks[:topPeaks]
+	}
+
+	groups = make([]Group, 0, len(a.groups))
+	for _, g := range a.groups {
+		g.ScheduledHours = round(g.ScheduledHours)
+		if g.ScheduledHours > 0 {
+			g.EntriesPerHour = round(float64(g.Entries) / g.ScheduledHours)
+		}
+		if p, ok := best[g.Group]; ok {
+			p.Group = ""
+			g.Peak = &p
+		}
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+
+	buckets = make([]Bucket, 0, len(a.buckets))
+	for _, b := range a.buckets {
+		b.ScheduledHours = round(b.ScheduledHours)
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Group != buckets[j].Group {
+			return buckets[i].Group < buckets[j].Group
+		}
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	neverUsed = []RoomUsage{}
+	for number, room := range a.rooms {
+		if !a.used[number] {
+			neverUsed = append(neverUsed, RoomUsage{
+				Room: number, Building: room.Building, Floor: room.Floor, ScheduledHours: round(a.roomHours[number]),
+			})
+		}
+	}
+	sort.Slice(neverUsed, func(i, j int) bool { return neverUsed[i].Room < neverUsed[j].Room })
+	return groups, buckets, peaks, neverUsed
+}
+
+// peakBefore упорядочивает часы пик по убыванию проходов
+func peakBefore(a, b Peak) bool {
+	if a.Entries != b.Entries {
+		return a.Entries > b.Entries
+	}
+	if a.Group != b.Group {
+		return a.Group < b.Group
+	}
+	if a.Weekday != b.Weekday {
+		return a.Weekday < b.Weekday
+	}
+	return a.Hour < b.Hour
+}
+
+// overbooked находит пары занятий в одной комнате в один день недели,
+// пересекающиеся по времени
+func overbooked(schedules []models.Schedule, rooms map[string]models.Room) []Overlap {
+	type slot struct {
+		schedule   models.Schedule
+		start, end time.Time
+	}
+	type dayKey struct{ room, day string }
+	byDay := map[dayKey][]slot{}
+	// Сравниваются только время начала и конца, дата не важна
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, sch := range schedules {
+		if _, ok := rooms[sch.RoomNumber]; !ok {
+			continue
+		}
+		start, end, err := sch.Interval(day)
+		if err != nil {
+			continue
+		}
+		key := dayKey{sch.RoomNumber, sch.Day}
+		byDay[key] = append(byDay[key], slot{sch, start, end})
+	}
+
+	overlaps := []Overlap{}
+	for key, slots := range byDay {
+		sort.Slice(slots, func(i, j int) bool { return slots[i].start.Before(slots[j].start) })
+		for i := range slots {
+			for j := i + 1; j < len(slots) && slots[j].start.Before(slots[i].end); j++ {
+				end := slots[i].end
+				if slots[j].end.Before(end) {
+					end = slots[j].end
+				}
+				overlaps = append(overlaps, Overlap{
+					Room:    key.room,
+					Day:     key.day,
+					First:   booking(slots[i].schedule),
+					Second:  booking(slots[j].schedule),
+					Minutes: int(end.Sub(slots[j].start).Minutes()),
+				})
+			}
+		}
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		a, b := overlaps[i], overlaps[j]
+		if a.Room != b.Room {
+			return a.Room < b.Room
+		}
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		return a.First.Start < b.First.Start
+	})
+	return overlaps
+}
+
+func booking(s models.Schedule) Booking {
+	return Booking{
+		ScheduleID: s.ID,
+		UserID:     s.UserID,
+		Name:       s.FirstName + " " + s.SecondName,
+		Subject:    s.Subject,
+		Start:      s.StartTime,
+		End:        s.EndTime,
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
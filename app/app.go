@@ -0,0 +1,285 @@
+package app
+
+import (
+	"access-control-system/alerts"
+	"access-control-system/analytics"
+	"access-control-system/audit"
+	"access-control-system/bundle"
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/controllers"
+	"access-control-system/eventlog"
+	"access-control-system/metrics"
+	"access-control-system/middleware"
+	"access-control-system/policy"
+	"access-control-system/repository"
+	"access-control-system/retention"
+	"access-control-system/routes"
+	"access-control-system/search"
+	"access-control-system/stream"
+	"access-control-system/webhook"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const indexTimeout = 30 * time.Second
+
+// worker — фоновая задача, работающая до отмены контекста
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// App владеет конфигурацией, хранилищами, маршрутизатором и фоновыми задачами
+// сервиса и отвечает за их корректную остановку
+type App struct {
+	cfg       *config.Config
+	client    *mongo.Client
+	store     *repository.Store
+	events    *eventlog.Writer
+	policy    *policy.Cache
+	stream    *stream.Broker
+	webhooks  *webhook.Dispatcher
+	alerts    *alerts.Engine
+	retention *retention.Pruner
+	search    *search.Service
+	handler   *controllers.Handler
+	router    *gin.Engine
+	server    *http.Server
+
+	workers        []worker
+	workersWG      sync.WaitGroup
+	workersRunning atomic.Int32
+	stopWorkers    context.CancelFunc
+
+	indexesReady atomic.Bool
+}
+
+// New подключается к хранилищам и собирает приложение. Отмена ctx
+// прерывает ожидание MongoDB
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	a := &App{cfg: cfg}
+
+	client, err := config.ConnectDB(ctx, cfg.Mongo)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	a.store = repository.NewStore(a.client, cfg.Mongo.Database)
+
+	photos, err := config.NewPhotoStore(a.client.Database(cfg.Mongo.Database))
+	if err != nil {
+		a.disconnect(context.Background())
+		return nil, fmt.Errorf("хранилище фотографий: %w", err)
+	}
+
+	signer, err := bundle.NewSigner(cfg.Bundles.SigningKey)
+	if err != nil {
+		a.disconnect(context.Background())
+		return nil, fmt.Errorf("ключ подписи офлайн-пакетов: %w", err)
+	}
+	if cfg.Bundles.SigningKey == "" {
+		log.Println("ВНИМАНИЕ: ключ подписи офлайн-пакетов создан на время работы сервиса, задайте BUNDLE_SIGNING_KEY")
+	}
+
+	clk := clock.System()
+	a.policy = policy.New(a.store, clk, cfg.Access.CacheMaxAge, cfg.Access.CachePollInterval)
+	metrics.Default.NewGaugeFunc("policy_cache_age_seconds",
+		"Возраст кэша политик доступа, -1 — кэш не загружен", func() float64 {
+			if age := a.policy.Age(); age >= 0 {
+				return age.Seconds()
+			}
+			return -1
+		})
+
+	a.events = eventlog.NewWriter(a.store.Logs, cfg.Mongo.QueryTimeout)
+	a.stream = stream.NewBroker(0, 0)
+	a.webhooks = webhook.NewDispatcher(a.store, clk, webhook.Config{
+		Timeout:         cfg.Webhooks.Timeout,
+		MaxAttempts:     cfg.Webhooks.MaxAttempts,
+		RetryBackoff:    cfg.Webhooks.RetryBackoff,
+		RetryMaxBackoff: cfg.Webhooks.RetryMaxBackoff,
+		PollInterval:    cfg.Webhooks.PollInterval,
+	})
+	a.alerts = alerts.NewEngine(a.store, clk, cfg.Alerts.Rules, cfg.Mongo.QueryTimeout,
+		func(e stream.Event) { a.webhooks.Notify(a.stream.Publish(e)) })
+	a.retention = retention.NewPruner(a.store.Logs, clk, retention.Config{
+		Default:     cfg.LogRetention(),
+		ByType:      cfg.LogRetentionByType(),
+		ArchiveDir:  cfg.Logs.ArchiveDir,
+		Interval:    cfg.Logs.PruneInterval,
+		BatchSize:   cfg.Logs.BatchSize,
+		RestoreHold: time.Duration(cfg.Logs.RestoreDays) * 24 * time.Hour,
+		Timeout:     cfg.Mongo.QueryTimeout,
+	})
+	a.search = search.NewService(a.store, cfg.Mongo.QueryTimeout)
+	a.handler = controllers.NewHandler(controllers.Deps{
+		Store:     a.store,
+		Photos:    photos,
+		Clock:     clk,
+		Events:    a.events,
+		Policy:    a.policy,
+		Signer:    signer,
+		Stream:    a.stream,
+		Webhooks:  a.webhooks,
+		Alerts:    a.alerts,
+		Audit:     audit.NewTrail(a.store.Audit, clk),
+		Retention: a.retention,
+		Analytics: analytics.NewService(a.store, clk, cfg.Analytics.CacheTTL),
+		Search:    a.search,
+	})
+	a.workers = []worker{
+		{name: "очистка деактивированных пользователей", run: a.handler.StartUserPurge},
+		{name: "кэш политик доступа", run: a.policy.Run},
+		{name: "доставка webhook", run: a.webhooks.Run},
+		{name: "правила оповещений", run: a.alerts.Run},
+		{name: "очистка журнала событий", run: a.retention.Run},
+		{name: "поисковый индекс", run: a.search.Run},
+	}
+
+	a.router = gin.New()
+	a.router.Use(middleware.RequestID(), middleware.Logger(routes.HealthPaths...), gin.Recovery())
+	routes.HealthRoutes(a.router, controllers.NewHealthHandler(a.readinessChecks()...))
+	routes.MetricsRoutes(a.router, metrics.Default)
+	config.SetupCORS(a.router)
+	routes.Register(a.router, a.handler)
+
+	a.server = &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      a.router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
+	// Открытые потоки событий не завершаются сами и задержали бы Shutdown
+	a.server.RegisterOnShutdown(a.stream.Close)
+	return a, nil
+}
+
+// Run запускает фоновые задачи и HTTP-сервер и блокирует до отмены ctx
+// или ошибки сервера, после чего корректно останавливает приложение
+func (a *App) Run(ctx context.Context) error {
+	go a.ensureIndexes()
+	a.startWorkers()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Сервер запущен на %s", a.cfg.Server.Addr)
+		if err := a.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Получен сигнал остановки")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("ошибка HTTP-сервера: %w", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(runErr, a.shutdown(shutdownCtx))
+}
+
+func (a *App) startWorkers() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopWorkers = cancel
+
+	for _, w := range a.workers {
+		a.workersWG.Add(1)
+		go func(w worker) {
+			defer a.workersWG.Done()
+			a.workersRunning.Add(1)
+			defer a.workersRunning.Add(-1)
+
+			log.Printf("Фоновая задача запущена: %s", w.name)
+			w.run(ctx)
+			log.Printf("Фоновая задача остановлена: %s", w.name)
+		}(w)
+	}
+}
+
+// ensureIndexes создаёт индексы в фоне, чтобы сервер начал отвечать на
+// /healthz сразу. До завершения сервис не считается готовым
+func (a *App) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+
+	if err := a.store.EnsureIndexes(ctx); err != nil {
+		log.Println("Не все индексы созданы, проверьте данные на дубликаты:", err)
+	}
+	a.indexesReady.Store(true)
+}
+
+func (a *App) readinessChecks() []controllers.ReadinessCheck {
+	return []controllers.ReadinessCheck{
+		{Name: "mongo", Check: a.store.Ping},
+		{Name: "indexes", Check: func(context.Context) error {
+			if !a.indexesReady.Load() {
+				return errors.New("индексы создаются")
+			}
+			return nil
+		}},
+		{Name: "workers", Check: func(context.Context) error {
+			if running, total := a.workersRunning.Load(), len(a.workers); int(running) < total {
+				return fmt.Errorf("запущено фоновых задач: %d из %d", running, total)
+			}
+			return nil
+		}},
+	}
+}
+
+// shutdown дожидается завершения текущих запросов, останавливает фоновые
+// задачи, записывает события из очереди и отключается от MongoDB
+func (a *App) shutdown(ctx context.Context) error {
+	var errs []error
+
+	log.Println("Ожидаем завершения текущих запросов")
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("остановка HTTP-сервера: %w", err))
+	}
+
+	if a.stopWorkers != nil {
+		a.stopWorkers()
+		done := make(chan struct{})
+		go func() {
+			a.workersWG.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("остановка фоновых задач: %w", ctx.Err()))
+		}
+	}
+
+	if err := a.events.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("запись журнала событий: %w", err))
+	}
+
+	if err := a.disconnect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	log.Println("Сервер остановлен")
+	return errors.Join(errs...)
+}
+
+func (a *App) disconnect(ctx context.Context) error {
+	if err := a.client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("отключение от MongoDB: %w", err)
+	}
+	log.Println("Соединение с MongoDB закрыто")
+	return nil
+}
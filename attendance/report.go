@@ -0,0 +1,368 @@
+// Package attendance сопоставляет разрешённые проходы с занятиями по
+// расписанию: кто пришёл вовремя, кто опоздал, кто не пришёл и кто
+// проходил в комнаты вне своих занятий
+package attendance
+
+import (
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Итог занятия
+const (
+	StatusPresent = "present"
+	StatusLate    = "late"
+	StatusAbsent  = "absent"
+)
+
+// Источник прохода
+const (
+	SourceOnline  = "online"
+	SourceOffline = "offline"
+)
+
+// Options — период и фильтры отчёта
+type Options struct {
+	From, To time.Time
+	// Now — занятия, которые ещё не закончились, в отчёт не входят
+	Now     time.Time
+	UserID  primitive.ObjectID
+	Room    string
+	Subject string
+	// LateAfter и EarlyWindow — см. config.AttendanceConfig
+	LateAfter   time.Duration
+	EarlyWindow time.Duration
+	// Location возвращает часовой пояс комнаты, по нему читается расписание
+	Location func(room string) *time.Location
+	// MaxUnscheduled — сколько самых ранних проходов вне расписания
+	// перечислить в отчёте. Итоги считаются по всем. 0 — без ограничения
+	MaxUnscheduled int
+}
+
+// Slot — одно занятие по расписанию и первый проход на него
+type Slot struct {
+	ScheduleID  primitive.ObjectID `json:"schedule_id"`
+	UserID      primitive.ObjectID `json:"user_id"`
+	Name        string             `json:"name"`
+	Subject     string             `json:"subject"`
+	Room        string             `json:"room"`
+	Week        string             `json:"week"`
+	Start       time.Time          `json:"start"`
+	End         time.Time          `json:"end"`
+	Arrival     *time.Time         `json:"arrival,omitempty"`
+	Source      string             `json:"source,omitempty"`
+	Status      string             `json:"status"`
+	LateMinutes int                `json:"late_minutes,omitempty"`
+}
+
+// Visit — разрешённый проход, не попавший ни в одно занятие
+type Visit struct {
+	UserID primitive.ObjectID `json:"user_id"`
+	Name   string             `json:"name"`
+	Room   string             `json:"room"`
+	Week   string             `json:"week"`
+	Time   time.Time          `json:"time"`
+	Source string             `json:"source"`
+}
+
+// Row — итоги преподавателя по предмету за неделю. Проходы вне
+// расписания учитываются в строке с пустым предметом
+type Row struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	Name        string             `json:"name"`
+	Subject     string             `json:"subject"`
+	Week        string             `json:"week"`
+	Scheduled   int                `json:"scheduled"`
+	Present     int                `json:"present"`
+	Late        int                `json:"late"`
+	Absent      int                `json:"absent"`
+	Unscheduled int                `json:"unscheduled"`
+	LateMinutes int                `json:"late_minutes"`
+}
+
+// Report — отчёт о посещаемости за период
+type Report struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Summary     []Row     `json:"summary"`
+	Slots       []Slot    `json:"slots"`
+	Unscheduled []Visit   `json:"unscheduled"`
+	// UnscheduledTotal — всего проходов вне расписания. Если их больше
+	// Options.MaxUnscheduled, Unscheduled содержит только самые ранние,
+	// а Truncated выставлен
+	UnscheduledTotal int  `json:"unscheduled_total"`
+	Truncated        bool `json:"truncated,omitempty"`
+}
+
+// Build строит отчёт по расписаниям, проходам из журнала событий
+// (access_granted и пропуск по аварийной политике) и разрешённым
+// проходам, загруженным контроллерами дверей. Проходы читаются курсором
+// и не накапливаются: в памяти остаются занятия периода, счётчики проходов
+// вне расписания по преподавателю и неделе и не больше opts.MaxUnscheduled
+// самих таких проходов
+func Build(ctx context.Context, store *repository.Store, opts Options) (Report, error) {
+	report := Report{From: opts.From, To: opts.To, Slots: []Slot{}, Unscheduled: []Visit{}, Summary: []Row{}}
+
+	schedules, err := store.Schedules.List(ctx)
+	if err != nil {
+		return report, err
+	}
+	m := newMatcher(opts)
+	for _, s := range schedules {
+		if (!opts.UserID.IsZero() && s.UserID != opts.UserID) ||
+			(opts.Room != "" && s.RoomNumber != opts.Room) ||
+			(opts.Subject != "" && s.Subject != opts.Subject) {
+			continue
+		}
+		if err := m.addSchedule(s); err != nil {
+			log.Printf("Расписание %s пропущено в отчёте о посещаемости: %v", s.ID.Hex(), err)
+		}
+	}
+
+	// Ранние проходы на занятия в начале периода
+	from := opts.From.Add(-opts.EarlyWindow)
+	for _, eventType := range []string{eventlog.TypeAccessGranted, eventlog.TypeStaleOpen} {
+		filter := repository.LogFilter{EventType: eventType, UserID: opts.UserID, Room: opts.Room, From: from, To: opts.To}
+		err := store.Logs.Each(ctx, filter, func(entry models.Log) error {
+			m.visit(entry.UserID, entry.Room, entry.Timestamp, SourceOnline)
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	filter := repository.AccessLogFilter{
+		UserID: opts.UserID, RoomID: opts.Room, Status: models.AccessStatusGranted, From: from, To: opts.To,
+	}
+	err = store.Access.Each(ctx, filter, func(a models.AccessLog) error {
+		m.visit(a.UserID, a.RoomID, a.AccessTime, SourceOffline)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Slots = m.result()
+	name := func(userID primitive.ObjectID) string {
+		n, ok := m.names[userID]
+		if !ok {
+			if u, err := store.Users.FindByID(ctx, userID); err == nil {
+				n = u.FirstName + " " + u.SecondName
+			}
+			m.names[userID] = n
+		}
+		return n
+	}
+	// С фильтром по предмету проходы вне расписания в отчёт не входят
+	var unscheduled map[visitKey]int
+	if opts.Subject == "" {
+		unscheduled = m.unscheduled
+		report.Unscheduled = m.visits
+		report.UnscheduledTotal = m.total
+		report.Truncated = len(m.visits) < m.total
+	}
+	for i := range report.Unscheduled {
+		report.Unscheduled[i].Name = name(report.Unscheduled[i].UserID)
+	}
+	report.Summary = summarize(report.Slots, unscheduled, name)
+	return report, nil
+}
+
+type slotKey struct {
+	user primitive.ObjectID
+	room string
+}
+
+// visitKey — преподаватель и неделя ISO для счётчика проходов вне расписания
+type visitKey struct {
+	user primitive.ObjectID
+	week string
+}
+
+type matcher struct {
+	opts  Options
+	slots map[slotKey][]*Slot
+	names map[primitive.ObjectID]string
+	// unscheduled — число проходов вне расписания, total — их сумма,
+	// visits — самые ранние из них, не больше opts.MaxUnscheduled
+	unscheduled map[visitKey]int
+	total       int
+	visits      []Visit
+}
+
+func newMatcher(opts Options) *matcher {
+	return &matcher{
+		opts:        opts,
+		slots:       map[slotKey][]*Slot{},
+		names:       map[primitive.ObjectID]string{},
+		unscheduled: map[visitKey]int{},
+		visits:      []Visit{},
+	}
+}
+
+// addSchedule добавляет занятия расписания s, начавшиеся в периоде
+// и закончившиеся к opts.Now
+func (m *matcher) addSchedule(s models.Schedule) error {
+	loc := m.opts.Location(s.RoomNumber)
+	m.names[s.UserID] = s.FirstName + " " + s.SecondName
+
+	first := m.opts.From.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(m.opts.To); day = day.AddDate(0, 0, 1) {
+		if day.Weekday().String() != s.Day {
+			continue
+		}
+		start, end, err := s.Interval(day)
+		if err != nil {
+			return err
+		}
+		if start.Before(m.opts.From) || !start.Before(m.opts.To) || end.After(m.opts.Now) {
+			continue
+		}
+		key := slotKey{s.UserID, s.RoomNumber}
+		m.slots[key] = append(m.slots[key], &Slot{
+			ScheduleID: s.ID,
+			UserID:     s.UserID,
+			Name:       m.names[s.UserID],
+			Subject:    s.Subject,
+			Room:       s.RoomNumber,
+			Week:       isoWeek(start),
+			Start:      start,
+			End:        end,
+		})
+	}
+	return nil
+}
+
+// visit засчитывает проход в занятие, в окно которого он попал, или
+// записывает его как проход вне расписания
+func (m *matcher) visit(userID primitive.ObjectID, room string, at time.Time, source string) {
+	if userID.IsZero() {
+		return
+	}
+	for _, slot := range m.slots[slotKey{userID, room}] {
+		if at.Before(slot.Start.Add(-m.opts.EarlyWindow)) || !at.Before(slot.End) {
+			continue
+		}
+		// Источники читаются по очереди, первым может прийти не самый ранний проход
+		if slot.Arrival == nil || at.Before(*slot.Arrival) {
+			arrival := at
+			slot.Arrival, slot.Source = &arrival, source
+		}
+		return
+	}
+	if at.Before(m.opts.From) {
+		return
+	}
+	week := isoWeek(at.In(m.opts.Location(room)))
+	m.unscheduled[visitKey{userID, week}]++
+	m.total++
+	m.visits = append(m.visits, Visit{UserID: userID, Room: room, Week: week, Time: at, Source: source})
+	// Источники читаются по очереди, поэтому самые ранние проходы
+	// отбираются сортировкой, когда срез вырос вдвое против предела
+	if limit := m.opts.MaxUnscheduled; limit > 0 && len(m.visits) >= 2*limit {
+		m.sortVisits()
+		m.visits = m.visits[:limit]
+	}
+}
+
+func (m *matcher) sortVisits() {
+	sort.SliceStable(m.visits, func(i, j int) bool { return m.visits[i].Time.Before(m.visits[j].Time) })
+}
+
+// result проставляет итоги занятий и возвращает их по времени начала
+func (m *matcher) result() []Slot {
+	slots := []Slot{}
+	for _, list := range m.slots {
+		for _, slot := range list {
+			switch {
+			case slot.Arrival == nil:
+				slot.Status = StatusAbsent
+			case slot.Arrival.Sub(slot.Start) > m.opts.LateAfter:
+				slot.Status = StatusLate
+				slot.LateMinutes = int((slot.Arrival.Sub(slot.Start) + time.Minute - 1) / time.Minute)
+			default:
+				slot.Status = StatusPresent
+			}
+			slots = append(slots, *slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if !slots[i].Start.Equal(slots[j].Start) {
+			return slots[i].Start.Before(slots[j].Start)
+		}
+		return slots[i].Room < slots[j].Room
+	})
+	m.sortVisits()
+	if limit := m.opts.MaxUnscheduled; limit > 0 && len(m.visits) > limit {
+		m.visits = m.visits[:limit]
+	}
+	return slots
+}
+
+// summarize сводит занятия и проходы вне расписания по преподавателю,
+// предмету и неделе
+func summarize(slots []Slot, unscheduled map[visitKey]int, name func(primitive.ObjectID) string) []Row {
+	type key struct {
+		user          primitive.ObjectID
+		subject, week string
+	}
+	rows := map[key]*Row{}
+	row := func(k key, name string) *Row {
+		r := rows[k]
+		if r == nil {
+			r = &Row{UserID: k.user, Name: name, Subject: k.subject, Week: k.week}
+			rows[k] = r
+		}
+		return r
+	}
+	for _, s := range slots {
+		r := row(key{s.UserID, s.Subject, s.Week}, s.Name)
+		r.Scheduled++
+		r.LateMinutes += s.LateMinutes
+		switch s.Status {
+		case StatusPresent:
+			r.Present++
+		case StatusLate:
+			r.Late++
+		default:
+			r.Absent++
+		}
+	}
+	for k, n := range unscheduled {
+		row(key{k.user, "", k.week}, name(k.user)).Unscheduled += n
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.UserID != b.UserID {
+			return a.UserID.Hex() < b.UserID.Hex()
+		}
+		if a.Week != b.Week {
+			return a.Week < b.Week
+		}
+		return a.Subject < b.Subject
+	})
+	return out
+}
+
+// isoWeek — неделя по ISO 8601, например 2026-W37
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
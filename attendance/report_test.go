@@ -0,0 +1,55 @@
+package attendance
+
+import (
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Перечисляются только самые ранние проходы вне расписания, итоги — по всем
+func TestBuildCapsUnscheduled(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	user := primitive.NewObjectID()
+	base := time.Date(2026, 9, 14, 8, 0, 0, 0, time.UTC)
+
+	// Журнал читается раньше загрузок, но самые ранние проходы — в загрузках
+	for i := 2; i < 5; i++ {
+		entry := models.Log{EventType: eventlog.TypeAccessGranted, UserID: user, Room: "101", Timestamp: base.Add(time.Duration(i) * time.Hour)}
+		if err := store.Logs.Insert(ctx, &entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := store.Access.InsertMany(ctx, []models.AccessLog{
+		{AccessTime: base, Status: models.AccessStatusGranted, RoomID: "101", KeyID: "k", UserID: user, ControllerID: "door-1"},
+		{AccessTime: base.Add(time.Hour), Status: models.AccessStatusGranted, RoomID: "101", KeyID: "k", UserID: user, ControllerID: "door-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Build(ctx, store, Options{
+		From:           base.Add(-24 * time.Hour),
+		To:             base.Add(24 * time.Hour),
+		Now:            base.Add(24 * time.Hour),
+		Location:       func(string) *time.Location { return time.UTC },
+		MaxUnscheduled: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UnscheduledTotal != 5 || !report.Truncated {
+		t.Fatalf("unscheduled_total = %d, truncated = %v, ожидалось 5 и true", report.UnscheduledTotal, report.Truncated)
+	}
+	if len(report.Unscheduled) != 2 || !report.Unscheduled[0].Time.Equal(base) || !report.Unscheduled[1].Time.Equal(base.Add(time.Hour)) {
+		t.Fatalf("перечислены не самые ранние проходы: %+v", report.Unscheduled)
+	}
+	if len(report.Summary) != 1 || report.Summary[0].Unscheduled != 5 {
+		t.Fatalf("итоги = %+v, ожидалось 5 проходов вне расписания", report.Summary)
+	}
+}
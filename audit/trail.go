@@ -0,0 +1,185 @@
+// Package audit ведёт журнал административных изменений. Каждая запись
+// содержит хэш предыдущей, поэтому изменение или удаление записи в базе
+// обнаруживается проверкой цепочки
+package audit
+
+import (
+	"access-control-system/clock"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSeqConflict — следующий номер цепочки уже занят другим экземпляром
+// сервиса. Внутри транзакции MongoDB ошибка записи отменяет транзакцию,
+// поэтому повторять нужно всю транзакцию, а не только запись
+var ErrSeqConflict = errors.New("номер записи аудита уже занят")
+
+// redactedFields не попадают в журнал значениями, только фактом изменения
+var redactedFields = map[string]bool{"password": true, "secret": true}
+
+// Trail добавляет записи в журнал аудита. Безопасен для одновременного
+// использования; nil-журнал ничего не записывает
+type Trail struct {
+	repo  repository.AuditRepository
+	clock clock.Clock
+	mu    sync.Mutex
+}
+
+func NewTrail(repo repository.AuditRepository, clk clock.Clock) *Trail {
+	return &Trail{repo: repo, clock: clk}
+}
+
+// Record дописывает entry в конец цепочки, заполняя Seq, Timestamp,
+// PrevHash и Hash. Если номер занят, возвращает ErrSeqConflict
+func (t *Trail) Record(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	if t == nil {
+		return entry, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(entry.Changes) == 0 {
+		entry.Changes = nil
+	}
+	// MongoDB хранит время с точностью до миллисекунды, хэш должен совпасть
+	// после чтения
+	entry.Timestamp = t.clock.Now().UTC().Truncate(time.Millisecond)
+
+	last, err := t.repo.Last(ctx)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		entry.Seq, entry.PrevHash = 1, ""
+	case err != nil:
+		return entry, err
+	default:
+		entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+	}
+	entry.Hash = Hash(entry)
+
+	err = t.repo.Insert(ctx, &entry)
+	var dup *repository.DuplicateKeyError
+	if errors.As(err, &dup) {
+		return entry, fmt.Errorf("%w: %v", ErrSeqConflict, err)
+	}
+	return entry, err
+}
+
+// Hash вычисляет хэш записи от её содержимого и PrevHash. ID и сам Hash
+// не учитываются
+func Hash(e models.AuditEntry) string {
+	data, _ := json.Marshal(struct {
+		Seq       int64                `json:"seq"`
+		Timestamp string               `json:"timestamp"`
+		Actor     string               `json:"actor"`
+		Action    string               `json:"action"`
+		Entity    string               `json:"entity"`
+		EntityID  string               `json:"entity_id"`
+		Changes   []models.AuditChange `json:"changes"`
+		RequestID string               `json:"request_id"`
+		SourceIP  string               `json:"source_ip"`
+		PrevHash  string               `json:"prev_hash"`
+	}{
+		e.Seq, e.Timestamp.UTC().Format(time.RFC3339Nano), e.Actor, e.Action, e.Entity, e.EntityID,
+		e.Changes, e.RequestID, e.SourceIP, e.PrevHash,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Report — результат проверки цепочки
+type Report struct {
+	Valid   bool  `json:"valid"`
+	Entries int64 `json:"entries"`
+	// LastSeq и LastHash стоит сохранять вне базы: удаление записей с конца
+	// цепочки обнаруживается только сравнением с ними
+	LastSeq  int64  `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+	// BrokenAt — номер первой записи, не прошедшей проверку
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// errBroken останавливает проверку на первой ошибке в цепочке
+var errBroken = errors.New("цепочка нарушена")
+
+// Verify проверяет цепочку: номера идут без пропусков, каждая запись
+// ссылается на хэш предыдущей и её хэш совпадает с содержимым
+func (t *Trail) Verify(ctx context.Context) (Report, error) {
+	var r Report
+	err := t.repo.Scan(ctx, func(e models.AuditEntry) error {
+		switch {
+		case e.Seq != r.LastSeq+1:
+			r.BrokenAt = r.LastSeq + 1
+			r.Reason = fmt.Sprintf("после записи %d следует запись %d: записи удалены", r.LastSeq, e.Seq)
+		case e.PrevHash != r.LastHash:
+			r.BrokenAt = e.Seq
+			r.Reason = "prev_hash не совпадает с хэшем предыдущей записи"
+		case Hash(e) != e.Hash:
+			r.BrokenAt = e.Seq
+			r.Reason = "содержимое записи изменено"
+		default:
+			r.Entries++
+			r.LastSeq, r.LastHash = e.Seq, e.Hash
+			return nil
+		}
+		return errBroken
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return r, err
+	}
+	r.Valid = r.BrokenAt == 0
+	return r, nil
+}
+
+// Diff сравнивает JSON-представления before и after по полям верхнего
+// уровня. nil означает отсутствие сущности: при создании или удалении
+// в изменения попадают все поля
+func Diff(before, after interface{}) []models.AuditChange {
+	b, a := fields(before), fields(after)
+	names := map[string]bool{}
+	for k := range b {
+		names[k] = true
+	}
+	for k := range a {
+		names[k] = true
+	}
+
+	var changes []models.AuditChange
+	for name := range names {
+		if bytes.Equal(b[name], a[name]) {
+			continue
+		}
+		ch := models.AuditChange{Field: name, Before: b[name], After: a[name]}
+		if redactedFields[name] {
+			ch.Before, ch.After = nil, nil
+		}
+		changes = append(changes, ch)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func fields(v interface{}) map[string]json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if json.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,125 @@
+// Package bundle собирает офлайн-политики для контроллеров дверей: какие
+// ключи и в какие интервалы времени открывают комнаты контроллера.
+// Пакет подписывается Ed25519, чтобы контроллер мог проверить его без
+// связи с сервером
+package bundle
+
+import (
+	"access-control-system/models"
+	"sort"
+	"time"
+)
+
+// FormatVersion — версия формата Policy. Меняется при несовместимых изменениях
+const FormatVersion = 1
+
+// Policy — содержимое пакета. Имена полей короткие: пакет хранится в
+// памяти контроллера. Время — Unix-секунды UTC
+type Policy struct {
+	Version      int    `json:"v"`
+	ControllerID string `json:"c"`
+	IssuedAt     int64  `json:"iat"`
+	// ValidUntil — после этого момента контроллер должен считать пакет
+	// устаревшим и применять аварийную политику комнаты
+	ValidUntil int64  `json:"exp"`
+	Rooms      []Room `json:"r"`
+}
+
+type Room struct {
+	Number      string             `json:"n"`
+	StalePolicy models.StalePolicy `json:"sp"`
+	Keys        []Key              `json:"k"`
+}
+
+// Key — интервалы [начало, конец), в которые ключ открывает комнату
+type Key struct {
+	KeyID   string     `json:"id"`
+	Windows [][2]int64 `json:"w"`
+}
+
+// Input — данные для сборки пакета
+type Input struct {
+	ControllerID string
+	Rooms        []models.Room
+	// Users — пользователи с правом доступа хотя бы в одну из Rooms
+	Users     []models.User
+	Schedules []models.Schedule
+	From      time.Time
+	Days      int
+	// Location возвращает часовой пояс комнаты
+	Location func(models.Room) *time.Location
+	// DefaultStalePolicy — политика для комнат без собственной
+	DefaultStalePolicy models.StalePolicy
+}
+
+// Compile разворачивает недельные расписания в конкретные интервалы на
+// Days дней начиная с дня From по часовому поясу каждой комнаты. В пакет
+// попадают только активные пользователи с ключом и правом на комнату
+func Compile(in Input) Policy {
+	p := Policy{
+		Version:      FormatVersion,
+		ControllerID: in.ControllerID,
+		IssuedAt:     in.From.Unix(),
+		ValidUntil:   in.From.AddDate(0, 0, in.Days).Unix(),
+		Rooms:        []Room{},
+	}
+
+	byUserRoom := map[string][]models.Schedule{}
+	for _, s := range in.Schedules {
+		key := s.UserID.Hex() + "/" + s.RoomNumber
+		byUserRoom[key] = append(byUserRoom[key], s)
+	}
+
+	for _, room := range in.Rooms {
+		loc := in.Location(room)
+		r := Room{Number: room.RoomNumber, StalePolicy: room.StalePolicy, Keys: []Key{}}
+		if r.StalePolicy == "" {
+			r.StalePolicy = in.DefaultStalePolicy
+		}
+
+		for _, u := range in.Users {
+			if u.KeyID == "" || !u.IsActive() || !hasGrant(u, room.RoomNumber) {
+				continue
+			}
+			windows := expand(byUserRoom[u.ID.Hex()+"/"+room.RoomNumber], in.From.In(loc), in.Days)
+			if len(windows) > 0 {
+				r.Keys = append(r.Keys, Key{KeyID: u.KeyID, Windows: windows})
+			}
+		}
+		sort.Slice(r.Keys, func(i, j int) bool { return r.Keys[i].KeyID < r.Keys[j].KeyID })
+		p.Rooms = append(p.Rooms, r)
+	}
+	sort.Slice(p.Rooms, func(i, j int) bool { return p.Rooms[i].Number < p.Rooms[j].Number })
+	return p
+}
+
+func hasGrant(u models.User, room string) bool {
+	for _, r := range models.SplitAccessRooms(u.AccessRooms) {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+// expand возвращает интервалы расписаний на days дней с начала дня from.
+// Расписания с некорректным временем пропускаются
+func expand(schedules []models.Schedule, from time.Time, days int) [][2]int64 {
+	var windows [][2]int64
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		for _, s := range schedules {
+			if s.Day != day.Weekday().String() {
+				continue
+			}
+			begin, end, err := s.Interval(day)
+			if err != nil || !end.After(begin) || end.Before(from) {
+				continue
+			}
+			windows = append(windows, [2]int64{begin.Unix(), end.Unix()})
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i][0] < windows[j][0] })
+	return windows
+}
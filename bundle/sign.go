@@ -0,0 +1,105 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const Algorithm = "Ed25519"
+
+var ErrBadSignature = errors.New("подпись пакета недействительна")
+
+// Signed — подписанный пакет. Payload — JSON Policy в base64url,
+// Signature — подпись Ed25519 байтов JSON (до кодирования в base64url)
+type Signed struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Payload   string `json:"payload"`
+	Signature string `json:"sig"`
+}
+
+// Signer подписывает пакеты закрытым ключом сервера
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSigner создаёт подписывающий ключ из seed (32 байта) или закрытого
+// ключа (64 байта) в base64. Пустая строка — новый случайный ключ,
+// который действует до перезапуска
+func NewSigner(encoded string) (*Signer, error) {
+	if encoded == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newSigner(key), nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("ключ подписи не в base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return newSigner(ed25519.NewKeyFromSeed(raw)), nil
+	case ed25519.PrivateKeySize:
+		return newSigner(ed25519.PrivateKey(raw)), nil
+	}
+	return nil, fmt.Errorf("ключ подписи должен занимать %d или %d байт, получено %d",
+		ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+func newSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}
+}
+
+// KeyID — короткий отпечаток открытого ключа, по которому контроллер
+// выбирает ключ проверки при его смене
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+func (s *Signer) Sign(p Policy) (Signed, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return Signed{}, err
+	}
+	return Signed{
+		Algorithm: Algorithm,
+		KeyID:     s.keyID,
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, payload)),
+	}, nil
+}
+
+// Verify проверяет подпись и возвращает содержимое пакета. Так же пакет
+// проверяет контроллер
+func Verify(signed Signed, pub ed25519.PublicKey) (Policy, error) {
+	var p Policy
+	payload, err := base64.RawURLEncoding.DecodeString(signed.Payload)
+	if err != nil {
+		return p, ErrBadSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signed.Signature)
+	if err != nil || signed.Algorithm != Algorithm || !ed25519.Verify(pub, payload, sig) {
+		return p, ErrBadSignature
+	}
+	err = json.Unmarshal(payload, &p)
+	return p, err
+}
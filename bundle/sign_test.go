@@ -0,0 +1,69 @@
+package bundle
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	signer, err := NewSigner(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := Policy{Version: 1, ControllerID: "door-1", IssuedAt: 100, ValidUntil: 200,
+		Rooms: []Room{{Number: "101", Keys: []Key{{KeyID: "k1", Windows: [][2]int64{{120, 180}}}}}}}
+	signed, err := signer.Sign(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Verify(signed, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.ControllerID != "door-1" || len(got.Rooms) != 1 || got.Rooms[0].Keys[0].KeyID != "k1" {
+		t.Fatalf("содержимое пакета = %+v", got)
+	}
+
+	// Контроллер получает другие окна доступа под той же подписью
+	forged := policy
+	forged.Rooms = []Room{{Number: "101", Keys: []Key{{KeyID: "k1", Windows: [][2]int64{{0, 1 << 40}}}}}}
+	other, err := signer.Sign(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := signed
+	tampered.Payload = other.Payload
+
+	otherSigner, err := NewSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := otherSigner.Sign(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badAlg := signed
+	badAlg.Algorithm = "none"
+
+	for name, s := range map[string]Signed{
+		"подменено содержимое": tampered,
+		"чужой ключ":           foreign,
+		"другой алгоритм":      badAlg,
+		"подпись не base64":    {Algorithm: Algorithm, Payload: signed.Payload, Signature: "%%%"},
+	} {
+		if _, err := Verify(s, signer.PublicKey()); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: Verify = %v, ожидалась ErrBadSignature", name, err)
+		}
+	}
+}
+
+func TestNewSignerRejectsBadKey(t *testing.T) {
+	for _, key := range []string{"не base64", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := NewSigner(key); err == nil {
+			t.Errorf("NewSigner(%q) без ошибки", key)
+		}
+	}
+}
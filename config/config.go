@@ -1,5 +1,608 @@
 package config
 
-import "os"
+import (
+	"access-control-system/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
-var Debug = os.Getenv("DEBUG") == "true"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigFile = "config.yaml"
+	defaultJWTSecret  = "my_secret_key"
+	redacted          = "REDACTED"
+)
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type MongoConfig struct {
+	URI                    string        `yaml:"uri"`
+	Database               string        `yaml:"database"`
+	ConnectTimeout         time.Duration `yaml:"connect_timeout"`
+	QueryTimeout           time.Duration `yaml:"query_timeout"`
+	ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout"`
+	MaxPoolSize            int           `yaml:"max_pool_size"`
+	MinPoolSize            int           `yaml:"min_pool_size"`
+	MaxConnIdleTime        time.Duration `yaml:"max_conn_idle_time"`
+	// ConnectRetries — число попыток подключения при старте, 0 — без ограничения
+	ConnectRetries  int           `yaml:"connect_retries"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff"`
+}
+
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+type JWTConfig struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+type PhotoConfig struct {
+	Storage   string `yaml:"storage"`
+	Dir       string `yaml:"dir"`
+	URLSecret string `yaml:"url_secret"`
+}
+
+type UserConfig struct {
+	RetentionDays int `yaml:"retention_days"`
+}
+
+type AccessConfig struct {
+	// DegradedCacheTTL — до какого возраста кэш политик используется для
+	// решений о доступе, пока база недоступна. 0 — не использовать: сразу
+	// применяется StalePolicy
+	DegradedCacheTTL time.Duration `yaml:"degraded_cache_ttl"`
+	// CacheMaxAge — возраст кэша политик, до которого решения принимаются
+	// без обращения к базе
+	CacheMaxAge time.Duration `yaml:"cache_max_age"`
+	// CachePollInterval — период полной перезагрузки кэша. Изменения из
+	// потока изменений MongoDB применяются сразу
+	CachePollInterval time.Duration `yaml:"cache_poll_interval"`
+	// StalePolicy — политика по умолчанию для комнат без собственной
+	StalePolicy models.StalePolicy `yaml:"stale_policy"`
+}
+
+// DeviceConfig — учётные данные контроллера двери. Контроллер передаёт
+// Key в заголовке X-Device-Key
+type DeviceConfig struct {
+	Key string `yaml:"key"`
+}
+
+// ServiceConfig — учётные данные внутреннего сервиса. Сервис передаёт
+// Key в заголовке X-Service-Key
+type ServiceConfig struct {
+	Key string `yaml:"key"`
+}
+
+type BundleConfig struct {
+	// SigningKey — закрытый ключ Ed25519 (seed 32 байта) в base64.
+	// Обязателен, если заданы devices. Без контроллеров ключ может не
+	// задаваться и тогда создаётся при каждом запуске
+	SigningKey  string `yaml:"signing_key"`
+	DefaultDays int    `yaml:"default_days"`
+	MaxDays     int    `yaml:"max_days"`
+}
+
+// WebhookConfig — доставка событий внешним системам
+type WebhookConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts — попыток до перевода доставки в dead
+	MaxAttempts     int           `yaml:"max_attempts"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff"`
+	PollInterval    time.Duration `yaml:"poll_interval"`
+}
+
+// AttendanceConfig — сопоставление проходов с расписанием в отчёте о посещаемости
+type AttendanceConfig struct {
+	// LateAfter — опоздание, если первый проход позже начала занятия на этот срок
+	LateAfter time.Duration `yaml:"late_after"`
+	// EarlyWindow — за сколько до начала занятия проход засчитывается в него
+	EarlyWindow time.Duration `yaml:"early_window"`
+}
+
+// AnalyticsConfig — аналитика использования комнат
+type AnalyticsConfig struct {
+	// CacheTTL — сколько хранится построенный отчёт, 0 — не кэшировать
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// LogsConfig — хранение журнала событий. Истёкшие записи архивируются
+// в ArchiveDir и удаляются фоновой задачей
+type LogsConfig struct {
+	// RetentionDays — срок хранения событий по умолчанию, 0 — бессрочно
+	RetentionDays int `yaml:"retention_days"`
+	// RetentionByType — срок хранения отдельных типов событий в днях, 0 — бессрочно
+	RetentionByType map[string]int `yaml:"retention_by_type"`
+	// ArchiveDir — каталог сжатых архивов JSONL. Пустой — истёкшие записи
+	// удаляются без архива
+	ArchiveDir    string        `yaml:"archive_dir"`
+	PruneInterval time.Duration `yaml:"prune_interval"`
+	// BatchSize — записей в одном файле архива
+	BatchSize int `yaml:"batch_size"`
+	// RestoreDays — сколько дней хранятся записи, восстановленные из архива
+	RestoreDays int `yaml:"restore_days"`
+}
+
+// AlertsConfig — правила оповещений о подозрительных попытках доступа
+type AlertsConfig struct {
+	Rules []models.AlertRule `yaml:"rules"`
+}
+
+// BuildingConfig — настройки корпуса. Комнаты ссылаются на корпус по имени
+type BuildingConfig struct {
+	Timezone string `yaml:"timezone"`
+}
+
+// Config — конфигурация сервиса. Значения берутся из умолчаний,
+// затем из YAML-файла, затем из переменных окружения
+type Config struct {
+	Debug    bool         `yaml:"debug"`
+	Timezone string       `yaml:"timezone"`
+	Server   ServerConfig `yaml:"server"`
+	Mongo    MongoConfig  `yaml:"mongo"`
+	CORS     CORSConfig   `yaml:"cors"`
+	JWT      JWTConfig    `yaml:"jwt"`
+	Photos   PhotoConfig  `yaml:"photos"`
+	Users    UserConfig   `yaml:"users"`
+	Access   AccessConfig `yaml:"access"`
+
+	Buildings map[string]BuildingConfig `yaml:"buildings"`
+	// Devices — контроллеры дверей по AccessControllerID
+	Devices map[string]DeviceConfig `yaml:"devices"`
+	// Services — внутренние сервисы, которым разрешено писать в журнал событий
+	Services map[string]ServiceConfig `yaml:"services"`
+	Bundles  BundleConfig             `yaml:"bundles"`
+	Webhooks WebhookConfig            `yaml:"webhooks"`
+	Alerts   AlertsConfig             `yaml:"alerts"`
+	Logs     LogsConfig               `yaml:"logs"`
+
+	Attendance AttendanceConfig `yaml:"attendance"`
+	Analytics  AnalyticsConfig  `yaml:"analytics"`
+
+	location          *time.Location
+	buildingLocations map[string]*time.Location
+}
+
+// Current — действующая конфигурация, устанавливается при старте через MustLoad
+var Current *Config
+
+func Default() *Config {
+	return &Config{
+		Timezone: "Asia/Almaty",
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Mongo: MongoConfig{
+			ConnectTimeout:         10 * time.Second,
+			QueryTimeout:           5 * time.Second,
+			ServerSelectionTimeout: 5 * time.Second,
+			MaxPoolSize:            100,
+			MaxConnIdleTime:        5 * time.Minute,
+			ConnectRetries:         10,
+			RetryBackoff:           500 * time.Millisecond,
+			RetryMaxBackoff:        30 * time.Second,
+		},
+		CORS:   CORSConfig{AllowOrigins: []string{"http://localhost:5173"}},
+		JWT:    JWTConfig{Secret: defaultJWTSecret, TTL: 72 * time.Hour},
+		Photos: PhotoConfig{Storage: "local", Dir: "uploads/photos"},
+		Users:  UserConfig{RetentionDays: 90},
+		Access: AccessConfig{
+			DegradedCacheTTL:  10 * time.Minute,
+			CacheMaxAge:       2 * time.Minute,
+			CachePollInterval: 30 * time.Second,
+			StalePolicy:       models.StaleFailClosed,
+		},
+		Bundles: BundleConfig{DefaultDays: 7, MaxDays: 31},
+		Webhooks: WebhookConfig{
+			Timeout:         10 * time.Second,
+			MaxAttempts:     8,
+			RetryBackoff:    30 * time.Second,
+			RetryMaxBackoff: time.Hour,
+			PollInterval:    5 * time.Second,
+		},
+		Logs: LogsConfig{
+			RetentionDays: 365,
+			ArchiveDir:    "archive/logs",
+			PruneInterval: time.Hour,
+			BatchSize:     5000,
+			RestoreDays:   30,
+		},
+		Attendance: AttendanceConfig{LateAfter: 5 * time.Minute, EarlyWindow: 15 * time.Minute},
+		Analytics:  AnalyticsConfig{CacheTTL: 10 * time.Minute},
+		Alerts: AlertsConfig{Rules: []models.AlertRule{
+			{
+				Name: "repeated_denials", Type: models.RuleThreshold, Severity: models.SeverityHigh,
+				Outcome: models.OutcomeDenied, GroupBy: models.GroupByUser, Count: 5, Window: time.Minute,
+			},
+			{
+				Name: "night_floors", Type: models.RuleOutOfHours, Severity: models.SeverityHigh,
+				From: "00:00", To: "06:00", MinFloors: 3, Window: time.Hour,
+			},
+			{
+				Name: "building_travel", Type: models.RuleImpossibleTravel, Severity: models.SeverityMedium,
+				MinTravel: 5 * time.Minute,
+			},
+			{
+				Name: "access_after_denial", Type: models.RuleAccessAfterDenial, Severity: models.SeverityMedium,
+				Window: 2 * time.Minute,
+			},
+		}},
+	}
+}
+
+// Load читает конфигурацию. Если path пуст, используется CONFIG_FILE или,
+// при наличии, config.yaml в рабочем каталоге
+func Load(path string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Файл .env не найден")
+	}
+
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("CONFIG_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("ошибка разбора %s: %w", path, err)
+		}
+		log.Printf("Конфигурация загружена из %s", path)
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("ошибка чтения %s: %w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MustLoad загружает конфигурацию и делает её текущей. Ошибки фатальны
+func MustLoad(path string) *Config {
+	cfg, err := Load(path)
+	if err != nil {
+		log.Fatalf("Некорректная конфигурация: %v", err)
+	}
+	if cfg.JWT.Secret == defaultJWTSecret {
+		log.Println("ВНИМАНИЕ: используется ключ JWT по умолчанию, задайте JWT_SECRET")
+	}
+	Current = cfg
+	return cfg
+}
+
+// Переменные окружения переопределяют значения из файла
+func (c *Config) applyEnv() error {
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*dst = v
+		}
+	}
+	var errs []error
+	dur := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = d
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	if v, ok := os.LookupEnv("DEBUG"); ok {
+		c.Debug = v == "true"
+	}
+	str("TIMEZONE", &c.Timezone)
+	str("LISTEN_ADDR", &c.Server.Addr)
+	dur("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	dur("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	dur("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	str("MONGODB_URI", &c.Mongo.URI)
+	str("DB_NAME", &c.Mongo.Database)
+	dur("MONGO_CONNECT_TIMEOUT", &c.Mongo.ConnectTimeout)
+	dur("MONGO_QUERY_TIMEOUT", &c.Mongo.QueryTimeout)
+	dur("MONGO_SERVER_SELECTION_TIMEOUT", &c.Mongo.ServerSelectionTimeout)
+	num("MONGO_MAX_POOL_SIZE", &c.Mongo.MaxPoolSize)
+	num("MONGO_MIN_POOL_SIZE", &c.Mongo.MinPoolSize)
+	dur("MONGO_MAX_CONN_IDLE_TIME", &c.Mongo.MaxConnIdleTime)
+	num("MONGO_CONNECT_RETRIES", &c.Mongo.ConnectRetries)
+	dur("MONGO_RETRY_BACKOFF", &c.Mongo.RetryBackoff)
+	dur("MONGO_RETRY_MAX_BACKOFF", &c.Mongo.RetryMaxBackoff)
+	if v := os.Getenv("CORS_ALLOW_ORIGINS"); v != "" {
+		c.CORS.AllowOrigins = splitList(v)
+	}
+	str("JWT_SECRET", &c.JWT.Secret)
+	dur("JWT_TTL", &c.JWT.TTL)
+	str("PHOTO_STORAGE", &c.Photos.Storage)
+	str("PHOTO_DIR", &c.Photos.Dir)
+	str("PHOTO_URL_SECRET", &c.Photos.URLSecret)
+	num("USER_RETENTION_DAYS", &c.Users.RetentionDays)
+	dur("ACCESS_DEGRADED_CACHE_TTL", &c.Access.DegradedCacheTTL)
+	dur("ACCESS_CACHE_MAX_AGE", &c.Access.CacheMaxAge)
+	dur("ACCESS_CACHE_POLL_INTERVAL", &c.Access.CachePollInterval)
+	if v := os.Getenv("ACCESS_STALE_POLICY"); v != "" {
+		c.Access.StalePolicy = models.StalePolicy(v)
+	}
+	str("BUNDLE_SIGNING_KEY", &c.Bundles.SigningKey)
+	num("BUNDLE_DEFAULT_DAYS", &c.Bundles.DefaultDays)
+	num("BUNDLE_MAX_DAYS", &c.Bundles.MaxDays)
+	dur("WEBHOOK_TIMEOUT", &c.Webhooks.Timeout)
+	num("WEBHOOK_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts)
+	dur("WEBHOOK_RETRY_BACKOFF", &c.Webhooks.RetryBackoff)
+	dur("WEBHOOK_RETRY_MAX_BACKOFF", &c.Webhooks.RetryMaxBackoff)
+	dur("WEBHOOK_POLL_INTERVAL", &c.Webhooks.PollInterval)
+	num("LOG_RETENTION_DAYS", &c.Logs.RetentionDays)
+	str("LOG_ARCHIVE_DIR", &c.Logs.ArchiveDir)
+	dur("LOG_PRUNE_INTERVAL", &c.Logs.PruneInterval)
+	num("LOG_ARCHIVE_BATCH_SIZE", &c.Logs.BatchSize)
+	num("LOG_RESTORE_DAYS", &c.Logs.RestoreDays)
+	dur("ATTENDANCE_LATE_AFTER", &c.Attendance.LateAfter)
+	dur("ATTENDANCE_EARLY_WINDOW", &c.Attendance.EarlyWindow)
+	dur("ANALYTICS_CACHE_TTL", &c.Analytics.CacheTTL)
+	// LOG_RETENTION_BY_TYPE=тип=дни,тип=дни
+	if v := os.Getenv("LOG_RETENTION_BY_TYPE"); v != "" {
+		if c.Logs.RetentionByType == nil {
+			c.Logs.RetentionByType = map[string]int{}
+		}
+		for _, pair := range splitList(v) {
+			eventType, raw, ok := strings.Cut(pair, "=")
+			days, err := strconv.Atoi(raw)
+			if !ok || eventType == "" || err != nil {
+				errs = append(errs, fmt.Errorf("LOG_RETENTION_BY_TYPE: ожидается тип=дни, получено %q", pair))
+				continue
+			}
+			c.Logs.RetentionByType[eventType] = days
+		}
+	}
+	// DEVICE_KEYS=контроллер=ключ,контроллер=ключ
+	if v := os.Getenv("DEVICE_KEYS"); v != "" {
+		if c.Devices == nil {
+			c.Devices = map[string]DeviceConfig{}
+		}
+		for _, pair := range splitList(v) {
+			id, key, ok := strings.Cut(pair, "=")
+			if !ok || id == "" || key == "" {
+				errs = append(errs, fmt.Errorf("DEVICE_KEYS: ожидается контроллер=ключ, получено %q", pair))
+				continue
+			}
+			c.Devices[id] = DeviceConfig{Key: key}
+		}
+	}
+	// SERVICE_KEYS=сервис=ключ,сервис=ключ
+	if v := os.Getenv("SERVICE_KEYS"); v != "" {
+		if c.Services == nil {
+			c.Services = map[string]ServiceConfig{}
+		}
+		for _, pair := range splitList(v) {
+			name, key, ok := strings.Cut(pair, "=")
+			if !ok || name == "" || key == "" {
+				errs = append(errs, fmt.Errorf("SERVICE_KEYS: ожидается сервис=ключ, получено %q", pair))
+				continue
+			}
+			c.Services[name] = ServiceConfig{Key: key}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate проверяет конфигурацию и собирает все ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr не задан")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout должен быть положительным")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout должен быть положительным")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout должен быть положительным")
+	check(c.Mongo.URI != "", "mongo.uri не задан (MONGODB_URI)")
+	check(c.Mongo.Database != "", "mongo.database не задан (DB_NAME)")
+	check(c.Mongo.ConnectTimeout > 0, "mongo.connect_timeout должен быть положительным")
+	check(c.Mongo.QueryTimeout > 0, "mongo.query_timeout должен быть положительным")
+	check(c.Mongo.ServerSelectionTimeout > 0, "mongo.server_selection_timeout должен быть положительным")
+	check(c.Mongo.MaxPoolSize > 0, "mongo.max_pool_size должен быть положительным")
+	check(c.Mongo.MinPoolSize >= 0 && c.Mongo.MinPoolSize <= c.Mongo.MaxPoolSize,
+		"mongo.min_pool_size должен быть от 0 до mongo.max_pool_size")
+	check(c.Mongo.MaxConnIdleTime >= 0, "mongo.max_conn_idle_time не может быть отрицательным")
+	check(c.Mongo.ConnectRetries >= 0, "mongo.connect_retries не может быть отрицательным")
+	check(c.Mongo.RetryBackoff > 0, "mongo.retry_backoff должен быть положительным")
+	check(c.Mongo.RetryMaxBackoff >= c.Mongo.RetryBackoff, "mongo.retry_max_backoff не может быть меньше mongo.retry_backoff")
+	check(len(c.CORS.AllowOrigins) > 0, "cors.allow_origins пуст")
+	check(c.JWT.Secret != "", "jwt.secret не задан (JWT_SECRET)")
+	check(c.JWT.TTL > 0, "jwt.ttl должен быть положительным")
+	check(c.Photos.Storage == "local" || c.Photos.Storage == "gridfs",
+		"photos.storage должен быть local или gridfs, получено %q", c.Photos.Storage)
+	check(c.Photos.Storage != "local" || c.Photos.Dir != "", "photos.dir не задан")
+	check(c.Users.RetentionDays > 0, "users.retention_days должен быть положительным")
+	check(c.Access.DegradedCacheTTL >= 0, "access.degraded_cache_ttl не может быть отрицательным")
+	check(c.Access.CachePollInterval > 0, "access.cache_poll_interval должен быть положительным")
+	check(c.Access.CacheMaxAge > c.Access.CachePollInterval, "access.cache_max_age должен быть больше access.cache_poll_interval")
+	check(c.Access.StalePolicy.Valid(), "access.stale_policy должен быть fail_closed или fail_open, получено %q", c.Access.StalePolicy)
+
+	check(c.Bundles.DefaultDays > 0, "bundles.default_days должен быть положительным")
+	check(c.Bundles.MaxDays >= c.Bundles.DefaultDays, "bundles.max_days не может быть меньше bundles.default_days")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout должен быть положительным")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts должен быть положительным")
+	check(c.Webhooks.RetryBackoff > 0, "webhooks.retry_backoff должен быть положительным")
+	check(c.Webhooks.RetryMaxBackoff >= c.Webhooks.RetryBackoff, "webhooks.retry_max_backoff не может быть меньше webhooks.retry_backoff")
+	check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval должен быть положительным")
+	check(c.Logs.RetentionDays >= 0, "logs.retention_days не может быть отрицательным")
+	for eventType, days := range c.Logs.RetentionByType {
+		check(days >= 0, "logs.retention_by_type.%s не может быть отрицательным", eventType)
+	}
+	check(c.Logs.PruneInterval > 0, "logs.prune_interval должен быть положительным")
+	check(c.Logs.BatchSize > 0, "logs.batch_size должен быть положительным")
+	check(c.Logs.RestoreDays > 0, "logs.restore_days должен быть положительным")
+	check(c.Attendance.LateAfter >= 0, "attendance.late_after не может быть отрицательным")
+	check(c.Attendance.EarlyWindow >= 0, "attendance.early_window не может быть отрицательным")
+	check(c.Analytics.CacheTTL >= 0, "analytics.cache_ttl не может быть отрицательным")
+	ruleNames := map[string]bool{}
+	for _, rule := range c.Alerts.Rules {
+		err := rule.Validate()
+		check(err == nil, "alerts.rules: %v", err)
+		check(!ruleNames[rule.Name], "alerts.rules: правило %s задано дважды", rule.Name)
+		ruleNames[rule.Name] = true
+	}
+	check(len(c.Devices) == 0 || c.Bundles.SigningKey != "",
+		"bundles.signing_key обязателен, если заданы devices: иначе пакеты контроллеров не проверяются после перезапуска")
+	for id, d := range c.Devices {
+		check(len(d.Key) >= 16, "devices.%s.key должен быть не короче 16 символов", id)
+	}
+	for name, s := range c.Services {
+		check(len(s.Key) >= 16, "services.%s.key должен быть не короче 16 символов", name)
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	check(err == nil, "timezone %q: %v", c.Timezone, err)
+	c.location = loc
+
+	c.buildingLocations = map[string]*time.Location{}
+	for name, b := range c.Buildings {
+		if b.Timezone == "" {
+			continue
+		}
+		loc, err := time.LoadLocation(b.Timezone)
+		check(err == nil, "buildings.%s.timezone %q: %v", name, b.Timezone, err)
+		c.buildingLocations[name] = loc
+	}
+
+	return errors.Join(errs...)
+}
+
+// Location возвращает часовой пояс, проверенный при загрузке
+func (c *Config) Location() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// BuildingLocation возвращает часовой пояс корпуса, а если он не задан —
+// общий часовой пояс сервиса
+func (c *Config) BuildingLocation(building string) *time.Location {
+	if loc, ok := c.buildingLocations[building]; ok {
+		return loc
+	}
+	return c.Location()
+}
+
+func (c *Config) UserRetention() time.Duration {
+	return time.Duration(c.Users.RetentionDays) * 24 * time.Hour
+}
+
+// LogRetention — срок хранения событий по умолчанию, 0 — бессрочно
+func (c *Config) LogRetention() time.Duration {
+	return time.Duration(c.Logs.RetentionDays) * 24 * time.Hour
+}
+
+// LogRetentionByType — сроки хранения отдельных типов событий
+func (c *Config) LogRetentionByType() map[string]time.Duration {
+	byType := make(map[string]time.Duration, len(c.Logs.RetentionByType))
+	for eventType, days := range c.Logs.RetentionByType {
+		byType[eventType] = time.Duration(days) * 24 * time.Hour
+	}
+	return byType
+}
+
+// Redacted возвращает копию конфигурации со скрытыми секретами
+func (c *Config) Redacted() Config {
+	r := *c
+	r.Mongo.URI = RedactURI(c.Mongo.URI)
+	if r.JWT.Secret != "" {
+		r.JWT.Secret = redacted
+	}
+	if r.Photos.URLSecret != "" {
+		r.Photos.URLSecret = redacted
+	}
+	if r.Bundles.SigningKey != "" {
+		r.Bundles.SigningKey = redacted
+	}
+	if c.Devices != nil {
+		r.Devices = make(map[string]DeviceConfig, len(c.Devices))
+		for id := range c.Devices {
+			r.Devices[id] = DeviceConfig{Key: redacted}
+		}
+	}
+	if c.Services != nil {
+		r.Services = make(map[string]ServiceConfig, len(c.Services))
+		for name := range c.Services {
+			r.Services[name] = ServiceConfig{Key: redacted}
+		}
+	}
+	return r
+}
+
+// RedactURI скрывает пароль в строке подключения
+func RedactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return redacted
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// YAML возвращает конфигурацию в виде YAML без секретов
+func (c *Config) YAML() (string, error) {
+	r := c.Redacted()
+	data, err := yaml.Marshal(&r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -9,7 +9,7 @@ import (
 
 func SetupCORS(router *gin.Engine) {
 	corsConfig := cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173"},
+		AllowOrigins:     Current.CORS.AllowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -17,7 +17,7 @@ func SetupCORS(router *gin.Engine) {
 		MaxAge:           12 * time.Hour,
 	}
 
-	if Debug {
+	if Current.Debug {
 		corsConfig.AllowOrigins = []string{"*"} // В режиме отладки разрешаем все источники
 	}
 
@@ -2,67 +2,90 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"math/rand"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var DB *mongo.Client
+// ConnectDB подключается к MongoDB, повторяя попытки с экспоненциальной
+// задержкой, пока база не ответит на ping, не кончатся попытки или не
+// будет отменён ctx. Строка подключения в журнале и ошибках без пароля
+func ConnectDB(ctx context.Context, cfg MongoConfig) (*mongo.Client, error) {
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ServerSelectionTimeout).
+		SetMaxPoolSize(uint64(cfg.MaxPoolSize)).
+		SetMinPoolSize(uint64(cfg.MinPoolSize)).
+		SetMaxConnIdleTime(cfg.MaxConnIdleTime).
+		SetMonitor(commandMonitor()).
+		SetPoolMonitor(poolMonitor())
 
-func ConnectDB() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Файл .env не найден")
-	}
-
-	log.Println("Получаем MONGODB_URI из переменных окружения")
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		log.Fatal("Переменная окружения MONGODB_URI не установлена")
-	}
-	log.Println("MONGODB_URI:", mongoURI)
-
-	log.Println("Создаём новый клиент MongoDB")
-	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	log.Println("Подключаемся к MongoDB:", RedactURI(cfg.URI))
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatalf("Ошибка при создании клиента MongoDB: %v", err)
+		return nil, fmt.Errorf("ошибка при создании клиента MongoDB: %w", redactError(err, cfg.URI))
 	}
-	log.Println("Клиент MongoDB создан")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	backoff := cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+		err = client.Ping(pingCtx, readpref.Primary())
+		cancel()
+		if err == nil {
+			log.Println("Соединение с MongoDB установлено")
+			return client, nil
+		}
+		err = redactError(err, cfg.URI)
 
-	log.Println("Подключаемся к MongoDB")
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatalf("Ошибка при подключении к MongoDB: %v", err)
-	}
-	log.Println("Соединение с MongoDB установлено")
+		if cfg.ConnectRetries > 0 && attempt >= cfg.ConnectRetries {
+			break
+		}
+		wait := jitter(backoff)
+		log.Printf("MongoDB недоступна (попытка %d): %v. Повтор через %s", attempt, err, wait.Round(time.Millisecond))
 
-	log.Println("Проверяем соединение с MongoDB")
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatalf("Ошибка при проверке соединения с MongoDB: %v", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			client.Disconnect(context.Background())
+			return nil, fmt.Errorf("подключение к MongoDB прервано: %w", errors.Join(ctx.Err(), err))
+		}
+		backoff = min(backoff*2, cfg.RetryMaxBackoff)
 	}
-	log.Println("Соединение с MongoDB успешно проверено")
 
-	DB = client
+	client.Disconnect(context.Background())
+	return nil, fmt.Errorf("не удалось подключиться к MongoDB за %d попыток: %w", cfg.ConnectRetries, err)
+}
+
+// jitter возвращает случайную задержку от d/2 до d, чтобы экземпляры
+// сервиса не переподключались одновременно
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
 }
 
-func GetCollection(collectionName string) *mongo.Collection {
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		log.Fatal("Переменная окружения DB_NAME не установлена")
+// redactError убирает пароль из текста ошибки драйвера
+func redactError(err error, uri string) error {
+	u, parseErr := url.Parse(uri)
+	if parseErr != nil || err == nil {
+		return err
 	}
-	log.Printf("Получаем базу данных: %s\n", dbName)
-	db := DB.Database(dbName)
-	log.Printf("База данных получена: %s\n", dbName)
+	password, ok := u.User.Password()
+	if !ok || password == "" || !strings.Contains(err.Error(), password) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), password, redacted))
+}
 
-	log.Printf("Получаем коллекцию: %s\n", collectionName)
-	collection := db.Collection(collectionName)
-	log.Printf("Коллекция получена: %s\n", collectionName)
-	return collection
+// QueryContext возвращает контекст с таймаутом запроса к базе из конфигурации
+func QueryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), Current.Mongo.QueryTimeout)
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"access-control-system/metrics"
+	"context"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoCommands = metrics.Default.NewCounter("mongo_commands_total",
+		"Команды MongoDB по имени и результату", "command", "status")
+	mongoCommandDuration = metrics.Default.NewHistogram("mongo_command_duration_seconds",
+		"Длительность команд MongoDB", nil, "command")
+	mongoConnections = metrics.Default.NewGauge("mongo_pool_connections",
+		"Открытые соединения пула MongoDB")
+	mongoConnectionsInUse = metrics.Default.NewGauge("mongo_pool_connections_in_use",
+		"Соединения пула MongoDB, выданные под операции")
+	mongoPoolEvents = metrics.Default.NewCounter("mongo_pool_events_total",
+		"События пула соединений MongoDB", "type")
+)
+
+func commandMonitor() *event.CommandMonitor {
+	finished := func(e event.CommandFinishedEvent, status string) {
+		mongoCommands.With(e.CommandName, status).Inc()
+		mongoCommandDuration.With(e.CommandName).Observe(e.Duration.Seconds())
+	}
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, "error")
+		},
+	}
+}
+
+func poolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				mongoConnections.With().Inc()
+			case event.ConnectionClosed:
+				mongoConnections.With().Dec()
+			case event.GetSucceeded:
+				mongoConnectionsInUse.With().Inc()
+			case event.ConnectionReturned:
+				mongoConnectionsInUse.With().Dec()
+			case event.PoolCleared, event.GetFailed:
+				mongoPoolEvents.With(e.Type).Inc()
+			}
+		},
+	}
+}
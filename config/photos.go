@@ -0,0 +1,28 @@
+package config
+
+import (
+	"access-control-system/storage"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewPhotoStore создаёт хранилище фотографий согласно photos.storage:
+// "local" (каталог photos.dir) или "gridfs" в базе db
+func NewPhotoStore(db *mongo.Database) (storage.Store, error) {
+	if Current.Photos.Storage == "gridfs" {
+		store, err := storage.NewGridFSStore(db, "photos")
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Фотографии хранятся в GridFS")
+		return store, nil
+	}
+
+	store, err := storage.NewLocalStore(Current.Photos.Dir)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Фотографии хранятся в каталоге %s", Current.Photos.Dir)
+	return store, nil
+}
@@ -2,144 +2,361 @@ package controllers
 
 import (
 	"access-control-system/config"
+	"access-control-system/eventlog"
+	"access-control-system/metrics"
 	"access-control-system/models"
+	"access-control-system/policy"
+	"access-control-system/repository"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type ScheduleWithUser struct {
-	models.Schedule `bson:",inline"`
-	UserInfo        struct {
-		FirstName  string `bson:"first_name"`
-		SecondName string `bson:"second_name"`
-	} `bson:"user_info"`
+// Причины решений о доступе. Для отказов совпадают с типами событий журнала
+const (
+	reasonGranted         = eventlog.TypeAccessGranted
+	reasonInactiveUser    = eventlog.TypeInactiveUser
+	reasonNoRoomAccess    = eventlog.TypeNoRoomAccess
+	reasonNoSchedule      = eventlog.TypeNoSchedule
+	reasonOutsideSchedule = eventlog.TypeOutsideSchedule
+	// Данные устарели, база недоступна, решение по политике комнаты
+	reasonStaleOpen   = eventlog.TypeStaleOpen
+	reasonStaleClosed = eventlog.TypeStaleClosed
+)
+
+var errScheduleFormat = errors.New("некорректный формат времени в расписании")
+
+var (
+	accessDecisions = metrics.Default.NewCounter("access_decisions_total",
+		"Решения о доступе по причине и источнику данных", "reason", "source")
+	accessCacheFallbacks = metrics.Default.NewCounter("access_cache_fallbacks_total",
+		"Обращения к кэшу политик при недоступной базе: hit — решение по кэшу, "+
+			"miss — кэша нет, он старше access.degraded_cache_ttl или в нём нет пользователя", "result")
+)
+
+// AccessDecision — решение о доступе пользователя в комнату в момент времени
+type AccessDecision struct {
+	Granted   bool             `json:"granted"`
+	Reason    string           `json:"reason"`
+	Message   string           `json:"message"`
+	Timezone  string           `json:"timezone"`
+	LocalTime time.Time        `json:"local_time"`
+	Day       string           `json:"day"`
+	Schedule  *models.Schedule `json:"schedule,omitempty"`
+	// Source — откуда взяты данные: db, cache, stale_cache или stale_policy
+	Source string `json:"source"`
+	// Degraded — решение принято по устаревшим данным, пока база недоступна
+	Degraded bool `json:"degraded,omitempty"`
+
+	user     models.User
+	building string
+	floor    *int
+	// logEntry — событие журнала для решения. nil — решение не журналируется
+	logEntry *models.Log
 }
 
-func CheckAccess(c *gin.Context) {
-	userIDParam := c.Param("user_id")
-	roomNumber := c.Param("room_number")
+// logDecision готовит событие журнала с причиной решения
+func (d *AccessDecision) logDecision(userID primitive.ObjectID, roomNumber string, params map[string]string) {
+	d.logEntry = &models.Log{
+		EventType: d.Reason,
+		UserID:    userID,
+		Room:      roomNumber,
+		Reason:    d.Reason,
+		Params:    params,
+	}
+	if d.Schedule != nil {
+		id := d.Schedule.ID
+		d.logEntry.ScheduleID = &id
+	}
+}
 
-	userID, err := primitive.ObjectIDFromHex(userIDParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
-		return
+// evaluateAccess принимает решение о доступе в момент at по часовому поясу
+// комнаты. Ничего не записывает в журнал.
+//
+// Решение принимается по кэшу политик, пока он свежий, иначе по базе.
+// Если база недоступна, используется кэш не старше access.degraded_cache_ttl,
+// а без него или без пользователя в нём — аварийная политика комнаты
+func (h *Handler) evaluateAccess(ctx context.Context, userID primitive.ObjectID, roomNumber string, at time.Time) (AccessDecision, error) {
+	snap, fresh := h.policy.Snapshot()
+	if fresh {
+		d, err := h.decide(ctx, snapshotSource{snap}, userID, roomNumber, at)
+		// Пользователь мог быть создан после загрузки кэша
+		if !errors.Is(err, errEntityNotFound) {
+			d.Source = sourceCache
+			return d, err
+		}
 	}
 
-	loc, err := time.LoadLocation("Asia/Almaty")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка загрузки временной зоны"})
-		return
+	d, err := h.decide(ctx, storeSource{h.store}, userID, roomNumber, at)
+	if err == nil || !repository.IsUnavailable(err) {
+		d.Source = sourceDB
+		return d, err
 	}
+	log.Printf("База недоступна, решение о доступе по устаревшим данным: %v", err)
 
-	now := time.Now().In(loc)
-	currentDay := now.Weekday().String()
+	if ttl := config.Current.Access.DegradedCacheTTL; snap != nil && ttl > 0 && h.clock.Now().Sub(snap.LoadedAt) <= ttl {
+		d, err := h.decide(ctx, snapshotSource{snap}, userID, roomNumber, at)
+		// Пользователя, созданного после загрузки кэша, проверить не по чему
+		if !errors.Is(err, errEntityNotFound) {
+			accessCacheFallbacks.With("hit").Inc()
+			d.Source = sourceStaleCache
+			d.Degraded = true
+			return d, err
+		}
+	}
+	accessCacheFallbacks.With("miss").Inc()
+	return staleDecision(snap, userID, roomNumber, at), nil
+}
 
-	var user models.User
-	err = config.DB.Database("ENU").Collection("users").
-		FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user)
+// decide принимает решение по данным из src
+func (h *Handler) decide(ctx context.Context, src accessSource, userID primitive.ObjectID, roomNumber string, at time.Time) (AccessDecision, error) {
+	var d AccessDecision
+
+	user, err := src.user(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return d, errEntityNotFound
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
-		return
+		return d, err
+	}
+	d.user = user
+	name := user.FirstName + " " + user.SecondName
+
+	room, err := src.room(ctx, roomNumber)
+	if err != nil {
+		return d, err
 	}
+	if room != nil {
+		floor := room.Floor
+		d.building, d.floor = room.Building, &floor
+	}
+	now := at.In(roomLocation(room))
+	d.Timezone = now.Location().String()
+	d.LocalTime = now
+	d.Day = now.Weekday().String()
 
-	var rooms []string
-	for _, roomStr := range user.AccessRooms {
-		splitted := strings.Split(roomStr, ",")
-		rooms = append(rooms, splitted...)
+	if !user.IsActive() {
+		d.Reason = reasonInactiveUser
+		d.Message = "Учётная запись пользователя неактивна"
+		d.logDecision(user.ID, roomNumber, map[string]string{"user_name": name, "status": string(user.EffectiveStatus())})
+		return d, nil
 	}
 
 	hasRoomAccess := false
-	for _, room := range rooms {
-		if room == roomNumber {
+	for _, r := range models.SplitAccessRooms(user.AccessRooms) {
+		if r == roomNumber {
 			hasRoomAccess = true
 			break
 		}
 	}
 	if !hasRoomAccess {
-		LogEvent("unauthorized_door_access",
-			"Пользователь "+user.FirstName+" "+user.SecondName+" пытался получить доступ к комнате "+roomNumber+" без разрешения",
-			nil)
-		c.JSON(http.StatusForbidden, gin.H{"message": "У пользователя нет доступа к этой комнате"})
-		return
+		d.Reason = reasonNoRoomAccess
+		d.Message = "У пользователя нет доступа к этой комнате"
+		d.logDecision(user.ID, roomNumber, map[string]string{"user_name": name})
+		return d, nil
 	}
 
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.D{
-			{"user_id", userID},
-			{"room_number", roomNumber},
-			{"day", currentDay},
-		}}},
-		{{"$lookup", bson.D{
-			{"from", "users"},
-			{"localField", "user_id"},
-			{"foreignField", "_id"},
-			{"as", "user_info"},
-		}}},
-		{{"$unwind", "$user_info"}},
+	schedules, err := src.schedules(ctx, userID, roomNumber, d.Day)
+	if err != nil {
+		return d, err
 	}
+	if len(schedules) == 0 {
+		d.Reason = reasonNoSchedule
+		d.Message = "Нет расписания для этой комнаты в данный день"
+		d.logDecision(user.ID, roomNumber, map[string]string{"user_name": name, "day": d.Day})
+		return d, nil
+	}
+	schedule := schedules[0]
+	d.Schedule = &schedule
 
-	cursor, err := config.DB.Database("ENU").Collection("schedule").Aggregate(context.TODO(), pipeline)
+	startTime, err := scheduleTime(now, schedule.StartTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка агрегирования расписания"})
-		return
+		return d, err
 	}
-	var results []ScheduleWithUser
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка декодирования результатов"})
+	endTime, err := scheduleTime(now, schedule.EndTime)
+	if err != nil {
+		return d, err
+	}
+
+	if now.After(startTime) && now.Before(endTime) {
+		d.Granted = true
+		d.Reason = reasonGranted
+		d.Message = "Доступ разрешен"
+		d.logDecision(user.ID, roomNumber, map[string]string{"user_name": name})
+		return d, nil
+	}
+
+	d.Reason = reasonOutsideSchedule
+	d.Message = "Время доступа не соответствует расписанию"
+	d.logDecision(user.ID, roomNumber, map[string]string{
+		"user_name": name,
+		"time":      now.Format("15:04:05"),
+		"allowed":   schedule.StartTime + " - " + schedule.EndTime,
+	})
+	return d, nil
+}
+
+// staleDecision применяет аварийную политику комнаты, когда проверить
+// права пользователя не по чему. snap может быть nil
+func staleDecision(snap *policy.Snapshot, userID primitive.ObjectID, roomNumber string, at time.Time) AccessDecision {
+	d := AccessDecision{Source: sourceStale, Degraded: true}
+
+	var room *models.Room
+	if snap != nil {
+		if r, ok := snap.Room(roomNumber); ok {
+			room = &r
+		}
+		d.user, _ = snap.User(userID)
+	}
+	if room != nil {
+		floor := room.Floor
+		d.building, d.floor = room.Building, &floor
+	}
+	now := at.In(roomLocation(room))
+	d.Timezone = now.Location().String()
+	d.LocalTime = now
+	d.Day = now.Weekday().String()
+
+	stalePolicy := config.Current.Access.StalePolicy
+	if room != nil && room.StalePolicy != "" {
+		stalePolicy = room.StalePolicy
+	}
+
+	if stalePolicy == models.StaleFailOpen {
+		d.Granted = true
+		d.Reason = reasonStaleOpen
+		d.Message = "Доступ разрешен по аварийной политике комнаты"
+		d.logDecision(userID, roomNumber, nil)
+		return d
+	}
+	d.Reason = reasonStaleClosed
+	d.Message = "Проверка доступа временно недоступна"
+	d.logDecision(userID, roomNumber, nil)
+	return d
+}
+
+// scheduleTime возвращает время расписания ("15:04" или "15:04:05") в день day
+func scheduleTime(day time.Time, clock string) (time.Time, error) {
+	if len(clock) == 5 {
+		clock += ":00"
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", day.Format("2006-01-02")+" "+clock, day.Location())
+	if err != nil {
+		return t, fmt.Errorf("%w: %q", errScheduleFormat, clock)
+	}
+	return t, nil
+}
+
+// roomLocation возвращает часовой пояс комнаты: собственный, её корпуса
+// или общий часовой пояс сервиса. room может быть nil
+func roomLocation(room *models.Room) *time.Location {
+	if room == nil {
+		return config.Current.Location()
+	}
+	if room.Timezone != "" {
+		loc, err := time.LoadLocation(room.Timezone)
+		if err == nil {
+			return loc
+		}
+		log.Printf("Некорректный часовой пояс комнаты %s: %v", room.RoomNumber, err)
+	}
+	return config.Current.BuildingLocation(room.Building)
+}
+
+func respondAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errEntityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+	case errors.Is(err, errScheduleFormat):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка в формате времени расписания"})
+	case repository.IsUnavailable(err):
+		log.Printf("База недоступна, решение о доступе не принято: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "База данных недоступна"})
+	default:
+		log.Printf("Ошибка при проверке доступа: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения расписания"})
+	}
+}
+
+func (h *Handler) CheckAccess(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
 		return
 	}
-	if len(results) == 0 {
-		LogEvent("unauthorized_schedule_access",
-			"Пользователь "+user.FirstName+" "+user.SecondName+" пытался войти в комнату "+roomNumber+" без действующего расписания на "+currentDay,
-			&user.ID)
-		c.JSON(http.StatusForbidden, gin.H{"message": "Нет расписания для этой комнаты в данный день"})
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	roomNumber := c.Param("room_number")
+	decision, err := h.evaluateAccess(ctx, userID, roomNumber, h.clock.Now())
+	if err != nil {
+		respondAccessError(c, err)
 		return
 	}
-	scheduleWithUser := results[0]
-	schedule := scheduleWithUser.Schedule
 
-	if len(schedule.StartTime) == 5 {
-		schedule.StartTime = schedule.StartTime + ":00"
+	if decision.Degraded {
+		log.Printf("Решение о доступе %s в комнату %s принято без базы (%s): %s", userID.Hex(), roomNumber, decision.Source, decision.Reason)
+	}
+	accessDecisions.With(decision.Reason, decision.Source).Inc()
+
+	if decision.logEntry != nil {
+		decision.logEntry.Actor = currentActor(c)
+		decision.logEntry.SourceIP = c.ClientIP()
 	}
-	if len(schedule.EndTime) == 5 {
-		schedule.EndTime = schedule.EndTime + ":00"
+	h.publishDecision(userID, roomNumber, decision)
+	if !decision.Granted {
+		c.JSON(http.StatusForbidden, gin.H{"message": decision.Message, "degraded": decision.Degraded})
+		return
 	}
 
-	dateStr := now.Format("2006-01-02")
-	startDateTimeStr := fmt.Sprintf("%s %s", dateStr, schedule.StartTime)
-	endDateTimeStr := fmt.Sprintf("%s %s", dateStr, schedule.EndTime)
+	user := decision.user
+	c.JSON(http.StatusOK, gin.H{
+		"message":    decision.Message,
+		"firstName":  user.FirstName,
+		"secondName": user.SecondName,
+		"photoUrl":   userPhotoURL(user),
+		"degraded":   decision.Degraded,
+	})
+}
 
-	startTime, err := time.ParseInLocation("2006-01-02 15:04:05", startDateTimeStr, loc)
+type AccessSimulationRequest struct {
+	UserID     string    `json:"user_id" binding:"required"`
+	RoomNumber string    `json:"room_number" binding:"required"`
+	Timestamp  time.Time `json:"timestamp" binding:"required"`
+}
+
+// Проверка решения о доступе на произвольный момент времени (RFC 3339).
+// Результат не записывается в журнал событий
+func (h *Handler) SimulateAccess(c *gin.Context) {
+	var req AccessSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка в формате времени начала расписания"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
 		return
 	}
 
-	endTime, err := time.ParseInLocation("2006-01-02 15:04:05", endDateTimeStr, loc)
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	decision, err := h.evaluateAccess(ctx, userID, req.RoomNumber, req.Timestamp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка в формате времени окончания расписания"})
+		respondAccessError(c, err)
 		return
 	}
 
-	if now.After(startTime) && now.Before(endTime) {
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Доступ разрешен",
-			"firstName":  scheduleWithUser.UserInfo.FirstName,
-			"secondName": scheduleWithUser.UserInfo.SecondName,
-		})
-	} else {
-		LogEvent("unauthorized_time_access",
-			"Пользователь "+scheduleWithUser.UserInfo.FirstName+" "+scheduleWithUser.UserInfo.SecondName+" пытался войти в комнату "+roomNumber+" в неразрешённое время ("+now.Format("15:04:05")+
-				"). Допустимое время: "+schedule.StartTime+" - "+schedule.EndTime,
-			&user.ID)
-		c.JSON(http.StatusForbidden, gin.H{"message": "Время доступа не соответствует расписанию"})
-	}
+	c.JSON(http.StatusOK, gin.H{
+		"user":     models.NewUserResponse(decision.user),
+		"decision": decision,
+	})
 }
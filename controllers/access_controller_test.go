@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/policy"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// testConfig — конфигурация по умолчанию с вычисленными часовыми поясами
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Mongo.URI, cfg.Mongo.Database = "mongodb://localhost:27017", "test"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestScheduleTime(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	tests := []struct {
+		name  string
+		day   time.Time
+		clock string
+		want  time.Time
+	}{
+		{"часы и минуты", time.Date(2026, 9, 14, 12, 0, 0, 0, time.UTC), "09:30", time.Date(2026, 9, 14, 9, 30, 0, 0, time.UTC)},
+		{"с секундами", time.Date(2026, 9, 14, 12, 0, 0, 0, time.UTC), "09:30:15", time.Date(2026, 9, 14, 9, 30, 15, 0, time.UTC)},
+		{"день в поясе day", time.Date(2026, 9, 14, 23, 0, 0, 0, mustLocation(t, "Asia/Tokyo")), "08:00", time.Date(2026, 9, 13, 23, 0, 0, 0, time.UTC)},
+		// 29 марта 2026 в Берлине часы переводятся с 02:00 на 03:00
+		{"до перехода на летнее время", time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), "01:00", time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC)},
+		{"после перехода на летнее время", time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), "04:00", time.Date(2026, 3, 29, 2, 0, 0, 0, time.UTC)},
+		{"после перехода на зимнее время", time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), "04:00", time.Date(2026, 10, 25, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scheduleTime(tt.day, tt.clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("scheduleTime(%s, %q) = %s, ожидалось %s", tt.day, tt.clock, got.UTC(), tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "9", "25:00", "09:60", "девять"} {
+		if _, err := scheduleTime(time.Now(), bad); !errors.Is(err, errScheduleFormat) {
+			t.Errorf("scheduleTime(%q): ошибка %v, ожидалась errScheduleFormat", bad, err)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	config.Current = testConfig(t)
+	store := repository.NewMemoryStore()
+	h := NewHandler(Deps{Store: store, Clock: clock.NewManual(time.Time{})})
+	ctx := context.Background()
+
+	rooms := []models.Room{
+		{ID: primitive.NewObjectID(), RoomNumber: "utc", Timezone: "UTC"},
+		{ID: primitive.NewObjectID(), RoomNumber: "tokyo", Timezone: "Asia/Tokyo"},
+		{ID: primitive.NewObjectID(), RoomNumber: "berlin", Timezone: "Europe/Berlin"},
+		// Без часового пояса — общий пояс сервиса Asia/Almaty (UTC+5)
+		{ID: primitive.NewObjectID(), RoomNumber: "default"},
+	}
+	if err := store.Rooms.CreateMany(ctx, rooms); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{
+		ID: primitive.NewObjectID(), Email: "ivan@example.com", Status: models.StatusActive,
+		AccessRooms: []string{"utc", "tokyo", "berlin", "default", "empty"},
+	}
+	suspended := models.User{
+		ID: primitive.NewObjectID(), Email: "petr@example.com", Status: models.StatusSuspended,
+		AccessRooms: []string{"utc"},
+	}
+	for _, u := range []*models.User{&user, &suspended} {
+		u.KeyID = u.ID.Hex()
+		if err := store.Users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schedules := []models.Schedule{
+		{UserID: user.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "utc"},
+		{UserID: user.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "tokyo"},
+		{UserID: user.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "default"},
+		// Занятие через переход на летнее время: 3 часа по местным часам, 2 реальных
+		{UserID: user.ID, Day: "Sunday", StartTime: "01:00", EndTime: "04:00", RoomNumber: "berlin"},
+		{UserID: suspended.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "utc"},
+	}
+	for i := range schedules {
+		schedules[i].ID = primitive.NewObjectID()
+		if err := store.Schedules.Create(ctx, &schedules[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	utc := func(day, hour, min, sec int) time.Time { return time.Date(2026, 9, day, hour, min, sec, 0, time.UTC) }
+	tests := []struct {
+		name   string
+		user   primitive.ObjectID
+		room   string
+		at     time.Time
+		reason string
+		day    string
+	}{
+		{"внутри окна", user.ID, "utc", utc(14, 10, 0, 0), reasonGranted, "Monday"},
+		{"за секунду до начала", user.ID, "utc", utc(14, 8, 59, 59), reasonOutsideSchedule, "Monday"},
+		{"через секунду после начала", user.ID, "utc", utc(14, 9, 0, 1), reasonGranted, "Monday"},
+		{"за секунду до конца", user.ID, "utc", utc(14, 10, 59, 59), reasonGranted, "Monday"},
+		{"ровно в конце", user.ID, "utc", utc(14, 11, 0, 0), reasonOutsideSchedule, "Monday"},
+		{"другой день недели", user.ID, "utc", utc(15, 10, 0, 0), reasonNoSchedule, "Tuesday"},
+		// 00:30 UTC понедельника — 09:30 в Токио, а в Алматы ещё 05:30
+		{"окно по поясу комнаты", user.ID, "tokyo", utc(14, 0, 30, 0), reasonGranted, "Monday"},
+		{"окно по общему поясу", user.ID, "default", utc(14, 4, 30, 0), reasonGranted, "Monday"},
+		{"до окна по общему поясу", user.ID, "default", utc(14, 0, 30, 0), reasonOutsideSchedule, "Monday"},
+		// 23:30 UTC воскресенья — уже понедельник 08:30 в Токио
+		{"день недели по поясу комнаты", user.ID, "tokyo", utc(13, 23, 30, 0), reasonOutsideSchedule, "Monday"},
+		{"до перехода на летнее время", user.ID, "berlin", time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC), reasonGranted, "Sunday"},
+		{"после перехода на летнее время", user.ID, "berlin", time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), reasonGranted, "Sunday"},
+		{"конец окна после перехода", user.ID, "berlin", time.Date(2026, 3, 29, 2, 0, 0, 0, time.UTC), reasonOutsideSchedule, "Sunday"},
+		{"комната без расписания", user.ID, "empty", utc(14, 10, 0, 0), reasonNoSchedule, "Monday"},
+		{"нет доступа к комнате", user.ID, "other", utc(14, 10, 0, 0), reasonNoRoomAccess, "Monday"},
+		{"заблокированный пользователь", suspended.ID, "utc", utc(14, 10, 0, 0), reasonInactiveUser, "Monday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := h.decide(ctx, storeSource{store}, tt.user, tt.room, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Reason != tt.reason || d.Granted != (tt.reason == reasonGranted) {
+				t.Fatalf("решение %s (granted=%t), ожидалось %s; местное время %s", d.Reason, d.Granted, tt.reason, d.LocalTime)
+			}
+			if d.Day != tt.day {
+				t.Fatalf("день %s, ожидался %s", d.Day, tt.day)
+			}
+		})
+	}
+
+	if _, err := h.decide(ctx, storeSource{store}, primitive.NewObjectID(), "utc", utc(14, 10, 0, 0)); !errors.Is(err, errEntityNotFound) {
+		t.Fatalf("неизвестный пользователь: ошибка %v, ожидалась errEntityNotFound", err)
+	}
+}
+
+// unavailableUsers имитирует недоступную базу при чтении пользователя
+type unavailableUsers struct {
+	repository.UserRepository
+}
+
+func (unavailableUsers) FindByID(context.Context, primitive.ObjectID) (models.User, error) {
+	return models.User{}, mongo.ErrClientDisconnected
+}
+
+func TestEvaluateAccessWithoutDatabase(t *testing.T) {
+	config.Current = testConfig(t)
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(now)
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+
+	if err := store.Rooms.CreateMany(ctx, []models.Room{{ID: primitive.NewObjectID(), RoomNumber: "101", Timezone: "UTC"}}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{ID: primitive.NewObjectID(), Email: "ivan@example.com", Status: models.StatusActive, AccessRooms: []string{"101"}}
+	if err := store.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	err := store.Schedules.Create(ctx, &models.Schedule{
+		ID: primitive.NewObjectID(), UserID: user.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "101",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := policy.New(store, clk, config.Current.Access.CacheMaxAge, time.Minute)
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(Deps{Store: store, Clock: clk, Policy: cache})
+	store.Users = unavailableUsers{store.Users}
+
+	tests := []struct {
+		name   string
+		user   primitive.ObjectID
+		age    time.Duration
+		source string
+		reason string
+	}{
+		{"свежий кэш", user.ID, time.Minute, sourceCache, reasonGranted},
+		{"устаревший кэш в пределах degraded_cache_ttl", user.ID, 5 * time.Minute, sourceStaleCache, reasonGranted},
+		{"кэш старше degraded_cache_ttl", user.ID, 11 * time.Minute, sourceStale, reasonStaleClosed},
+		{"пользователя нет в кэше", primitive.NewObjectID(), 5 * time.Minute, sourceStale, reasonStaleClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk.Set(now.Add(tt.age))
+			d, err := h.evaluateAccess(ctx, tt.user, "101", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Source != tt.source || d.Reason != tt.reason {
+				t.Fatalf("решение %s по %s, ожидалось %s по %s", d.Reason, d.Source, tt.reason, tt.source)
+			}
+			if d.Degraded != (tt.source != sourceCache) {
+				t.Fatalf("degraded = %t", d.Degraded)
+			}
+		})
+	}
+}
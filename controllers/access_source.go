@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"access-control-system/models"
+	"access-control-system/policy"
+	"access-control-system/repository"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Откуда взяты данные для решения о доступе
+const (
+	sourceDB         = "db"
+	sourceCache      = "cache"
+	sourceStaleCache = "stale_cache"
+	sourceStale      = "stale_policy"
+)
+
+// accessSource — данные, по которым принимается решение о доступе.
+// Отсутствующий пользователь — repository.ErrNotFound, отсутствующая комната — nil
+type accessSource interface {
+	user(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	room(ctx context.Context, number string) (*models.Room, error)
+	schedules(ctx context.Context, userID primitive.ObjectID, room, day string) ([]models.Schedule, error)
+}
+
+type storeSource struct {
+	store *repository.Store
+}
+
+func (s storeSource) user(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return s.store.Users.FindByID(ctx, id)
+}
+
+func (s storeSource) room(ctx context.Context, number string) (*models.Room, error) {
+	room, err := s.store.Rooms.FindByNumber(ctx, number)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func (s storeSource) schedules(ctx context.Context, userID primitive.ObjectID, room, day string) ([]models.Schedule, error) {
+	return s.store.Schedules.FindForAccess(ctx, userID, room, day)
+}
+
+type snapshotSource struct {
+	snap *policy.Snapshot
+}
+
+func (s snapshotSource) user(_ context.Context, id primitive.ObjectID) (models.User, error) {
+	u, ok := s.snap.User(id)
+	if !ok {
+		return u, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (s snapshotSource) room(_ context.Context, number string) (*models.Room, error) {
+	room, ok := s.snap.Room(number)
+	if !ok {
+		return nil, nil
+	}
+	return &room, nil
+}
+
+func (s snapshotSource) schedules(_ context.Context, userID primitive.ObjectID, room, day string) ([]models.Schedule, error) {
+	return s.snap.Schedules(userID, room, day), nil
+}
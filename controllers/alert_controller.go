@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"access-control-system/alerts"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAlertLimit = 100
+	maxAlertLimit     = 1000
+)
+
+// Типы событий потока об изменении статуса оповещения
+const (
+	eventAlertAcknowledged = "alert_acknowledged"
+	eventAlertResolved     = "alert_resolved"
+)
+
+// AlertStatusRequest — комментарий к подтверждению или закрытию оповещения
+type AlertStatusRequest struct {
+	Note string `json:"note"`
+}
+
+// currentActor — email или телефон пользователя из токена, для
+// контроллеров дверей и сервисов — controller:<id> и service:<имя>
+func currentActor(c *gin.Context) string {
+	user, _ := c.Get("user")
+	u, _ := user.(models.User)
+	switch {
+	case u.Email != "":
+		return u.Email
+	case u.Phone != "":
+		return u.Phone
+	case c.GetString("device") != "":
+		return "controller:" + c.GetString("device")
+	case c.GetString("service") != "":
+		return "service:" + c.GetString("service")
+	}
+	return ""
+}
+
+// Оповещения, новые первыми. ?status=open|acknowledged|resolved,
+// ?severity=low|medium|high|critical, ?limit=
+func (h *Handler) GetAlerts(c *gin.Context) {
+	filter := repository.AlertFilter{
+		Status:   models.AlertStatus(c.Query("status")),
+		Severity: models.AlertSeverity(c.Query("severity")),
+		Limit:    defaultAlertLimit,
+	}
+	switch filter.Status {
+	case "", models.AlertOpen, models.AlertAcknowledged, models.AlertResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status должен быть open, acknowledged или resolved"})
+		return
+	}
+	if filter.Severity != "" && !filter.Severity.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "severity должен быть low, medium, high или critical"})
+		return
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAlertLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit должен быть от 1 до " + strconv.Itoa(maxAlertLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	list, err := h.store.Alerts.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения оповещений"})
+		return
+	}
+	if list == nil {
+		list = []models.Alert{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// Подтверждение оповещения: кто-то занимается им
+func (h *Handler) AcknowledgeAlert(c *gin.Context) {
+	h.changeAlertStatus(c, []models.AlertStatus{models.AlertOpen}, models.AlertAcknowledged,
+		eventAlertAcknowledged, "Оповещение подтверждено", "Подтвердить можно только открытое оповещение")
+}
+
+// Закрытие открытого или подтверждённого оповещения
+func (h *Handler) ResolveAlert(c *gin.Context) {
+	h.changeAlertStatus(c, []models.AlertStatus{models.AlertOpen, models.AlertAcknowledged}, models.AlertResolved,
+		eventAlertResolved, "Оповещение закрыто", "Оповещение уже закрыто")
+}
+
+func (h *Handler) changeAlertStatus(c *gin.Context, from []models.AlertStatus, to models.AlertStatus, eventType, done, conflict string) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID оповещения"})
+		return
+	}
+	var req AlertStatusRequest
+	// Тело необязательно
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	var alert models.Alert
+	err = h.audited(ctx, func(tx context.Context) error {
+		// Отсутствие оповещения обработает ChangeStatus
+		before, _ := h.store.Alerts.FindByID(tx, id)
+		var err error
+		alert, err = h.store.Alerts.ChangeStatus(tx, id, from, to, currentActor(c), strings.TrimSpace(req.Note), h.clock.Now())
+		if err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditAlert, alert.ID.Hex(), before, alert)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Оповещение не найдено"})
+		return
+	}
+	if errors.Is(err, repository.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось изменить статус оповещения"})
+		return
+	}
+
+	h.emit(alerts.AlertEvent(alert, eventType))
+	c.JSON(http.StatusOK, gin.H{"message": done, "data": alert})
+}
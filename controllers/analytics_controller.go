@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"access-control-system/analytics"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Наибольшее число интервалов в отчёте об использовании комнат
+const maxUtilizationBuckets = 2000
+
+// Наименьшая длина интервала, для оценки их числа в периоде
+var usageUnitLength = map[string]time.Duration{
+	repository.UsageHour:  time.Hour,
+	repository.UsageDay:   24 * time.Hour,
+	repository.UsageWeek:  7 * 24 * time.Hour,
+	repository.UsageMonth: 28 * 24 * time.Hour,
+}
+
+var utilizationColumns = []string{"group", "start", "scheduled_hours", "entries"}
+
+// Использование комнат за период: часы занятий по расписанию и
+// разрешённые проходы по интервалам ?bucket=hour|day|week|month
+// (по умолчанию day) для комнат, этажей или корпусов (?group_by=room|floor|building),
+// часы пик, комнаты без проходов и пересекающиеся занятия. По умолчанию —
+// прошлый календарный месяц. Фильтры: ?from=, ?to= (RFC 3339), ?building=,
+// ?floor=, ?room=. ?format=csv выгружает интервалы. Отчёт кэшируется
+// на analytics.cache_ttl
+func (h *Handler) GetUtilizationReport(c *gin.Context) {
+	q := analytics.Query{
+		Unit:     c.DefaultQuery("bucket", repository.UsageDay),
+		GroupBy:  c.DefaultQuery("group_by", analytics.GroupRoom),
+		Building: c.Query("building"),
+		Room:     c.Query("room"),
+		Location: config.Current.Location(),
+		RoomLocation: func(room models.Room) *time.Location {
+			return roomLocation(&room)
+		},
+	}
+	length, ok := usageUnitLength[q.Unit]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket должен быть hour, day, week или month"})
+		return
+	}
+	switch q.GroupBy {
+	case analytics.GroupRoom, analytics.GroupFloor, analytics.GroupBuilding:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by должен быть room, floor или building"})
+		return
+	}
+	if raw := c.Query("floor"); raw != "" {
+		floor, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "floor должен быть числом"})
+			return
+		}
+		q.Floor = &floor
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются форматы json и csv"})
+		return
+	}
+
+	if q.From, q.To, ok = reportPeriod(c); !ok {
+		return
+	}
+	switch {
+	case q.From.IsZero() && q.To.IsZero():
+		q.From, q.To = h.previousMonth()
+	case q.To.IsZero():
+		q.To = h.clock.Now()
+	case q.From.IsZero():
+		q.From = q.To.Add(-30 * 24 * time.Hour)
+	}
+	if q.To.Sub(q.From)/length > maxUtilizationBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Слишком много интервалов в периоде, не больше %d: сократите период или увеличьте bucket", maxUtilizationBuckets)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reportTimeout)
+	defer cancel()
+
+	report, err := h.analytics.Utilization(ctx, q)
+	if err != nil {
+		log.Printf("Ошибка при построении отчёта об использовании комнат: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сформировать отчёт"})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"data": report})
+		return
+	}
+
+	loc := config.Current.Location()
+	filename := fmt.Sprintf("utilization-%s-%s-%s-%s.csv", q.GroupBy, q.Unit,
+		q.From.In(loc).Format("2006-01-02"), q.To.In(loc).Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	_, err = writeCSV(exportWriter(c), utilizationColumns, sliceEach(report.Buckets), func(b analytics.Bucket) []string {
+		return []string{
+			b.Group, reportTime(b.Start), strconv.FormatFloat(b.ScheduledHours, 'f', -1, 64), strconv.FormatInt(b.Entries, 10),
+		}
+	})
+	if err != nil {
+		log.Printf("Ошибка при выгрузке отчёта об использовании комнат: %v", err)
+		c.Abort()
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"access-control-system/attendance"
+	"access-control-system/config"
+	"access-control-system/models"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Наибольший период отчёта о посещаемости: занятия периода держатся в памяти
+const maxAttendancePeriod = 366 * 24 * time.Hour
+
+// Сколько проходов вне расписания перечислить в отчёте, остальные только
+// учитываются в итогах
+const maxUnscheduledVisits = 10000
+
+var attendanceSummaryColumns = []string{
+	"user_id", "name", "subject", "week", "scheduled", "present", "late", "absent", "unscheduled", "late_minutes",
+}
+
+var attendanceSlotColumns = []string{
+	"schedule_id", "user_id", "name", "subject", "room", "week", "start", "end", "arrival", "source", "status", "late_minutes",
+}
+
+var attendanceVisitColumns = []string{"user_id", "name", "room", "week", "time", "source"}
+
+// Отчёт о посещаемости: занятия по расписанию, сопоставленные с
+// разрешёнными проходами, — вовремя, опоздание, неявка — и проходы вне
+// расписания, с итогами по преподавателю, предмету и неделе ISO.
+// По умолчанию — прошлый календарный месяц. Фильтры: ?from=, ?to=
+// (RFC 3339), ?user_id=, ?room=, ?subject=. ?format=csv выгружает
+// таблицу ?view=summary (по умолчанию), slots или unscheduled. Проходов
+// вне расписания перечисляется не больше maxUnscheduledVisits, их общее
+// число — в unscheduled_total и заголовке X-Unscheduled-Total выгрузки
+func (h *Handler) GetAttendanceReport(c *gin.Context) {
+	opts := attendance.Options{
+		Room:           c.Query("room"),
+		Subject:        c.Query("subject"),
+		Now:            h.clock.Now(),
+		LateAfter:      config.Current.Attendance.LateAfter,
+		EarlyWindow:    config.Current.Attendance.EarlyWindow,
+		MaxUnscheduled: maxUnscheduledVisits,
+	}
+	var err error
+	if opts.UserID, err = optionalObjectID(c.Query("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
+		return
+	}
+	var ok bool
+	if opts.From, opts.To, ok = reportPeriod(c); !ok {
+		return
+	}
+	switch {
+	case opts.From.IsZero() && opts.To.IsZero():
+		opts.From, opts.To = h.previousMonth()
+	case opts.To.IsZero():
+		opts.To = opts.Now
+	case opts.From.IsZero():
+		opts.From = opts.To.Add(-30 * 24 * time.Hour)
+	}
+	if opts.To.Sub(opts.From) > maxAttendancePeriod {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Период отчёта не может быть больше года"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	view := c.DefaultQuery("view", "summary")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются форматы json и csv"})
+		return
+	}
+	if view != "summary" && view != "slots" && view != "unscheduled" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view должен быть summary, slots или unscheduled"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reportTimeout)
+	defer cancel()
+
+	rooms, err := h.store.Rooms.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения комнат"})
+		return
+	}
+	byNumber := make(map[string]*models.Room, len(rooms))
+	for i := range rooms {
+		byNumber[rooms[i].RoomNumber] = &rooms[i]
+	}
+	opts.Location = func(room string) *time.Location {
+		return roomLocation(byNumber[room])
+	}
+
+	report, err := attendance.Build(ctx, h.store, opts)
+	if err != nil {
+		log.Printf("Ошибка при построении отчёта о посещаемости: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сформировать отчёт"})
+		return
+	}
+	if report.Truncated {
+		log.Printf("В отчёте о посещаемости перечислено %d из %d проходов вне расписания", len(report.Unscheduled), report.UnscheduledTotal)
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"data": report})
+		return
+	}
+
+	filename := fmt.Sprintf("attendance-%s-%s-%s.csv", view,
+		opts.From.In(config.Current.Location()).Format("2006-01-02"), opts.To.In(config.Current.Location()).Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("X-Unscheduled-Total", strconv.Itoa(report.UnscheduledTotal))
+	switch view {
+	case "summary":
+		_, err = writeCSV(exportWriter(c), attendanceSummaryColumns, sliceEach(report.Summary), func(r attendance.Row) []string {
+			return []string{
+				r.UserID.Hex(), r.Name, r.Subject, r.Week, strconv.Itoa(r.Scheduled), strconv.Itoa(r.Present),
+				strconv.Itoa(r.Late), strconv.Itoa(r.Absent), strconv.Itoa(r.Unscheduled), strconv.Itoa(r.LateMinutes),
+			}
+		})
+	case "slots":
+		_, err = writeCSV(exportWriter(c), attendanceSlotColumns, sliceEach(report.Slots), func(s attendance.Slot) []string {
+			var arrival string
+			if s.Arrival != nil {
+				arrival = reportTime(*s.Arrival)
+			}
+			return []string{
+				s.ScheduleID.Hex(), s.UserID.Hex(), s.Name, s.Subject, s.Room, s.Week, reportTime(s.Start),
+				reportTime(s.End), arrival, s.Source, s.Status, strconv.Itoa(s.LateMinutes),
+			}
+		})
+	default:
+		_, err = writeCSV(exportWriter(c), attendanceVisitColumns, sliceEach(report.Unscheduled), func(v attendance.Visit) []string {
+			return []string{v.UserID.Hex(), v.Name, v.Room, v.Week, reportTime(v.Time), v.Source}
+		})
+	}
+	if err != nil {
+		log.Printf("Ошибка при выгрузке отчёта о посещаемости: %v", err)
+		c.Abort()
+	}
+}
+
+// sliceEach перебирает срез для writeCSV
+func sliceEach[T any](items []T) func(func(T) error) error {
+	return func(fn func(T) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
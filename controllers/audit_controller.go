@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"access-control-system/audit"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// Попытки транзакции, если номер в цепочке аудита занял другой экземпляр
+	maxAuditAttempts = 5
+)
+
+// Сущности журнала аудита
+const (
+	auditUser     = "user"
+	auditRoom     = "room"
+	auditSchedule = "schedule"
+	auditWebhook  = "webhook"
+	auditAlert    = "alert"
+)
+
+// Автор изменений без токена и изменений фоновых задач
+const (
+	actorAnonymous = "anonymous"
+	actorSystem    = "system"
+)
+
+// audited выполняет изменение fn в транзакции вместе с записями журнала
+// аудита, которые fn делает через recordAudit: если запись не удалась,
+// изменение отменяется.
+//
+// auditMu упорядочивает аудируемые изменения только внутри экземпляра.
+// Если номер в цепочке одновременно занял другой экземпляр, транзакция
+// отменяется и fn выполняется заново, поэтому fn не должна накапливать
+// результаты между вызовами
+func (h *Handler) audited(ctx context.Context, fn func(tx context.Context) error) error {
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	for attempt := 1; ; attempt++ {
+		err := h.store.WithTransaction(ctx, fn)
+		if !errors.Is(err, audit.ErrSeqConflict) || attempt == maxAuditAttempts {
+			return err
+		}
+		log.Printf("Номер в журнале аудита занят другим экземпляром, повтор транзакции (%d)", attempt)
+	}
+}
+
+// recordAudit записывает изменение сущности, сделанное в запросе c, в
+// транзакции tx из audited. before или after равны nil при создании и удалении
+func (h *Handler) recordAudit(tx context.Context, c *gin.Context, action, entity, entityID string, before, after interface{}, extra ...models.AuditChange) error {
+	actor := currentActor(c)
+	if actor == "" {
+		actor = actorAnonymous
+	}
+	return h.appendAudit(tx, models.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Changes:   append(audit.Diff(before, after), extra...),
+		RequestID: c.GetString("request_id"),
+		SourceIP:  c.ClientIP(),
+	})
+}
+
+// affectedChange отмечает в записи об удалении связанные изменения
+// по выбранной политике
+func affectedChange(affected map[string]int64) models.AuditChange {
+	data, _ := json.Marshal(affected)
+	return models.AuditChange{Field: "affected", After: data}
+}
+
+func (h *Handler) appendAudit(tx context.Context, entry models.AuditEntry) error {
+	if _, err := h.audit.Record(tx, entry); err != nil {
+		log.Printf("Ошибка записи в журнал аудита (%s %s %s): %v", entry.Action, entry.Entity, entry.EntityID, err)
+		return err
+	}
+	return nil
+}
+
+// Журнал аудита, новые записи первыми. ?entity=, ?entity_id=, ?actor=,
+// ?action=create|update|delete, ?limit=
+func (h *Handler) GetAudit(c *gin.Context) {
+	filter := repository.AuditFilter{
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entity_id"),
+		Actor:    c.Query("actor"),
+		Action:   c.Query("action"),
+		Limit:    defaultAuditLimit,
+	}
+	switch filter.Action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action должен быть create, update или delete"})
+		return
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit должен быть от 1 до " + strconv.Itoa(maxAuditLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	list, err := h.store.Audit.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения журнала аудита"})
+		return
+	}
+	if list == nil {
+		list = []models.AuditEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// Проверка цепочки хэшей журнала аудита. Читает весь журнал, поэтому
+// не ограничена таймаутом обычного запроса
+func (h *Handler) VerifyAudit(c *gin.Context) {
+	report, err := h.audit.Verify(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить журнал аудита"})
+		return
+	}
+	if !report.Valid {
+		log.Printf("ВНИМАНИЕ: журнал аудита нарушен на записи %d: %s", report.BrokenAt, report.Reason)
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"access-control-system/audit"
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingAudit имитирует сбой записи в журнал аудита
+type failingAudit struct {
+	repository.AuditRepository
+}
+
+func (failingAudit) Insert(context.Context, *models.AuditEntry) error {
+	return errors.New("журнал аудита недоступен")
+}
+
+// Изменение и запись о нём в журнале аудита сохраняются вместе
+func TestAuditedChangeRollsBack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Current = testConfig(t)
+	clk := clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		failing bool
+		code    int
+		rooms   int
+		entries int
+	}{
+		{"запись не удалась", true, http.StatusInternalServerError, 0, 0},
+		{"запись сохранена", false, http.StatusOK, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repository.NewMemoryStore()
+			var repo repository.AuditRepository = store.Audit
+			if tt.failing {
+				repo = failingAudit{store.Audit}
+			}
+			h := NewHandler(Deps{Store: store, Clock: clk, Audit: audit.NewTrail(repo, clk)})
+			router := gin.New()
+			router.POST("/rooms", h.CreateRooms)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/rooms", strings.NewReader(`[{"room_number": "101"}]`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("POST /rooms = %d, ожидался %d: %s", w.Code, tt.code, w.Body)
+			}
+
+			ctx := context.Background()
+			rooms, err := store.Rooms.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := store.Audit.List(ctx, repository.AuditFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rooms) != tt.rooms || len(entries) != tt.entries {
+				t.Fatalf("комнат %d, записей аудита %d", len(rooms), len(entries))
+			}
+		})
+	}
+}
+
+// conflictingAudit отвечает конфликтом номера первые conflicts вставок,
+// как если бы номер занял другой экземпляр
+type conflictingAudit struct {
+	repository.AuditRepository
+	conflicts int
+	inserts   int
+}
+
+func (a *conflictingAudit) Insert(ctx context.Context, entry *models.AuditEntry) error {
+	a.inserts++
+	if a.inserts <= a.conflicts {
+		return &repository.DuplicateKeyError{Field: "seq"}
+	}
+	return a.AuditRepository.Insert(ctx, entry)
+}
+
+// При конфликте номера повторяется вся транзакция
+func TestAuditedRetriesSeqConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Current = testConfig(t)
+	clk := clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name      string
+		conflicts int
+		code      int
+		rooms     int
+	}{
+		{"конфликт разрешён повтором", 2, http.StatusOK, 1},
+		{"попытки исчерпаны", maxAuditAttempts, http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repository.NewMemoryStore()
+			repo := &conflictingAudit{AuditRepository: store.Audit, conflicts: tt.conflicts}
+			h := NewHandler(Deps{Store: store, Clock: clk, Audit: audit.NewTrail(repo, clk)})
+			router := gin.New()
+			router.POST("/rooms", h.CreateRooms)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/rooms", strings.NewReader(`[{"room_number": "101"}]`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("POST /rooms = %d, ожидался %d: %s", w.Code, tt.code, w.Body)
+			}
+			rooms, err := store.Rooms.List(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rooms) != tt.rooms || repo.inserts != min(tt.conflicts+1, maxAuditAttempts) {
+				t.Fatalf("комнат %d, вставок в журнал %d", len(rooms), repo.inserts)
+			}
+		})
+	}
+}
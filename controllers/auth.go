@@ -2,21 +2,22 @@ package controllers
 
 import (
 	"access-control-system/config"
+	"access-control-system/eventlog"
 	"access-control-system/models"
-	"context"
+	"access-control-system/repository"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 
-var jwtKey = []byte("my_secret_key")
-
 type Claims struct {
 	ID         string      `json:"id"`
 	Identifier string      `json:"identifier"` 
@@ -25,59 +26,140 @@ type Claims struct {
 }
 
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	log.Println("Началась обработка запроса POST /login")
 
-	var credentials struct {
-		Identifier string `json:"identifier"`
-		Password   string `json:"password"`
+	credentials, user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	// Временный пароль из импорта нужно сменить до получения токена
+	if user.MustChangePassword {
+		log.Printf("Вход с временным паролем без его смены: %s", credentials.Identifier)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                "Необходимо сменить временный пароль через POST /login/change-password",
+			"must_change_password": true,
+		})
+		return
+	}
+
+	h.issueToken(c, user, credentials.Identifier, "Вы успешно авторизовались")
+}
+
+// Смена пароля по логину и текущему паролю. Снимает требование сменить
+// временный пароль и возвращает токен, как при входе
+func (h *Handler) ChangePassword(c *gin.Context) {
+	log.Println("Началась обработка запроса POST /login/change-password")
+
+	credentials, user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	if credentials.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Новый пароль не может быть пустым"})
+		return
+	}
+	if credentials.NewPassword == credentials.Password {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Новый пароль должен отличаться от текущего"})
+		return
 	}
 
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(credentials.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Ошибка при хэшировании пароля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сменить пароль"})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	err = h.store.Users.Update(ctx, user.ID, map[string]interface{}{
+		"password":             string(hashedPassword),
+		"must_change_password": false,
+	})
+	if err != nil {
+		log.Printf("Ошибка при смене пароля пользователя %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сменить пароль"})
+		return
+	}
+	user.MustChangePassword = false
+
+	log.Printf("Пользователь %s сменил пароль", user.ID.Hex())
+	h.issueToken(c, user, credentials.Identifier, "Пароль успешно изменён")
+}
+
+type loginCredentials struct {
+	Identifier string `json:"identifier"`
+	Password   string `json:"password"`
+	// NewPassword — новый пароль при смене пароля
+	NewPassword string `json:"new_password"`
+}
+
+// authenticate проверяет логин и пароль из тела запроса и то, что вход
+// разрешён. При ошибке отправляет ответ и возвращает false
+func (h *Handler) authenticate(c *gin.Context) (loginCredentials, models.User, bool) {
+	var credentials loginCredentials
+
 	if err := c.ShouldBindJSON(&credentials); err != nil {
 		log.Printf("Ошибка при привязке JSON: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные"})
-		return
+		return credentials, models.User{}, false
 	}
 
-	collection := config.GetCollection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	var user models.User
-
-	filter := bson.M{
-		"$or": []bson.M{
-			{"email": credentials.Identifier},
-			{"phone": credentials.Identifier},
-		},
+	// Ищем как по введённому значению, так и по нормализованному:
+	// пользователи, созданные до нормализации, хранят контакты как есть
+	identifiers := []string{credentials.Identifier}
+	if strings.Contains(credentials.Identifier, "@") {
+		identifiers = append(identifiers, models.NormalizeEmail(credentials.Identifier))
+	} else if phone, err := models.NormalizePhone(credentials.Identifier); err == nil && phone != "" {
+		identifiers = append(identifiers, phone)
 	}
 
-	err := collection.FindOne(ctx, filter).Decode(&user)
+	user, err := h.store.Users.FindByIdentifier(ctx, identifiers)
 	if err != nil {
 		log.Printf("Ошибка при поиске пользователя: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
-		return
+		return credentials, models.User{}, false
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
 		log.Printf("Неверный пароль для пользователя %s: %v", credentials.Identifier, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
-		return
+		return credentials, models.User{}, false
+	}
+
+	if !user.IsActive() {
+		log.Printf("Попытка входа неактивного пользователя: %s", credentials.Identifier)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Учётная запись заблокирована или деактивирована"})
+		return credentials, models.User{}, false
 	}
 
 	if user.Role != models.RoleAdmin {
-		LogEvent("admin_access_denied",
-			"Пользователь "+user.FirstName+" "+user.SecondName+" с ролью '"+string(user.Role)+"' пытался авторизоваться как администратор",
-			nil)
+		h.LogEvent(models.Log{
+			EventType: eventlog.TypeAdminLoginDenied,
+			UserID:    user.ID,
+			Actor:     credentials.Identifier,
+			SourceIP:  c.ClientIP(),
+			Params:    map[string]string{"user_name": user.FirstName + " " + user.SecondName, "role": string(user.Role)},
+		})
 		log.Printf("Попытка авторизации неадминистратора: %s", credentials.Identifier)
 		c.JSON(http.StatusForbidden, gin.H{"error": "Доступ разрешен только администраторам"})
-		return
+		return credentials, models.User{}, false
 	}
+	return credentials, user, true
+}
 
-	expirationTime := time.Now().Add(72 * time.Hour)
+// issueToken отправляет JWT пользователя вместе с message
+func (h *Handler) issueToken(c *gin.Context, user models.User, identifier, message string) {
+	expirationTime := time.Now().Add(config.Current.JWT.TTL)
 	claims := &Claims{
 		ID:         user.ID.Hex(),
-		Identifier: credentials.Identifier,
+		Identifier: identifier,
 		Role:       user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
@@ -85,7 +167,7 @@ func Login(c *gin.Context) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := token.SignedString([]byte(config.Current.JWT.Secret))
 	if err != nil {
 		log.Printf("Ошибка при генерации JWT: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать токен"})
@@ -93,21 +175,51 @@ func Login(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Вы успешно авторизовались",
+		"message": message,
 		"token":   tokenString,
-		"user": gin.H{
-			"id":           user.ID.Hex(),
-			"key_id":       user.KeyID,
-			"first_name":   user.FirstName,
-			"second_name":  user.SecondName,
-			"email":        user.Email,
-			"phone":        user.Phone,
-			"role":         user.Role,
-			"access_rooms": user.AccessRooms,
-			"photos":       user.Photos,
-			"address":      user.Address,
-			"country":      user.Country,
-			"city":         user.City,
-		},
+		"user":    models.NewUserResponse(user),
 	})
 }
+
+// RequireActiveUser отклоняет токены пользователей, которые удалены,
+// заблокированы или деактивированы после входа, и подставляет роль из
+// текущей записи пользователя. Запросы без токена пропускает
+func (h *Handler) RequireActiveUser(c *gin.Context) {
+	value, exists := c.Get("user")
+	if !exists {
+		c.Next()
+		return
+	}
+	claimed, _ := value.(models.User)
+
+	user, err := h.tokenUser(claimed.ID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !user.IsActive()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Учётная запись заблокирована или деактивирована"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при проверке пользователя токена %s: %v", claimed.ID.Hex(), err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Не удалось проверить учётную запись"})
+		c.Abort()
+		return
+	}
+
+	claimed.Role = user.Role
+	c.Set("user", claimed)
+	c.Next()
+}
+
+// tokenUser читает пользователя из актуального кэша политик, а без него — из базы
+func (h *Handler) tokenUser(id primitive.ObjectID) (models.User, error) {
+	if snap, fresh := h.policy.Snapshot(); fresh {
+		if user, ok := snap.User(id); ok {
+			return user, nil
+		}
+		return models.User{}, repository.ErrNotFound
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+	return h.store.Users.FindByID(ctx, id)
+}
@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Политика обработки зависимых документов при удалении
+type DeletePolicy string
+
+const (
+	// Удаление запрещено, пока есть зависимые документы
+	PolicyBlock DeletePolicy = "block"
+	// Зависимые документы удаляются вместе с сущностью
+	PolicyCascade DeletePolicy = "cascade"
+	// Зависимые документы переносятся на другую сущность
+	PolicyReassign DeletePolicy = "reassign"
+)
+
+var (
+	errEntityNotFound      = errors.New("сущность не найдена")
+	errReassignTarget      = errors.New("некорректная цель переназначения")
+	errControllerAssigned  = errors.New("у целевой комнаты уже есть контроллер доступа")
+	errUnsupportedPolicy   = errors.New("недопустимая политика удаления")
+	errReassignTargetEmpty = errors.New("для политики reassign требуется параметр reassign_to")
+	errNotExpired          = errors.New("пользователь восстановлен или срок хранения ещё не истёк")
+)
+
+// dependencyError возвращается политикой block, если у сущности есть зависимые документы
+type dependencyError struct {
+	Dependents map[string]int64
+}
+
+func (e *dependencyError) Error() string {
+	return fmt.Sprintf("есть зависимые документы: %v", e.Dependents)
+}
+
+func parseDeletePolicy(raw, reassignTo string) (DeletePolicy, error) {
+	policy := DeletePolicy(raw)
+	switch policy {
+	case "":
+		return PolicyBlock, nil
+	case PolicyBlock, PolicyCascade:
+		return policy, nil
+	case PolicyReassign:
+		if reassignTo == "" {
+			return "", errReassignTargetEmpty
+		}
+		return policy, nil
+	default:
+		return "", errUnsupportedPolicy
+	}
+}
+
+// respondDeleteError переводит ошибки удаления с политикой в HTTP-ответ
+func respondDeleteError(c *gin.Context, err error) {
+	var depErr *dependencyError
+	switch {
+	case errors.As(err, &depErr):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Удаление заблокировано: есть зависимые документы",
+			"dependents": depErr.Dependents,
+		})
+	case errors.Is(err, errEntityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Объект не найден"})
+	case errors.Is(err, errReassignTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный объект для переназначения"})
+	case errors.Is(err, errControllerAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": "У целевой комнаты уже есть контроллер доступа"})
+	default:
+		log.Printf("Ошибка при удалении: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выполнить удаление"})
+	}
+}
+
+// deleteAudit записывает удаление сущности before в журнал аудита в
+// транзакции удаления
+type deleteAudit func(tx context.Context, before interface{}, affected map[string]int64) error
+
+// deleteUserWithPolicy удаляет пользователя и обрабатывает его расписания
+// согласно политике. Если задан deactivatedBefore, пользователь удаляется,
+// только если в момент удаления он деактивирован раньше этого времени,
+// иначе возвращается errNotExpired. Возвращает количество затронутых документов
+func (h *Handler) deleteUserWithPolicy(ctx context.Context, userID primitive.ObjectID, policy DeletePolicy, reassignTo string, deactivatedBefore time.Time, record deleteAudit) (map[string]int64, error) {
+	users := h.store.Users
+	schedules := h.store.Schedules
+	affected := map[string]int64{}
+
+	var user models.User
+	err := h.audited(ctx, func(tx context.Context) error {
+		var err error
+		user, err = users.FindByID(tx, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errEntityNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !deactivatedBefore.IsZero() && (user.EffectiveStatus() != models.StatusDeactivated ||
+			user.DeactivatedAt == nil || !user.DeactivatedAt.Before(deactivatedBefore)) {
+			return errNotExpired
+		}
+
+		switch policy {
+		case PolicyBlock:
+			n, err := schedules.CountByUser(tx, userID)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return &dependencyError{Dependents: map[string]int64{"schedules": n}}
+			}
+		case PolicyCascade:
+			n, err := schedules.DeleteByUser(tx, userID)
+			if err != nil {
+				return err
+			}
+			affected["schedules_deleted"] = n
+		case PolicyReassign:
+			targetID, err := primitive.ObjectIDFromHex(reassignTo)
+			if err != nil || targetID == userID {
+				return errReassignTarget
+			}
+			target, err := users.FindByID(tx, targetID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return errReassignTarget
+			}
+			if err != nil {
+				return err
+			}
+			if !target.IsActive() {
+				return errReassignTarget
+			}
+			n, err := schedules.ReassignUser(tx, userID, target)
+			if err != nil {
+				return err
+			}
+			affected["schedules_reassigned"] = n
+		}
+
+		if err := users.Delete(tx, userID); err != nil {
+			return err
+		}
+		affected["users_deleted"] = 1
+		return record(tx, user, affected)
+	})
+	if err != nil {
+		return affected, err
+	}
+
+	// Файлы фотографий не участвуют в транзакции и удаляются после неё
+	for _, photo := range user.Photos {
+		h.deleteStoredPhoto(ctx, userID, photo)
+	}
+	return affected, nil
+}
+
+// deleteRoomWithPolicy удаляет комнату и обрабатывает зависящие от неё
+// расписания, права доступа пользователей и привязку контроллера
+func (h *Handler) deleteRoomWithPolicy(ctx context.Context, roomID primitive.ObjectID, policy DeletePolicy, reassignTo string, record deleteAudit) (map[string]int64, error) {
+	rooms := h.store.Rooms
+	schedules := h.store.Schedules
+	users := h.store.Users
+	affected := map[string]int64{}
+
+	err := h.audited(ctx, func(tx context.Context) error {
+		room, err := rooms.FindByID(tx, roomID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errEntityNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		grantees, err := users.FindByRoomGrant(tx, room.RoomNumber)
+		if err != nil {
+			return err
+		}
+
+		switch policy {
+		case PolicyBlock:
+			n, err := schedules.CountByRoom(tx, room.RoomNumber)
+			if err != nil {
+				return err
+			}
+			dependents := map[string]int64{}
+			if n > 0 {
+				dependents["schedules"] = n
+			}
+			if len(grantees) > 0 {
+				dependents["access_grants"] = int64(len(grantees))
+			}
+			if room.AccessControllerID != "" {
+				dependents["access_controllers"] = 1
+			}
+			if len(dependents) > 0 {
+				return &dependencyError{Dependents: dependents}
+			}
+		case PolicyCascade:
+			n, err := schedules.DeleteByRoom(tx, room.RoomNumber)
+			if err != nil {
+				return err
+			}
+			affected["schedules_deleted"] = n
+			if err := h.replaceRoomGrant(tx, grantees, room.RoomNumber, ""); err != nil {
+				return err
+			}
+			affected["access_grants_revoked"] = int64(len(grantees))
+		case PolicyReassign:
+			target, err := rooms.FindByNumber(tx, reassignTo)
+			if errors.Is(err, repository.ErrNotFound) {
+				return errReassignTarget
+			}
+			if err != nil {
+				return err
+			}
+			if target.ID == room.ID {
+				return errReassignTarget
+			}
+			n, err := schedules.ReassignRoom(tx, room.RoomNumber, target.RoomNumber)
+			if err != nil {
+				return err
+			}
+			affected["schedules_reassigned"] = n
+			if err := h.replaceRoomGrant(tx, grantees, room.RoomNumber, target.RoomNumber); err != nil {
+				return err
+			}
+			affected["access_grants_reassigned"] = int64(len(grantees))
+			if room.AccessControllerID != "" {
+				if target.AccessControllerID != "" {
+					return errControllerAssigned
+				}
+				if err := rooms.SetAccessController(tx, target.ID, room.AccessControllerID); err != nil {
+					return err
+				}
+				affected["access_controllers_reassigned"] = 1
+			}
+		}
+
+		if err := rooms.Delete(tx, roomID); err != nil {
+			return err
+		}
+		affected["rooms_deleted"] = 1
+		return record(tx, room, affected)
+	})
+	return affected, err
+}
+
+// replaceRoomGrant заменяет номер комнаты в access_rooms пользователей на
+// replacement, либо удаляет его, если replacement пуст
+func (h *Handler) replaceRoomGrant(ctx context.Context, grantees []models.User, roomNumber, replacement string) error {
+	for _, u := range grantees {
+		seen := map[string]bool{}
+		updated := []string{}
+		for _, room := range models.SplitAccessRooms(u.AccessRooms) {
+			if room == roomNumber {
+				room = replacement
+			}
+			if room == "" || seen[room] {
+				continue
+			}
+			seen[room] = true
+			updated = append(updated, room)
+		}
+		if err := h.store.Users.SetAccessRooms(ctx, u.ID, updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
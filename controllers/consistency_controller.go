@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"access-control-system/models"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DanglingReference — документ, ссылающийся на несуществующую сущность
+type DanglingReference struct {
+	Collection string `json:"collection"`
+	ID         string `json:"id"`
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+}
+
+// Проверка ссылочной целостности между коллекциями.
+// Коллекции могут находиться в разных базах, поэтому $lookup не используется
+func (h *Handler) CheckConsistency(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	refs, err := h.findDanglingReferences(ctx)
+	if err != nil {
+		log.Printf("Ошибка при проверке целостности данных: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить целостность данных"})
+		return
+	}
+
+	summary := map[string]int{}
+	for _, ref := range refs {
+		summary[ref.Collection+"."+ref.Field]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"consistent": len(refs) == 0,
+		"summary":    summary,
+		"data":       refs,
+	})
+}
+
+func (h *Handler) findDanglingReferences(ctx context.Context) ([]DanglingReference, error) {
+	userIDs, err := h.store.Users.IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roomNumbers, err := h.store.Rooms.Numbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	knownUsers := map[primitive.ObjectID]bool{}
+	for _, id := range userIDs {
+		knownUsers[id] = true
+	}
+	existingRooms := map[string]bool{}
+	for _, r := range roomNumbers {
+		existingRooms[r] = true
+	}
+
+	refs := []DanglingReference{}
+
+	// Расписания без пользователя или без комнаты
+	schedules, err := h.store.Schedules.FindDangling(ctx, userIDs, roomNumbers)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schedules {
+		if !knownUsers[s.UserID] {
+			refs = append(refs, DanglingReference{"schedule", s.ID.Hex(), "user_id", s.UserID.Hex()})
+		}
+		if !existingRooms[s.RoomNumber] {
+			refs = append(refs, DanglingReference{"schedule", s.ID.Hex(), "room_number", s.RoomNumber})
+		}
+	}
+
+	// Права доступа на несуществующие комнаты
+	users, err := h.store.Users.List(ctx, allUsers)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		for _, room := range models.SplitAccessRooms(u.AccessRooms) {
+			if room != "*" && !existingRooms[room] {
+				refs = append(refs, DanglingReference{"users", u.ID.Hex(), "access_rooms", room})
+			}
+		}
+	}
+
+	// Логи, ссылающиеся на удалённых пользователей
+	logs, err := h.store.Logs.FindWithUnknownUser(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		refs = append(refs, DanglingReference{"logs", l.ID.Hex(), "user_id", l.UserID.Hex()})
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"access-control-system/bundle"
+	"access-control-system/config"
+	"access-control-system/eventlog"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Ключ, не принадлежащий ни одному пользователю
+	reasonUnknownKey = eventlog.TypeUnknownKey
+	// Контроллер принял решение, противоположное решению сервера
+	eventOfflineMismatch = eventlog.TypeOfflineMismatch
+	// Параметр события о загруженном проходе: время прохода на контроллере
+	paramOfflineTime = "offline_time"
+
+	maxOfflineUpload = 5000
+)
+
+var offlineEntries = metrics.Default.NewCounter("offline_access_entries_total",
+	"Записи о проходах, загруженные контроллерами, по результату сверки", "result")
+
+// Открытый ключ для проверки офлайн-пакетов
+func (h *Handler) GetBundlePublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"alg":        bundle.Algorithm,
+		"kid":        h.signer.KeyID(),
+		"public_key": base64.StdEncoding.EncodeToString(h.signer.PublicKey()),
+	})
+}
+
+// Подписанный пакет с ключами и интервалами доступа в комнаты контроллера
+// на ?days= дней вперёд
+func (h *Handler) GetOfflineBundle(c *gin.Context) {
+	controllerID := c.Param("controller_id")
+
+	days := config.Current.Bundles.DefaultDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > config.Current.Bundles.MaxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days должен быть от 1 до %d", config.Current.Bundles.MaxDays)})
+			return
+		}
+		days = n
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	rooms, err := h.store.Rooms.ListByAccessController(ctx, controllerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения комнат"})
+		return
+	}
+	if len(rooms) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контроллеру не назначена ни одна комната"})
+		return
+	}
+
+	served := map[string]bool{}
+	seen := map[string]bool{}
+	var users []models.User
+	for _, room := range rooms {
+		served[room.RoomNumber] = true
+		grantees, err := h.store.Users.FindByRoomGrant(ctx, room.RoomNumber)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения пользователей"})
+			return
+		}
+		for _, u := range grantees {
+			if !seen[u.ID.Hex()] {
+				seen[u.ID.Hex()] = true
+				users = append(users, u)
+			}
+		}
+	}
+
+	all, err := h.store.Schedules.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения расписания"})
+		return
+	}
+	var schedules []models.Schedule
+	for _, s := range all {
+		if served[s.RoomNumber] {
+			schedules = append(schedules, s)
+		}
+	}
+
+	policy := bundle.Compile(bundle.Input{
+		ControllerID: controllerID,
+		Rooms:        rooms,
+		Users:        users,
+		Schedules:    schedules,
+		From:         h.clock.Now(),
+		Days:         days,
+		Location: func(room models.Room) *time.Location {
+			return roomLocation(&room)
+		},
+		DefaultStalePolicy: config.Current.Access.StalePolicy,
+	})
+	signed, err := h.signer.Sign(policy)
+	if err != nil {
+		log.Printf("Ошибка подписи пакета для контроллера %s: %v", controllerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подписи пакета"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, signed)
+}
+
+type rejectedEntry struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Загрузка проходов, которые контроллер разрешил или запретил без связи
+// с сервером. Каждая запись сверяется с решением сервера по текущим данным
+func (h *Handler) UploadAccessLogs(c *gin.Context) {
+	controllerID := c.Param("controller_id")
+
+	var entries []models.AccessLog
+	if err := c.ShouldBindJSON(&entries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+		return
+	}
+	if len(entries) > maxOfflineUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Не более %d записей за один запрос", maxOfflineUpload)})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	rooms, err := h.store.Rooms.ListByAccessController(ctx, controllerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения комнат"})
+		return
+	}
+	served := map[string]bool{}
+	for _, room := range rooms {
+		served[room.RoomNumber] = true
+	}
+
+	now := h.clock.Now()
+	users := map[string]*models.User{}
+	var (
+		accepted []models.AccessLog
+		events   []*models.Log
+		rejected = []rejectedEntry{}
+	)
+	for i, e := range entries {
+		e.Status = strings.ToLower(strings.TrimSpace(e.Status))
+		if e.Status != models.AccessStatusGranted && e.Status != models.AccessStatusDenied {
+			rejected = append(rejected, rejectedEntry{Index: i, Error: "status должен быть granted или denied"})
+			continue
+		}
+		if !served[e.RoomID] {
+			rejected = append(rejected, rejectedEntry{Index: i, Error: "Комната " + e.RoomID + " не обслуживается контроллером"})
+			continue
+		}
+
+		user, ok := users[e.KeyID]
+		if !ok {
+			u, err := h.store.Users.FindByKeyID(ctx, e.KeyID)
+			switch {
+			case err == nil:
+				user = &u
+			case !errors.Is(err, repository.ErrNotFound):
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска пользователя"})
+				return
+			}
+			users[e.KeyID] = user
+		}
+
+		e.ID = primitive.NilObjectID
+		e.ControllerID = controllerID
+		e.UploadedAt = now
+
+		granted := false
+		var entry *models.Log
+		if user == nil {
+			e.ServerReason = reasonUnknownKey
+			entry = &models.Log{EventType: eventlog.TypeUnknownKey, Room: e.RoomID, Reason: reasonUnknownKey}
+		} else {
+			decision, err := h.evaluateAccess(ctx, user.ID, e.RoomID, e.AccessTime)
+			if err != nil {
+				respondAccessError(c, err)
+				return
+			}
+			e.UserID = user.ID
+			e.ServerReason = decision.Reason
+			granted = decision.Granted
+			entry = decision.logEntry
+		}
+		e.Mismatch = (e.Status == models.AccessStatusGranted) != granted
+
+		switch {
+		case e.Mismatch:
+			entry = &models.Log{
+				EventType: eventOfflineMismatch,
+				UserID:    e.UserID,
+				Room:      e.RoomID,
+				Reason:    e.ServerReason,
+				Params:    map[string]string{"controller_status": e.Status},
+			}
+		case e.Status == models.AccessStatusGranted:
+			entry = nil
+		}
+		if entry != nil {
+			entry.Timestamp = e.AccessTime
+			entry.ControllerID = controllerID
+			entry.SourceIP = c.ClientIP()
+			if entry.Params == nil {
+				entry.Params = map[string]string{}
+			}
+			entry.Params["key_id"] = e.KeyID
+			entry.Params[paramOfflineTime] = e.AccessTime.Format("2006-01-02 15:04:05 MST")
+		}
+
+		accepted = append(accepted, e)
+		events = append(events, entry)
+	}
+
+	inserted, err := h.store.Access.InsertMany(ctx, accepted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения записей"})
+		return
+	}
+
+	mismatches := 0
+	for _, i := range inserted {
+		if accepted[i].Mismatch {
+			mismatches++
+			offlineEntries.With("mismatch").Inc()
+		} else {
+			offlineEntries.With("stored").Inc()
+		}
+		if events[i] != nil {
+			h.LogEvent(*events[i])
+		}
+	}
+	duplicates := len(accepted) - len(inserted)
+	offlineEntries.With("duplicate").Add(float64(duplicates))
+	offlineEntries.With("rejected").Add(float64(len(rejected)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Записи приняты",
+		"received":   len(entries),
+		"stored":     len(inserted),
+		"duplicates": duplicates,
+		"mismatches": mismatches,
+		"rejected":   rejected,
+	})
+}
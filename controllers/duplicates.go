@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"access-control-system/repository"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// duplicateKeyField возвращает имя поля, нарушившего уникальный индекс
+func duplicateKeyField(err error) (string, bool) {
+	var dupErr *repository.DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		return "", false
+	}
+	return dupErr.Field, true
+}
+
+func respondDuplicateKey(c *gin.Context, field string) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error": fmt.Sprintf("Значение поля '%s' уже используется", field),
+		"field": field,
+	})
+}
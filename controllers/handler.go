@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"access-control-system/alerts"
+	"access-control-system/analytics"
+	"access-control-system/audit"
+	"access-control-system/bundle"
+	"access-control-system/clock"
+	"access-control-system/eventlog"
+	"access-control-system/policy"
+	"access-control-system/repository"
+	"access-control-system/retention"
+	"access-control-system/search"
+	"access-control-system/storage"
+	"access-control-system/stream"
+	"access-control-system/webhook"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deps — зависимости HTTP-обработчиков
+type Deps struct {
+	Store  *repository.Store
+	Photos storage.Store
+	// Clock — часы для решений о доступе
+	Clock  clock.Clock
+	Events *eventlog.Writer
+	// Policy — кэш для решений о доступе. Если nil, решения принимаются по базе
+	Policy *policy.Cache
+	// Signer подписывает офлайн-пакеты для контроллеров дверей
+	Signer *bundle.Signer
+	// Stream рассылает события журнала и решения о доступе в реальном времени
+	Stream *stream.Broker
+	// Webhooks доставляет события подписчикам webhook
+	Webhooks *webhook.Dispatcher
+	// Alerts проверяет решения о доступе по правилам оповещений
+	Alerts *alerts.Engine
+	// Audit записывает административные изменения. Если nil, журнал аудита не ведётся
+	Audit *audit.Trail
+	// Retention архивирует журнал событий и восстанавливает его из архива
+	Retention *retention.Pruner
+	// Analytics строит и кэширует отчёты об использовании комнат
+	Analytics *analytics.Service
+	// Search держит поисковый индекс пользователей, комнат и расписаний
+	Search *search.Service
+}
+
+// Handler объединяет HTTP-обработчики и их зависимости
+type Handler struct {
+	store     *repository.Store
+	photos    storage.Store
+	clock     clock.Clock
+	events    *eventlog.Writer
+	policy    *policy.Cache
+	signer    *bundle.Signer
+	stream    *stream.Broker
+	webhooks  *webhook.Dispatcher
+	alerts    *alerts.Engine
+	audit     *audit.Trail
+	retention *retention.Pruner
+	analytics *analytics.Service
+	search    *search.Service
+
+	// auditMu упорядочивает аудируемые изменения, см. audited
+	auditMu sync.Mutex
+}
+
+func NewHandler(deps Deps) *Handler {
+	return &Handler{
+		store:     deps.Store,
+		photos:    deps.Photos,
+		clock:     deps.Clock,
+		events:    deps.Events,
+		policy:    deps.Policy,
+		signer:    deps.Signer,
+		stream:    deps.Stream,
+		webhooks:  deps.Webhooks,
+		alerts:    deps.Alerts,
+		audit:     deps.Audit,
+		retention: deps.Retention,
+		analytics: deps.Analytics,
+		search:    deps.Search,
+	}
+}
+
+// emit публикует событие в поток и передаёт его подписчикам webhook.
+// Возвращает событие с присвоенным номером
+func (h *Handler) emit(e stream.Event) stream.Event {
+	e = h.stream.Publish(e)
+	h.webhooks.Notify(e)
+	return e
+}
+
+// RefreshPolicy запрашивает перезагрузку кэша политик после успешного
+// изменения пользователей, комнат или расписаний, если поток изменений
+// недоступен. Иначе кэш сам применяет изменённые документы
+func (h *Handler) RefreshPolicy(c *gin.Context) {
+	c.Next()
+	if c.Writer.Status() < http.StatusBadRequest {
+		h.policy.Invalidate()
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"access-control-system/buildinfo"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck — проверка готовности сервиса принимать запросы.
+// Ошибка Check означает, что сервис не готов
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthHandler обслуживает служебные эндпоинты для оркестратора
+type HealthHandler struct {
+	checks []ReadinessCheck
+}
+
+func NewHealthHandler(checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// Процесс жив и обрабатывает запросы
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Сервис готов: база доступна, индексы созданы, фоновые задачи запущены
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	ready := true
+	checks := gin.H{}
+	for _, check := range h.checks {
+		if err := check.Check(ctx); err != nil {
+			ready = false
+			checks[check.Name] = err.Error()
+			continue
+		}
+		checks[check.Name] = "ok"
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"ready": ready, "checks": checks})
+}
+
+func (h *HealthHandler) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
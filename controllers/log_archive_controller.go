@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/retention"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Сущность журнала аудита для восстановления архива журнала событий
+const auditLogArchive = "log_archive"
+
+// LogRestoreRequest — период и тип событий, возвращаемых из архива
+type LogRestoreRequest struct {
+	From      time.Time `json:"from" binding:"required"`
+	To        time.Time `json:"to" binding:"required"`
+	EventType string    `json:"event_type"`
+}
+
+// Файлы архива журнала событий с периодами записей в них
+func (h *Handler) GetLogArchives(c *gin.Context) {
+	archives, err := h.retention.Archives()
+	if errors.Is(err, retention.ErrNoArchive) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Архивирование журнала отключено: logs.archive_dir не задан"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения каталога архивов журнала: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить список архивов"})
+		return
+	}
+	if archives == nil {
+		archives = []retention.Archive{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": archives})
+}
+
+// Восстановление записей журнала за период из архива для расследования.
+// Восстановленные записи доступны в GET /logs и удаляются через
+// logs.restore_days без повторного архивирования
+func (h *Handler) RestoreLogs(c *gin.Context) {
+	var req LogRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+		return
+	}
+	if !req.From.Before(req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from должен быть раньше to"})
+		return
+	}
+
+	// Архив читается целиком, таймаут обычного запроса не подходит
+	res, err := h.retention.Restore(c.Request.Context(), req.From, req.To, req.EventType)
+	if errors.Is(err, retention.ErrNoArchive) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Архивирование журнала отключено: logs.archive_dir не задан"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка восстановления журнала из архива: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить записи из архива", "data": res})
+		return
+	}
+
+	if res.Restored > 0 {
+		period, _ := json.Marshal(req)
+		restored, _ := json.Marshal(res)
+		ctx, cancel := config.QueryContext()
+		defer cancel()
+		// Восстановление идёт частями и не помещается в одну транзакцию,
+		// поэтому о сбое записи в журнал аудита сообщается в ответе
+		err := h.audited(ctx, func(tx context.Context) error {
+			return h.recordAudit(tx, c, models.AuditCreate, auditLogArchive, "", nil, nil,
+				models.AuditChange{Field: "period", After: period},
+				models.AuditChange{Field: "result", After: restored})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Записи восстановлены, но не записаны в журнал аудита", "data": res})
+			return
+		}
+	}
+	log.Printf("Из архива журнала восстановлено %d записей (%d файлов)", res.Restored, res.Files)
+	c.JSON(http.StatusOK, gin.H{"message": "Записи восстановлены", "data": res})
+}
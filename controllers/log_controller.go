@@ -2,78 +2,202 @@ package controllers
 
 import (
 	"access-control-system/config"
+	"access-control-system/eventlog"
 	"access-control-system/models"
-	"context"
-	"log"
+	"access-control-system/repository"
+	"access-control-system/stream"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// LogRequest — событие журнала. Для типа из реестра message можно не
+// передавать: текст соберётся из полей при чтении
 type LogRequest struct {
-	EventType string `json:"event_type" binding:"required"`
-	Message   string `json:"message" binding:"required"`
-	UserID    string `json:"user_id"` 
+	EventType    string            `json:"event_type" binding:"required"`
+	Message      string            `json:"message"`
+	UserID       string            `json:"user_id"`
+	Room         string            `json:"room"`
+	ControllerID string            `json:"controller_id"`
+	Reason       string            `json:"reason"`
+	ScheduleID   string            `json:"schedule_id"`
+	Params       map[string]string `json:"params"`
 }
 
-func CreateLog(c *gin.Context) {
+func (h *Handler) CreateLog(c *gin.Context) {
 	var req LogRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
 		return
 	}
+	if _, known := eventlog.Lookup(req.EventType); !known && strings.TrimSpace(req.Message) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Для неизвестного типа события нужен message"})
+		return
+	}
 
-	var userID *primitive.ObjectID
-	if req.UserID != "" {
-		oid, err := primitive.ObjectIDFromHex(req.UserID)
+	entry := models.Log{
+		EventType:    req.EventType,
+		Message:      req.Message,
+		Actor:        currentActor(c),
+		Room:         req.Room,
+		ControllerID: req.ControllerID,
+		Reason:       req.Reason,
+		SourceIP:     c.ClientIP(),
+		Params:       req.Params,
+	}
+	// Контроллер пишет события только от своего имени
+	if device := c.GetString("device"); device != "" && req.ControllerID != "" && req.ControllerID != device {
+		c.JSON(http.StatusForbidden, gin.H{"error": "controller_id не совпадает с контроллером из X-Device-ID"})
+		return
+	}
+	var err error
+	if entry.UserID, err = optionalObjectID(req.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат UserID"})
+		return
+	}
+	if req.ScheduleID != "" {
+		id, err := primitive.ObjectIDFromHex(req.ScheduleID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат UserID"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат schedule_id"})
 			return
 		}
-		userID = &oid
+		entry.ScheduleID = &id
 	}
 
-	LogEvent(req.EventType, req.Message, userID)
+	h.LogEvent(entry)
 
 	c.JSON(http.StatusOK, gin.H{"status": "Лог записан"})
 }
 
-func LogEvent(eventType, message string, userID *primitive.ObjectID) {
-	logCollection := config.DB.Database("ENU").Collection("logs")
-	logEntry := models.Log{
-		ID:        primitive.NewObjectID(),
-		EventType: eventType,
-		Message:   message,
-		Timestamp: time.Now(),
+func optionalObjectID(hex string) (primitive.ObjectID, error) {
+	if hex == "" {
+		return primitive.NilObjectID, nil
 	}
+	return primitive.ObjectIDFromHex(hex)
+}
+
+// LogEvent ставит событие в очередь на запись в журнал и публикует его
+// в поток событий и подписчикам webhook
+func (h *Handler) LogEvent(entry models.Log) {
+	entry = h.writeLog(entry)
+	h.emit(stream.Event{
+		Kind:      stream.KindLog,
+		Type:      entry.EventType,
+		Message:   eventlog.Render(entry, eventlog.DefaultLang),
+		UserID:    hexOrEmpty(entry.UserID),
+		Room:      entry.Room,
+		Timestamp: entry.Timestamp,
+	})
+}
 
-	if userID != nil {
-		logEntry.UserID = *userID
+// writeLog ставит событие в очередь на запись, не публикуя его. Время
+// события — текущее, если вызывающий не задал своё, как для проходов,
+// загруженных контроллером
+func (h *Handler) writeLog(entry models.Log) models.Log {
+	entry.ID = primitive.NewObjectID()
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = h.clock.Now()
 	}
+	h.events.Write(entry)
+	return entry
+}
 
-	_, err := logCollection.InsertOne(context.TODO(), logEntry)
-	if err != nil {
-		log.Printf("Ошибка записи лога: %v", err)
+// logCursor — позиция записи в выдаче журнала: время в наносекундах и ID
+func logCursor(entry models.Log) string {
+	return strconv.FormatInt(entry.Timestamp.UnixNano(), 10) + "_" + entry.ID.Hex()
+}
+
+func parseLogCursor(cursor string, filter *repository.LogFilter) bool {
+	nanos, id, ok := strings.Cut(cursor, "_")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil {
+		return false
+	}
+	if filter.AfterID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return false
 	}
+	filter.AfterTime = time.Unix(0, n)
+	return true
 }
 
-func GetLogs(c *gin.Context) {
-	logCollection := config.DB.Database("ENU").Collection("logs")
-	cursor, err := logCollection.Find(context.TODO(), bson.M{})
+// Журнал событий постранично по возрастанию времени. Фильтры: ?event_type=,
+// ?user_id=, ?actor=, ?room=, ?controller_id=, ?reason=, ?from=, ?to=
+// (RFC 3339). ?limit= — размер страницы, ?cursor= — next_cursor из
+// предыдущего ответа. Текст событий на языке из ?lang= или Accept-Language (ru, en)
+func (h *Handler) GetLogs(c *gin.Context) {
+	filter := repository.LogFilter{
+		EventType:    c.Query("event_type"),
+		Actor:        c.Query("actor"),
+		Room:         c.Query("room"),
+		ControllerID: c.Query("controller_id"),
+		Reason:       c.Query("reason"),
+		Limit:        defaultLogLimit,
+	}
+	var err error
+	if filter.UserID, err = optionalObjectID(c.Query("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
+		return
+	}
+	for param, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(param); raw != "" {
+			if *t, err = time.Parse(time.RFC3339, raw); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " должен быть временем в формате RFC 3339"})
+				return
+			}
+		}
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLogLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit должен быть от 1 до " + strconv.Itoa(maxLogLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+	if cursor := c.Query("cursor"); cursor != "" && !parseLogCursor(cursor, &filter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный cursor"})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	// Лишняя запись показывает, есть ли следующая страница
+	pageSize := filter.Limit
+	filter.Limit++
+	logs, err := h.store.Logs.List(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения логов: " + err.Error()})
 		return
 	}
-	defer cursor.Close(context.TODO())
+	resp := gin.H{}
+	if len(logs) > pageSize {
+		logs = logs[:pageSize]
+		resp["next_cursor"] = logCursor(logs[pageSize-1])
+	}
 
-	var logs []models.Log
-	if err = cursor.All(context.TODO(), &logs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения логов: " + err.Error()})
-		return
+	lang := eventlog.Language(c.Query("lang"), c.GetHeader("Accept-Language"))
+	for i := range logs {
+		logs[i].Message = eventlog.Render(logs[i], lang)
 	}
+	if logs == nil {
+		logs = []models.Log{}
+	}
+	resp["data"] = logs
+	c.JSON(http.StatusOK, resp)
+}
 
-	c.JSON(http.StatusOK, gin.H{"data": logs})
+// Известные типы событий журнала с шаблонами текста
+func (h *Handler) GetLogEventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": eventlog.Types()})
 }
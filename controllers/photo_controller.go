@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"access-control-system/config"
+	"access-control-system/imaging"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	maxPhotoSize      = 5 << 20
+	maxPhotoDimension = 4096
+	thumbnailSize     = 256
+	photoURLTTL       = 15 * time.Minute
+
+	photoSizeOriginal  = "original"
+	photoSizeThumbnail = "thumb"
+)
+
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// PhotoResponse — фотография пользователя с подписанными ссылками для скачивания
+type PhotoResponse struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+func photoObjectName(userID primitive.ObjectID, photoID, size string) string {
+	if size == photoSizeThumbnail {
+		return fmt.Sprintf("users/%s/%s_thumb", userID.Hex(), photoID)
+	}
+	return fmt.Sprintf("users/%s/%s", userID.Hex(), photoID)
+}
+
+// Фотографии, загруженные через API, хранятся по идентификатору,
+// остальные элементы Photos — внешние ссылки, заданные вручную
+func isStoredPhoto(photo string) bool {
+	return primitive.IsValidObjectID(photo)
+}
+
+// Метка HKDF для ключа подписи ссылок, выводимого из ключа JWT
+const photoKeyInfo = "access-control-system/photo-url"
+
+// photoSigningKey — photos.url_secret или, если он не задан, отдельный ключ,
+// выведенный из ключа JWT: сам ключ JWT подписывает только токены
+func photoSigningKey() []byte {
+	if secret := config.Current.Photos.URLSecret; secret != "" {
+		return []byte(secret)
+	}
+	// HKDF-SHA256 выдаёт до 255 блоков, чтение одного блока не ошибается
+	key := make([]byte, sha256.Size)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, []byte(config.Current.JWT.Secret), nil, []byte(photoKeyInfo)), key)
+	return key
+}
+
+func photoSignature(userID, photoID, size string, expires int64) string {
+	mac := hmac.New(sha256.New, photoSigningKey())
+	fmt.Fprintf(mac, "%s/%s/%s/%d", userID, photoID, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedPhotoURL возвращает ссылку на фотографию, действительную photoURLTTL
+func signedPhotoURL(userID primitive.ObjectID, photoID, size string) string {
+	expires := time.Now().Add(photoURLTTL).Unix()
+	q := url.Values{}
+	q.Set("size", size)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", photoSignature(userID.Hex(), photoID, size, expires))
+	return fmt.Sprintf("/photos/%s/%s?%s", userID.Hex(), photoID, q.Encode())
+}
+
+func photoResponse(userID primitive.ObjectID, photo string) PhotoResponse {
+	if !isStoredPhoto(photo) {
+		return PhotoResponse{ID: photo, URL: photo}
+	}
+	return PhotoResponse{
+		ID:           photo,
+		URL:          signedPhotoURL(userID, photo, photoSizeOriginal),
+		ThumbnailURL: signedPhotoURL(userID, photo, photoSizeThumbnail),
+	}
+}
+
+// userPhotoURL возвращает ссылку на миниатюру первой фотографии пользователя
+// для отображения на посту охраны рядом с событием двери
+func userPhotoURL(user models.User) string {
+	if len(user.Photos) == 0 {
+		return ""
+	}
+	p := photoResponse(user.ID, user.Photos[0])
+	if p.ThumbnailURL != "" {
+		return p.ThumbnailURL
+	}
+	return p.URL
+}
+
+// deleteStoredPhoto удаляет файлы загруженной фотографии из хранилища.
+// Ошибки только логируются: ссылка из документа пользователя к этому моменту уже удалена
+func (h *Handler) deleteStoredPhoto(ctx context.Context, userID primitive.ObjectID, photoID string) {
+	if !isStoredPhoto(photoID) {
+		return
+	}
+	for _, size := range []string{photoSizeOriginal, photoSizeThumbnail} {
+		err := h.photos.Delete(ctx, photoObjectName(userID, photoID, size))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Ошибка при удалении файла фотографии %s: %v", photoID, err)
+		}
+	}
+}
+
+func (h *Handler) findUserForPhotos(ctx context.Context, c *gin.Context) (models.User, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return models.User{}, false
+	}
+
+	user, err := h.store.Users.FindByID(ctx, objID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return user, false
+	}
+	if err != nil {
+		log.Printf("Ошибка при поиске пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска пользователя"})
+		return user, false
+	}
+	return user, true
+}
+
+// Загрузка фотографии пользователя (multipart, поле "photo")
+func (h *Handler) UploadUserPhoto(c *gin.Context) {
+	log.Println("Началась обработка запроса POST /users/:id/photos")
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPhotoSize+1<<20)
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл фотографии не передан или превышает допустимый размер"})
+		return
+	}
+	if fileHeader.Size > maxPhotoSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Размер фотографии превышает %d МБ", maxPhotoSize>>20)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxPhotoSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	if len(data) > maxPhotoSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Размер фотографии превышает %d МБ", maxPhotoSize>>20)})
+		return
+	}
+
+	// Тип определяем по содержимому, а не по заголовку клиента
+	contentType := http.DetectContentType(data)
+	if !allowedPhotoTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Допустимы только изображения JPEG и PNG"})
+		return
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не является корректным изображением"})
+		return
+	}
+	if cfg.Width > maxPhotoDimension || cfg.Height > maxPhotoDimension {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Размеры изображения не должны превышать %dx%d", maxPhotoDimension, maxPhotoDimension)})
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не является корректным изображением"})
+		return
+	}
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, imaging.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 85}); err != nil {
+		log.Printf("Ошибка при создании миниатюры: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обработать изображение"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, ok := h.findUserForPhotos(ctx, c)
+	if !ok {
+		return
+	}
+
+	photoID := primitive.NewObjectID().Hex()
+	original := photoObjectName(user.ID, photoID, photoSizeOriginal)
+	thumbnail := photoObjectName(user.ID, photoID, photoSizeThumbnail)
+
+	if err := h.photos.Save(ctx, original, contentType, bytes.NewReader(data)); err != nil {
+		log.Printf("Ошибка при сохранении фотографии: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить фотографию"})
+		return
+	}
+	if err := h.photos.Save(ctx, thumbnail, "image/jpeg", &thumb); err != nil {
+		log.Printf("Ошибка при сохранении миниатюры: %v", err)
+		h.photos.Delete(ctx, original)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить фотографию"})
+		return
+	}
+
+	after := user
+	after.Photos = append(append([]string(nil), user.Photos...), photoID)
+	err = h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Users.AddPhoto(tx, user.ID, photoID); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditUser, user.ID.Hex(), user, after)
+	})
+	if err != nil {
+		log.Printf("Ошибка при обновлении пользователя: %v", err)
+		h.photos.Delete(ctx, original)
+		h.photos.Delete(ctx, thumbnail)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить фотографию"})
+		return
+	}
+
+	log.Printf("Фотография %s загружена для пользователя %s", photoID, user.ID.Hex())
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Фотография загружена",
+		"photo":   photoResponse(user.ID, photoID),
+	})
+}
+
+// Список фотографий пользователя с подписанными ссылками
+func (h *Handler) GetUserPhotos(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	user, ok := h.findUserForPhotos(ctx, c)
+	if !ok {
+		return
+	}
+
+	photos := make([]PhotoResponse, 0, len(user.Photos))
+	for _, p := range user.Photos {
+		photos = append(photos, photoResponse(user.ID, p))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": photos})
+}
+
+// Удаление фотографии пользователя
+func (h *Handler) DeleteUserPhoto(c *gin.Context) {
+	log.Println("Началась обработка запроса DELETE /users/:id/photos/:photo_id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	user, ok := h.findUserForPhotos(ctx, c)
+	if !ok {
+		return
+	}
+
+	photoID := c.Param("photo_id")
+	found := false
+	for _, p := range user.Photos {
+		if p == photoID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Фотография не найдена"})
+		return
+	}
+
+	after := user
+	after.Photos = nil
+	for _, p := range user.Photos {
+		if p != photoID {
+			after.Photos = append(after.Photos, p)
+		}
+	}
+	err := h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Users.RemovePhoto(tx, user.ID, photoID); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditUser, user.ID.Hex(), user, after)
+	})
+	if err != nil {
+		log.Printf("Ошибка при обновлении пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить фотографию"})
+		return
+	}
+
+	h.deleteStoredPhoto(ctx, user.ID, photoID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Фотография удалена"})
+}
+
+// Скачивание фотографии по подписанной ссылке
+func (h *Handler) DownloadPhoto(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	photoID := c.Param("photo_id")
+	if err != nil || !isStoredPhoto(photoID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Фотография не найдена"})
+		return
+	}
+
+	size := c.DefaultQuery("size", photoSizeOriginal)
+	if size != photoSizeOriginal && size != photoSizeThumbnail {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимый размер фотографии"})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна"})
+		return
+	}
+	expected := photoSignature(userID.Hex(), photoID, size, expires)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("sig"))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна"})
+		return
+	}
+	if time.Now().Unix() > expires {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Срок действия ссылки истёк"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reader, info, err := h.photos.Open(ctx, photoObjectName(userID, photoID, size))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Фотография не найдена"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при чтении фотографии %s: %v", photoID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить фотографию"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(photoURLTTL.Seconds())))
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, reader, nil)
+}
@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"access-control-system/config"
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/pdf"
+	"access-control-system/repository"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Выгрузка читает коллекцию курсором и может идти долго
+const reportTimeout = 10 * time.Minute
+
+// Строк в отчёте PDF с наибольшим числом отказов
+const topDeniedUsers = 20
+
+// Клиент, не принявший очередную порцию выгрузки за это время, отключается
+const exportWriteTimeout = time.Minute
+
+var logExportColumns = []string{
+	"timestamp", "event_type", "message", "user_id", "actor", "room",
+	"controller_id", "reason", "source_ip",
+}
+
+var accessExportColumns = []string{
+	"access_time", "status", "room_id", "key_id", "user_id", "user_name",
+	"controller_id", "server_reason", "mismatch", "uploaded_at",
+}
+
+// reportPeriod читает ?from= и ?to= в формате RFC 3339
+func reportPeriod(c *gin.Context) (from, to time.Time, ok bool) {
+	for param, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := c.Query(param); raw != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, raw); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " должен быть временем в формате RFC 3339"})
+				return from, to, false
+			}
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from должен быть раньше to"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// exportFormat читает ?format=csv|jsonl и выставляет заголовки ответа
+func exportFormat(c *gin.Context, name string) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются форматы csv и jsonl"})
+		return "", false
+	}
+	filename := name + "-" + time.Now().Format("2006-01-02") + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return format, true
+}
+
+// previousMonth — прошлый календарный месяц, период отчётов по умолчанию
+func (h *Handler) previousMonth() (from, to time.Time) {
+	loc := config.Current.Location()
+	now := h.clock.Now().In(loc)
+	to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	return to.AddDate(0, -1, 0), to
+}
+
+func reportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(config.Current.Location()).Format(time.RFC3339)
+}
+
+// Выгрузка журнала событий в CSV или JSONL. Фильтры как у GET /logs:
+// ?event_type=, ?user_id=, ?room=, ?from=, ?to=, язык текста — ?lang=
+func (h *Handler) ExportLogs(c *gin.Context) {
+	filter := repository.LogFilter{
+		EventType: c.Query("event_type"),
+		Room:      c.Query("room"),
+	}
+	var err error
+	if filter.UserID, err = optionalObjectID(c.Query("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
+		return
+	}
+	var ok bool
+	if filter.From, filter.To, ok = reportPeriod(c); !ok {
+		return
+	}
+	format, ok := exportFormat(c, "logs")
+	if !ok {
+		return
+	}
+	lang := eventlog.Language(c.Query("lang"), c.GetHeader("Accept-Language"))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reportTimeout)
+	defer cancel()
+
+	each := func(fn func(models.Log) error) error {
+		return h.store.Logs.Each(ctx, filter, func(entry models.Log) error {
+			entry.Message = eventlog.Render(entry, lang)
+			return fn(entry)
+		})
+	}
+	var count int
+	if format == "csv" {
+		count, err = writeCSV(exportWriter(c), logExportColumns, each, func(e models.Log) []string {
+			return []string{
+				reportTime(e.Timestamp), e.EventType, e.Message, hexOrEmpty(e.UserID), e.Actor, e.Room,
+				e.ControllerID, e.Reason, e.SourceIP,
+			}
+		})
+	} else {
+		count, err = writeJSONL(exportWriter(c), each)
+	}
+	if err != nil {
+		// Заголовки уже отправлены, поэтому остаётся только прервать ответ
+		log.Printf("Ошибка при выгрузке журнала событий: %v", err)
+		c.Abort()
+		return
+	}
+	log.Printf("Выгружено записей журнала событий: %d", count)
+}
+
+// Выгрузка проходов, загруженных контроллерами дверей, в CSV или JSONL.
+// Фильтры: ?room=, ?user_id=, ?status=granted|denied, ?controller_id=, ?from=, ?to=
+func (h *Handler) ExportAccessLogs(c *gin.Context) {
+	filter, ok := accessLogFilter(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c, "access")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reportTimeout)
+	defer cancel()
+
+	each := func(fn func(models.AccessLog) error) error {
+		return h.store.Access.Each(ctx, filter, fn)
+	}
+	var (
+		count int
+		err   error
+	)
+	if format == "csv" {
+		names := h.userNames(ctx)
+		count, err = writeCSV(exportWriter(c), accessExportColumns, each, func(a models.AccessLog) []string {
+			return []string{
+				reportTime(a.AccessTime), a.Status, a.RoomID, a.KeyID, hexOrEmpty(a.UserID), names(a.UserID),
+				a.ControllerID, a.ServerReason, strconv.FormatBool(a.Mismatch), reportTime(a.UploadedAt),
+			}
+		})
+	} else {
+		count, err = writeJSONL(exportWriter(c), each)
+	}
+	if err != nil {
+		log.Printf("Ошибка при выгрузке проходов: %v", err)
+		c.Abort()
+		return
+	}
+	log.Printf("Выгружено проходов: %d", count)
+}
+
+func accessLogFilter(c *gin.Context) (repository.AccessLogFilter, bool) {
+	filter := repository.AccessLogFilter{
+		RoomID:       c.Query("room"),
+		ControllerID: c.Query("controller_id"),
+		Status:       c.Query("status"),
+	}
+	switch filter.Status {
+	case "", models.AccessStatusGranted, models.AccessStatusDenied:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status должен быть granted или denied"})
+		return filter, false
+	}
+	var err error
+	if filter.UserID, err = optionalObjectID(c.Query("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат user_id"})
+		return filter, false
+	}
+	var ok bool
+	filter.From, filter.To, ok = reportPeriod(c)
+	return filter, ok
+}
+
+// userNames возвращает функцию имени пользователя по ID. Каждый
+// пользователь запрашивается из базы один раз
+func (h *Handler) userNames(ctx context.Context) func(primitive.ObjectID) string {
+	names := map[primitive.ObjectID]string{}
+	return func(id primitive.ObjectID) string {
+		if id.IsZero() {
+			return ""
+		}
+		name, ok := names[id]
+		if !ok {
+			if u, err := h.store.Users.FindByID(ctx, id); err == nil {
+				name = u.FirstName + " " + u.SecondName
+			}
+			names[id] = name
+		}
+		return name
+	}
+}
+
+// exportWriter продлевает срок записи сервера перед каждой порцией ответа:
+// выгрузка идёт дольше server.write_timeout, а зависший клиент отключается
+func exportWriter(c *gin.Context) io.Writer {
+	return deadlineWriter{w: c.Writer, rc: http.NewResponseController(c.Writer)}
+}
+
+type deadlineWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (d deadlineWriter) Write(p []byte) (int, error) {
+	_ = d.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return d.w.Write(p)
+}
+
+func writeCSV[T any](w io.Writer, columns []string, each func(func(T) error) error, row func(T) []string) (int, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := each(func(item T) error {
+		if err := writer.Write(csvSafe(row(item))); err != nil {
+			return err
+		}
+		count++
+		if count%500 == 0 {
+			writer.Flush()
+		}
+		return nil
+	})
+	writer.Flush()
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Error()
+}
+
+// Первые символы ячейки, с которых электронные таблицы начинают формулу
+const csvFormulaChars = "=+-@"
+
+// csvSafe экранирует ячейки, которые Excel и LibreOffice выполнили бы как
+// формулу: такие ячейки получают префикс '. Изменяет row на месте
+func csvSafe(row []string) []string {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune(csvFormulaChars, rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return row
+}
+
+// csvUnescape снимает префикс, добавленный csvSafe
+func csvUnescape(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaChars, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func writeJSONL[T any](w io.Writer, each func(func(T) error) error) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	err := each(func(item T) error {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// accessCounts — проходы по итоговому решению
+type accessCounts struct {
+	granted, denied, mismatches int
+}
+
+func (a *accessCounts) add(granted, mismatch bool) {
+	if granted {
+		a.granted++
+	} else {
+		a.denied++
+	}
+	if mismatch {
+		a.mismatches++
+	}
+}
+
+// serverDecisions — события журнала о решениях сервера на запрос прохода:
+// true — доступ разрешён
+var serverDecisions = map[string]bool{
+	reasonGranted:         true,
+	reasonStaleOpen:       true,
+	reasonInactiveUser:    false,
+	reasonNoRoomAccess:    false,
+	reasonNoSchedule:      false,
+	reasonOutsideSchedule: false,
+	reasonStaleClosed:     false,
+}
+
+// deniedKey — кому отказано: пользователь или, если ключ ни за кем не
+// закреплён, сам ключ
+type deniedKey struct {
+	userID primitive.ObjectID
+	keyID  string
+}
+
+// Сводный отчёт PDF о проходах и событиях журнала за период. По умолчанию —
+// прошлый календарный месяц. Фильтры: ?from=, ?to=, ?room=
+func (h *Handler) AccessReportPDF(c *gin.Context) {
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+	loc := config.Current.Location()
+	if from.IsZero() && to.IsZero() {
+		from, to = h.previousMonth()
+	}
+	room := c.Query("room")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), reportTimeout)
+	defer cancel()
+
+	// Отчёт собирается из счётчиков, записи не накапливаются. Проходы —
+	// решения сервера из журнала событий и решения контроллеров, принятые
+	// без связи с сервером и загруженные позже
+	var total, online, offline accessCounts
+	byRoom := map[string]*accessCounts{}
+	byDay := map[string]*accessCounts{}
+	deniedBy := map[deniedKey]int{}
+	count := func(source *accessCounts, roomID string, at time.Time, granted, mismatch bool, denied deniedKey) {
+		source.add(granted, mismatch)
+		total.add(granted, mismatch)
+		for key, m := range map[string]map[string]*accessCounts{roomID: byRoom, at.In(loc).Format("2006-01-02"): byDay} {
+			if m[key] == nil {
+				m[key] = &accessCounts{}
+			}
+			m[key].add(granted, mismatch)
+		}
+		if !granted {
+			deniedBy[denied]++
+		}
+	}
+	err := h.store.Access.Each(ctx, repository.AccessLogFilter{RoomID: room, From: from, To: to}, func(a models.AccessLog) error {
+		denied := deniedKey{userID: a.UserID}
+		if a.UserID.IsZero() {
+			denied.keyID = a.KeyID
+		}
+		count(&offline, a.RoomID, a.AccessTime, a.Status == models.AccessStatusGranted, a.Mismatch, denied)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Ошибка при подсчёте проходов для отчёта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сформировать отчёт"})
+		return
+	}
+	byType := map[string]int{}
+	logTotal := 0
+	err = h.store.Logs.Each(ctx, repository.LogFilter{Room: room, From: from, To: to}, func(entry models.Log) error {
+		byType[entry.EventType]++
+		logTotal++
+		// Отказы из загрузок контроллеров уже посчитаны по проходам
+		if granted, ok := serverDecisions[entry.EventType]; ok && entry.Params[paramOfflineTime] == "" {
+			count(&online, entry.Room, entry.Timestamp, granted, false, deniedKey{userID: entry.UserID})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Ошибка при подсчёте событий для отчёта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сформировать отчёт"})
+		return
+	}
+
+	doc := pdf.New("Отчёт о доступе в помещения")
+	if room == "" {
+		room = "все"
+	}
+	doc.Text(fmt.Sprintf("Период: %s — %s (%s)", from.In(loc).Format("02.01.2006 15:04"), to.In(loc).Format("02.01.2006 15:04"), loc))
+	doc.Text("Комната: " + room)
+	doc.Text("Сформирован: " + h.clock.Now().In(loc).Format("02.01.2006 15:04"))
+	doc.Text("Проходы — решения сервера на запросы доступа и решения контроллеров дверей, принятые без связи с сервером и загруженные позже; события — записи журнала событий.")
+
+	doc.Heading("Итоги")
+	doc.Table([]string{"Показатель", "Значение"}, [][]string{
+		{"Проходов всего", strconv.Itoa(total.granted + total.denied)},
+		{"Разрешено", strconv.Itoa(total.granted)},
+		{"Отказано", strconv.Itoa(total.denied)},
+		{"Решений сервера", strconv.Itoa(online.granted + online.denied)},
+		{"Загружено контроллерами", strconv.Itoa(offline.granted + offline.denied)},
+		{"Расхождений с сервером", strconv.Itoa(total.mismatches)},
+		{"Событий журнала", strconv.Itoa(logTotal)},
+	})
+
+	countRows := func(m map[string]*accessCounts) [][]string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		rows := make([][]string, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, []string{k, strconv.Itoa(m[k].granted), strconv.Itoa(m[k].denied), strconv.Itoa(m[k].mismatches)})
+		}
+		return rows
+	}
+	doc.Heading("По комнатам")
+	doc.Table([]string{"Комната", "Разрешено", "Отказано", "Расхождения"}, countRows(byRoom))
+	doc.Heading("По дням")
+	doc.Table([]string{"Дата", "Разрешено", "Отказано", "Расхождения"}, countRows(byDay))
+
+	doc.Heading("События журнала по типам")
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return byType[types[i]] > byType[types[j]] })
+	var typeRows [][]string
+	for _, t := range types {
+		description := ""
+		if et, ok := eventlog.Lookup(t); ok {
+			description = et.Description
+		}
+		typeRows = append(typeRows, []string{t, description, strconv.Itoa(byType[t])})
+	}
+	doc.Table([]string{"Тип", "Описание", "Количество"}, typeRows)
+
+	doc.Heading("Больше всего отказов")
+	keys := make([]deniedKey, 0, len(deniedBy))
+	for k := range deniedBy {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return deniedBy[keys[i]] > deniedBy[keys[j]] })
+	if len(keys) > topDeniedUsers {
+		keys = keys[:topDeniedUsers]
+	}
+	names := h.userNames(ctx)
+	var deniedRows [][]string
+	for _, k := range keys {
+		deniedRows = append(deniedRows, []string{hexOrEmpty(k.userID), names(k.userID), k.keyID, strconv.Itoa(deniedBy[k])})
+	}
+	doc.Table([]string{"ID пользователя", "Пользователь", "Незакреплённый ключ", "Отказов"}, deniedRows)
+
+	filename := fmt.Sprintf("access-report-%s-%s.pdf", from.In(loc).Format("2006-01-02"), to.In(loc).Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", "application/pdf")
+	c.Status(http.StatusOK)
+	if _, err := doc.WriteTo(exportWriter(c)); err != nil {
+		log.Printf("Ошибка при отправке отчёта: %v", err)
+	}
+}
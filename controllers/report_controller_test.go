@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"access-control-system/models"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Выгрузка, идущая дольше server.write_timeout, доходит до клиента целиком
+func TestExportWriterExtendsDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/export", func(c *gin.Context) {
+		w := exportWriter(c)
+		for i := 0; i < 3; i++ {
+			time.Sleep(150 * time.Millisecond)
+			if _, err := io.WriteString(w, "строка\n"); err != nil {
+				t.Errorf("запись: %v", err)
+				return
+			}
+			c.Writer.Flush()
+		}
+	})
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 200 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ответ оборван: %v", err)
+	}
+	if want := "строка\nстрока\nстрока\n"; string(body) != want {
+		t.Fatalf("тело = %q, ожидалось %q", body, want)
+	}
+}
+
+// Ячейки, похожие на формулу, выгружаются с префиксом ' и читаются импортом как были
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	users := []models.User{{
+		FirstName: "=HYPERLINK(\"http://evil\")", SecondName: "@SUM(A1)", Phone: "+79001234567",
+		Address: "-1+1", City: "Москва", Country: "Россия", Role: models.RoleTeacher,
+	}}
+	_, err := writeUsersCSV(&buf, func(fn func(models.User) error) error {
+		for _, u := range users {
+			if err := fn(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("CSV: %d строк, %v", len(records), err)
+	}
+	for i, cell := range records[1] {
+		if cell != "" && strings.ContainsRune(csvFormulaChars, rune(cell[0])) {
+			t.Errorf("колонка %s = %q не экранирована", userExportColumns[i], cell)
+		}
+	}
+
+	requests, err := parseUsersCSV(bytes.NewReader(buf.Bytes()))
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("импорт: %d строк, %v", len(requests), err)
+	}
+	got, want := requests[0], users[0]
+	if got.FirstName != want.FirstName || got.SecondName != want.SecondName || got.Phone != want.Phone ||
+		got.Address != want.Address || got.City != want.City || got.Country != want.Country {
+		t.Fatalf("импорт = %+v, ожидалось %+v", got, want)
+	}
+}
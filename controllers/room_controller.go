@@ -6,13 +6,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateRooms(c *gin.Context) {
+func (h *Handler) CreateRooms(c *gin.Context) {
 	var rooms []models.Room // Слайс для нескольких комнат
 
 	if err := c.ShouldBindJSON(&rooms); err != nil {
@@ -26,19 +27,38 @@ func CreateRooms(c *gin.Context) {
 
 	for i := range rooms {
 		rooms[i].ID = primitive.NewObjectID()
+		rooms[i].RoomNumber = strings.TrimSpace(rooms[i].RoomNumber)
+		rooms[i].Building = strings.TrimSpace(rooms[i].Building)
+		if tz := rooms[i].Timezone; tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неизвестный часовой пояс '%s' у комнаты %s", tz, rooms[i].RoomNumber)})
+				return
+			}
+		}
+		if p := rooms[i].StalePolicy; p != "" && !p.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Неизвестная политика '%s' у комнаты %s: допустимы fail_closed и fail_open", p, rooms[i].RoomNumber)})
+			return
+		}
 	}
 
-	if config.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Нет соединения с базой данных"})
-		return
-	}
+	ctx, cancel := config.QueryContext()
+	defer cancel()
 
-	var interfaceRooms []interface{}
-	for _, room := range rooms {
-		interfaceRooms = append(interfaceRooms, room)
+	err := h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Rooms.CreateMany(tx, rooms); err != nil {
+			return err
+		}
+		for _, room := range rooms {
+			if err := h.recordAudit(tx, c, models.AuditCreate, auditRoom, room.ID.Hex(), nil, room); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if field, ok := duplicateKeyField(err); ok {
+		respondDuplicateKey(c, field)
+		return
 	}
-
-	_, err := config.DB.Database("ENU").Collection("rooms").InsertMany(context.TODO(), interfaceRooms)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Ошибка вставки в MongoDB",
@@ -53,13 +73,11 @@ func CreateRooms(c *gin.Context) {
 	})
 }
 
-func GetRooms(c *gin.Context) {
-	if config.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Нет соединения с базой данных"})
-		return
-	}
+func (h *Handler) GetRooms(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
 
-	cursor, err := config.DB.Database("ENU").Collection("rooms").Find(context.TODO(), bson.D{})
+	rooms, err := h.store.Rooms.List(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Ошибка получения данных из MongoDB",
@@ -67,19 +85,40 @@ func GetRooms(c *gin.Context) {
 		})
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var rooms []models.Room
-	if err := cursor.All(context.TODO(), &rooms); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Ошибка при преобразовании данных",
-			"details": err.Error(),
-		})
-		return
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Список комнат",
 		"data":    rooms,
 	})
 }
+
+// Удаление комнаты. Параметр policy определяет судьбу расписаний, прав доступа
+// и контроллера: block (по умолчанию), cascade или reassign (reassign_to=<номер комнаты>)
+func (h *Handler) DeleteRoom(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID комнаты"})
+		return
+	}
+
+	reassignTo := c.Query("reassign_to")
+	policy, err := parseDeletePolicy(c.Query("policy"), reassignTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	affected, err := h.deleteRoomWithPolicy(ctx, objID, policy, reassignTo,
+		func(tx context.Context, before interface{}, affected map[string]int64) error {
+			return h.recordAudit(tx, c, models.AuditDelete, auditRoom, objID.Hex(), before, nil, affectedChange(affected))
+		})
+	if err != nil {
+		respondDeleteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Комната удалена", "affected": affected})
+}
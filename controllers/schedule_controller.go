@@ -3,27 +3,21 @@ package controllers
 import (
 	"access-control-system/config"
 	"access-control-system/models"
+	"access-control-system/repository"
 	"context"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Поиск ID пользователя по имени и фамилии
-func getUserIDByName(firstName, secondName string) (primitive.ObjectID, error) {
-	var user models.User
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (h *Handler) getUserIDByName(firstName, secondName string) (primitive.ObjectID, error) {
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	collection := config.DB.Database("ENU").Collection("users")
-	filter := bson.M{"first_name": firstName, "second_name": secondName}
-
-	err := collection.FindOne(ctx, filter).Decode(&user)
+	user, err := h.store.Users.FindByName(ctx, firstName, secondName)
 	if err != nil {
 		return primitive.NilObjectID, err
 	}
@@ -32,7 +26,7 @@ func getUserIDByName(firstName, secondName string) (primitive.ObjectID, error) {
 }
 
 // Создание расписания
-func CreateSchedule(c *gin.Context) {
+func (h *Handler) CreateSchedule(c *gin.Context) {
 	var schedule models.Schedule
 	if err := c.ShouldBindJSON(&schedule); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -40,9 +34,9 @@ func CreateSchedule(c *gin.Context) {
 	}
 
 	// Поиск пользователя по имени и фамилии для заполнения UserID
-	userID, err := getUserIDByName(schedule.FirstName, schedule.SecondName)
+	userID, err := h.getUserIDByName(schedule.FirstName, schedule.SecondName)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
 			return
 		}
@@ -53,8 +47,15 @@ func CreateSchedule(c *gin.Context) {
 	schedule.UserID = userID
 	schedule.ID = primitive.NewObjectID()
 
-	collection := config.DB.Database("ENU").Collection("schedule")
-	_, err = collection.InsertOne(context.TODO(), schedule)
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	err = h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Schedules.Create(tx, &schedule); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditCreate, auditSchedule, schedule.ID.Hex(), nil, schedule)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -64,30 +65,21 @@ func CreateSchedule(c *gin.Context) {
 }
 
 // Получение списка расписаний
-func GetSchedules(c *gin.Context) {
-	if config.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Нет соединения с базой данных"})
-		return
-	}
+func (h *Handler) GetSchedules(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
 
-	cursor, err := config.DB.Database("ENU").Collection("schedule").Find(context.TODO(), bson.D{})
+	schedules, err := h.store.Schedules.List(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения данных", "details": err.Error()})
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var schedules []models.Schedule
-	if err := cursor.All(context.TODO(), &schedules); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при преобразовании данных", "details": err.Error()})
-		return
-	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Список расписаний", "data": schedules})
 }
 
 // Обновление расписания (динамическое обновление только переданных полей)
-func UpdateSchedule(c *gin.Context) {
+func (h *Handler) UpdateSchedule(c *gin.Context) {
 	id := c.Param("id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -101,7 +93,7 @@ func UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	updateData := bson.M{}
+	updateData := map[string]interface{}{}
 
 	// Обновляем поля, если они переданы и не пустые
 	if updatedSchedule.FirstName != "" {
@@ -112,7 +104,7 @@ func UpdateSchedule(c *gin.Context) {
 	}
 	// Если оба поля заданы, пробуем обновить UserID
 	if updatedSchedule.FirstName != "" && updatedSchedule.SecondName != "" {
-		if userID, err := getUserIDByName(updatedSchedule.FirstName, updatedSchedule.SecondName); err == nil {
+		if userID, err := h.getUserIDByName(updatedSchedule.FirstName, updatedSchedule.SecondName); err == nil {
 			updateData["user_id"] = userID
 		}
 	}
@@ -137,17 +129,29 @@ func UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	collection := config.DB.Database("ENU").Collection("schedule")
-	update := bson.M{"$set": updateData}
+	ctx, cancel := config.QueryContext()
+	defer cancel()
 
-	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить расписание"})
+	err = h.audited(ctx, func(tx context.Context) error {
+		before, err := h.store.Schedules.FindByID(tx, objID)
+		if err != nil {
+			return err
+		}
+		if err := h.store.Schedules.Update(tx, objID, updateData); err != nil {
+			return err
+		}
+		after, err := h.store.Schedules.FindByID(tx, objID)
+		if err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditSchedule, id, before, after)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Расписание не найдено"})
 		return
 	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Расписание не найдено"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить расписание"})
 		return
 	}
 
@@ -155,7 +159,7 @@ func UpdateSchedule(c *gin.Context) {
 }
 
 // Удаление расписания
-func DeleteSchedule(c *gin.Context) {
+func (h *Handler) DeleteSchedule(c *gin.Context) {
 	id := c.Param("id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -163,17 +167,27 @@ func DeleteSchedule(c *gin.Context) {
 		return
 	}
 
-	collection := config.DB.Database("ENU").Collection("schedule")
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить расписание"})
-		return
-	}
+	ctx, cancel := config.QueryContext()
+	defer cancel()
 
-	if result.DeletedCount == 0 {
+	err = h.audited(ctx, func(tx context.Context) error {
+		before, err := h.store.Schedules.FindByID(tx, objID)
+		if err != nil {
+			return err
+		}
+		if err := h.store.Schedules.Delete(tx, objID); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditDelete, auditSchedule, id, before, nil)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Расписание не найдено"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить расписание"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Расписание успешно удалено"})
 }
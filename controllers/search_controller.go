@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"access-control-system/search"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Поиск по пользователям, комнатам и расписаниям
+func (h *Handler) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if len([]rune(query)) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поисковый запрос должен содержать не менее 2 символов"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный параметр limit"})
+			return
+		}
+		if n > maxSearchLimit {
+			n = maxSearchLimit
+		}
+		limit = n
+	}
+
+	types, err := parseSearchTypes(c.Query("types"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.search.Search(ctx, query, limit, types)
+	if err != nil {
+		log.Printf("Ошибка при загрузке поискового индекса: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выполнить поиск"})
+		return
+	}
+	if results == nil {
+		results = []search.Result{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query": query,
+		"total": len(results),
+		"data":  results,
+	})
+}
+
+func parseSearchTypes(raw string) (map[string]bool, error) {
+	all := map[string]bool{search.TypeUser: true, search.TypeRoom: true, search.TypeSchedule: true}
+	if raw == "" {
+		return all, nil
+	}
+
+	types := map[string]bool{}
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if !all[t] {
+			return nil, fmt.Errorf("Недопустимый тип поиска '%s'. Допустимые типы: '%s', '%s', '%s'",
+				t, search.TypeUser, search.TypeRoom, search.TypeSchedule)
+		}
+		types[t] = true
+	}
+	return types, nil
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"access-control-system/eventlog"
+	"access-control-system/stream"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// Пустое сообщение, чтобы прокси не закрывали молчащее соединение
+	streamHeartbeat = 15 * time.Second
+	// Клиент, не принявший сообщение за это время, отключается
+	streamWriteTimeout = 10 * time.Second
+)
+
+// publishDecision журналирует и публикует решение о доступе и передаёт его
+// правилам оповещений. Разрешённые проходы тоже журналируются: по ним
+// строится отчёт о посещаемости
+func (h *Handler) publishDecision(userID primitive.ObjectID, roomNumber string, d AccessDecision) {
+	var message string
+	if d.logEntry != nil {
+		message = eventlog.Render(h.writeLog(*d.logEntry), eventlog.DefaultLang)
+	}
+
+	granted := d.Granted
+	h.alerts.Observe(h.emit(stream.Event{
+		Kind:      stream.KindAccess,
+		Type:      d.Reason,
+		Message:   message,
+		UserID:    userID.Hex(),
+		Room:      roomNumber,
+		Building:  d.building,
+		Floor:     d.floor,
+		Timezone:  d.Timezone,
+		Granted:   &granted,
+		Timestamp: h.clock.Now(),
+	}))
+}
+
+func hexOrEmpty(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+// streamFilter читает фильтры из ?type=, ?room=, ?building=. Каждый
+// параметр можно повторять или перечислять значения через запятую
+func streamFilter(c *gin.Context) stream.Filter {
+	return stream.Filter{
+		Types:     queryList(c, "type"),
+		Rooms:     queryList(c, "room"),
+		Buildings: queryList(c, "building"),
+	}
+}
+
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// lastEventID — номер последнего полученного события: заголовок
+// Last-Event-ID, который EventSource отправляет при переподключении,
+// или ?last_event_id=
+func lastEventID(c *gin.Context) uint64 {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	id, _ := strconv.ParseUint(raw, 10, 64)
+	return id
+}
+
+// streamSink — способ доставки событий клиенту
+type streamSink interface {
+	event(e stream.Event) error
+	// dropped сообщает, сколько событий клиент не получил
+	dropped(n uint64) error
+	heartbeat() error
+}
+
+// pumpEvents передаёт события подписки клиенту, пока не закроется done,
+// подписка или не произойдёт ошибка записи
+func pumpEvents(done <-chan struct{}, sub *stream.Subscription, backlog []stream.Event, sink streamSink) {
+	for _, e := range backlog {
+		if sink.event(e) != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if n := sub.TakeDropped(); n > 0 {
+				if sink.dropped(n) != nil {
+					return
+				}
+			}
+			if sink.event(e) != nil {
+				return
+			}
+		case <-ticker.C:
+			if n := sub.TakeDropped(); n > 0 {
+				if sink.dropped(n) != nil {
+					return
+				}
+			}
+			if sink.heartbeat() != nil {
+				return
+			}
+		}
+	}
+}
+
+// Поток событий журнала и решений о доступе (Server-Sent Events).
+// Фильтры: ?type=, ?room=, ?building=
+func (h *Handler) StreamEvents(c *gin.Context) {
+	sub, backlog := h.stream.Subscribe(streamFilter(c), lastEventID(c))
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	sink := &sseSink{w: c.Writer, rc: http.NewResponseController(c.Writer)}
+	if sink.write("retry: 3000\n\n") != nil {
+		return
+	}
+	pumpEvents(c.Request.Context().Done(), sub, backlog, sink)
+}
+
+type sseSink struct {
+	w  gin.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseSink) write(frame string) error {
+	// Продлеваем срок записи сервера для каждого сообщения: поток живёт
+	// дольше server.write_timeout, а зависший клиент отключается
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := s.w.WriteString(frame); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+func (s *sseSink) event(e stream.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Kind, data))
+}
+
+func (s *sseSink) dropped(n uint64) error {
+	return s.write(fmt.Sprintf("event: dropped\ndata: {\"dropped\":%d}\n\n", n))
+}
+
+func (s *sseSink) heartbeat() error {
+	return s.write(": ping\n\n")
+}
+
+// Поток событий через WebSocket: каждое сообщение — JSON-объект события.
+// Служебные сообщения имеют kind "dropped" и "heartbeat".
+// Источник запроса уже проверен CORS-middleware
+func (h *Handler) StreamEventsWS(c *gin.Context) {
+	filter, after := streamFilter(c), lastEventID(c)
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		h.serveEventsWS(ws, filter, after)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *Handler) serveEventsWS(ws *websocket.Conn, filter stream.Filter, after uint64) {
+	defer ws.Close()
+	// Сроки чтения и записи HTTP-сервера остаются на соединении после
+	// перехвата и оборвали бы поток
+	_ = ws.SetDeadline(time.Time{})
+
+	sub, backlog := h.stream.Subscribe(filter, after)
+	defer sub.Close()
+
+	// Клиент ничего не присылает, чтение нужно, чтобы заметить отключение
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+
+	pumpEvents(done, sub, backlog, wsSink{ws})
+}
+
+type wsSink struct {
+	ws *websocket.Conn
+}
+
+type wsNotice struct {
+	Kind    string `json:"kind"`
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+func (s wsSink) send(v interface{}) error {
+	_ = s.ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return websocket.JSON.Send(s.ws, v)
+}
+
+func (s wsSink) event(e stream.Event) error { return s.send(e) }
+
+func (s wsSink) dropped(n uint64) error {
+	return s.send(wsNotice{Kind: "dropped", Dropped: n})
+}
+
+func (s wsSink) heartbeat() error {
+	return s.send(wsNotice{Kind: "heartbeat"})
+}
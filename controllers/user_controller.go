@@ -3,18 +3,22 @@ package controllers
 import (
 	"access-control-system/config"
 	"access-control-system/models"
+	"access-control-system/repository"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Фильтр, возвращающий пользователей с любым статусом
+var allUsers = repository.UserFilter{All: true}
+
 func isValidRole(role models.Role) bool {
 	switch role {
 	case models.RoleAdmin, models.RoleTeacher, models.RoleStaff:
@@ -24,15 +28,20 @@ func isValidRole(role models.Role) bool {
 	}
 }
 
-func CreateUser(c *gin.Context) {
+func (h *Handler) CreateUser(c *gin.Context) {
 	log.Println("Началась обработка запроса POST /users")
-	var user models.User
+	var req models.UserRequest
 
-	if err := c.ShouldBindJSON(&user); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Printf("Ошибка при привязке JSON: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер телефона"})
+		return
+	}
+	user := req.ToUser()
 
 	if user.Password == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Пароль не может быть пустым"})
@@ -63,14 +72,24 @@ func CreateUser(c *gin.Context) {
 		user.AccessRooms = []string{"*"}
 	}
 
+	user.Status = models.StatusActive
 	user.ID = primitive.NewObjectID()
 	user.KeyID = user.ID.Hex()
 
-	collection := config.GetCollection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	_, err = collection.InsertOne(ctx, user)
+	err = h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Users.Create(tx, &user); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditCreate, auditUser, user.ID.Hex(), nil, user)
+	})
+	if field, ok := duplicateKeyField(err); ok {
+		log.Printf("Дублирующееся значение поля %s при создании пользователя", field)
+		respondDuplicateKey(c, field)
+		return
+	}
 	if err != nil {
 		log.Printf("Ошибка при создании пользователя: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать пользователя"})
@@ -80,32 +99,44 @@ func CreateUser(c *gin.Context) {
 	log.Printf("Пользователь успешно создан (ID: %s, KeyID: %s)", user.ID.Hex(), user.KeyID)
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Пользователь успешно создан",
-		"user":    user,
+		"user":    models.NewUserResponse(user),
 	})
 	log.Println("Запрос POST /users обработан успешно")
 }
 
-func GetUsers(c *gin.Context) {
+// userStatusFilter строит фильтр по параметру status. По умолчанию
+// деактивированные пользователи не возвращаются, "all" отключает фильтр
+func userStatusFilter(raw string) (repository.UserFilter, bool) {
+	switch status := models.UserStatus(raw); status {
+	case "":
+		return repository.UserFilter{}, true
+	case "all":
+		return allUsers, true
+	case models.StatusActive, models.StatusSuspended, models.StatusDeactivated:
+		return repository.UserFilter{Status: status}, true
+	default:
+		return repository.UserFilter{}, false
+	}
+}
+
+func (h *Handler) GetUsers(c *gin.Context) {
 	log.Println("Началась обработка запроса GET /users")
 
-	collection := config.GetCollection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	filter, ok := userStatusFilter(c.Query("status"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимый статус пользователя"})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	cursor, err := collection.Find(ctx, bson.M{})
+	users, err := h.store.Users.List(ctx, filter)
 	if err != nil {
 		log.Printf("Ошибка при получении пользователей: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Не удалось получить пользователей: %v", err)})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var users []models.User
-	if err = cursor.All(ctx, &users); err != nil {
-		log.Printf("Ошибка при декодировании пользователей: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Не удалось декодировать пользователей: %v", err)})
-		return
-	}
 
 	if len(users) == 0 {
 		log.Println("Пользователи не найдены")
@@ -114,11 +145,11 @@ func GetUsers(c *gin.Context) {
 	}
 
 	log.Printf("Найдено пользователей: %d", len(users))
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, models.NewUserResponses(users))
 	log.Println("Запрос GET /users обработан успешно")
 }
 
-func UpdateUser(c *gin.Context) {
+func (h *Handler) UpdateUser(c *gin.Context) {
 	log.Println("Началась обработка запроса PUT /users/:id")
 
 	id := c.Param("id")
@@ -129,12 +160,16 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var updatedUser models.User
+	var updatedUser models.UserRequest
 	if err := c.ShouldBindJSON(&updatedUser); err != nil {
 		log.Printf("Ошибка при привязке JSON: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := updatedUser.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер телефона"})
+		return
+	}
 
 	// Если роль передана и она некорректная, возвращаем ошибку
 	if updatedUser.Role != "" && !isValidRole(updatedUser.Role) {
@@ -145,11 +180,10 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	updateFields := bson.M{}
+	updateFields := map[string]interface{}{}
 
 	// Обновляем только непустые поля
 	if updatedUser.FirstName != "" {
@@ -193,6 +227,7 @@ func UpdateUser(c *gin.Context) {
 			return
 		}
 		updateFields["password"] = string(hashedPassword)
+		updateFields["must_change_password"] = false
 	}
 
 	if len(updateFields) == 0 {
@@ -200,8 +235,34 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	update := bson.M{"$set": updateFields}
-	_, err = collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	// Хэш пароля не сериализуется, его замена отмечается отдельно
+	var passwordChange []models.AuditChange
+	if updatedUser.Password != "" {
+		passwordChange = append(passwordChange, models.AuditChange{Field: "password"})
+	}
+	err = h.audited(ctx, func(tx context.Context) error {
+		before, err := h.store.Users.FindByID(tx, objID)
+		if err != nil {
+			return err
+		}
+		if err := h.store.Users.Update(tx, objID, updateFields); err != nil {
+			return err
+		}
+		after, err := h.store.Users.FindByID(tx, objID)
+		if err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditUser, id, before, after, passwordChange...)
+	})
+	if field, ok := duplicateKeyField(err); ok {
+		log.Printf("Дублирующееся значение поля %s при обновлении пользователя", field)
+		respondDuplicateKey(c, field)
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
 	if err != nil {
 		log.Printf("Ошибка при обновлении пользователя: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить пользователя"})
@@ -212,9 +273,34 @@ func UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Пользователь успешно обновлен"})
 }
 
-func DeleteUser(c *gin.Context) {
+// Деактивация пользователя. Документ сохраняется вместе с историей,
+// окончательное удаление выполняет фоновая очистка по истечении срока хранения
+func (h *Handler) DeleteUser(c *gin.Context) {
 	log.Println("Началась обработка запроса DELETE /users/:id")
 
+	from := []models.UserStatus{models.StatusActive, models.StatusSuspended}
+	h.changeUserStatus(c, from, models.StatusDeactivated, "деактивирован")
+}
+
+// Временная блокировка пропуска пользователя
+func (h *Handler) SuspendUser(c *gin.Context) {
+	log.Println("Началась обработка запроса POST /users/:id/suspend")
+
+	from := []models.UserStatus{models.StatusActive}
+	h.changeUserStatus(c, from, models.StatusSuspended, "заблокирован")
+}
+
+// Восстановление заблокированного или деактивированного пользователя
+func (h *Handler) RestoreUser(c *gin.Context) {
+	log.Println("Началась обработка запроса POST /users/:id/restore")
+
+	from := []models.UserStatus{models.StatusSuspended, models.StatusDeactivated}
+	h.changeUserStatus(c, from, models.StatusActive, "восстановлен")
+}
+
+// changeUserStatus переводит пользователя в статус status, если его текущий
+// статус входит в from. Иначе отвечает 404 или 409
+func (h *Handler) changeUserStatus(c *gin.Context, from []models.UserStatus, status models.UserStatus, action string) {
 	id := c.Param("id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -223,23 +309,74 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := config.QueryContext()
 	defer cancel()
 
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": objID})
+	var user models.User
+	err = h.audited(ctx, func(tx context.Context) error {
+		// Исходное состояние для журнала аудита. Отсутствие пользователя
+		// обработает ChangeStatus
+		before, _ := h.store.Users.FindByID(tx, objID)
+		var err error
+		user, err = h.store.Users.ChangeStatus(tx, objID, from, status, h.clock.Now())
+		if err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditUser, id, before, user)
+	})
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Текущий статус пользователя не допускает это действие"})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		log.Printf("Пользователь с ID %s не найден", id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	case err != nil:
+		log.Printf("Ошибка при изменении статуса пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось изменить статус пользователя"})
+		return
+	}
+
+	log.Printf("Пользователь (ID: %s) %s", id, action)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Пользователь " + action,
+		"user":    models.NewUserResponse(user),
+	})
+}
+
+// Окончательное удаление пользователя. Параметр policy определяет судьбу
+// его расписаний: block (по умолчанию), cascade или reassign (reassign_to=<id>)
+func (h *Handler) PurgeUser(c *gin.Context) {
+	log.Println("Началась обработка запроса DELETE /users/:id/permanent")
+
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Printf("Ошибка при удалении пользователя: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить пользователя"})
+		log.Printf("Ошибка при конвертации ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		log.Printf("Пользователь с ID %s не найден", id)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+	reassignTo := c.Query("reassign_to")
+	policy, err := parseDeletePolicy(c.Query("policy"), reassignTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	affected, err := h.deleteUserWithPolicy(ctx, objID, policy, reassignTo, time.Time{},
+		func(tx context.Context, before interface{}, affected map[string]int64) error {
+			return h.recordAudit(tx, c, models.AuditDelete, auditUser, id, before, nil, affectedChange(affected))
+		})
+	if err != nil {
+		respondDeleteError(c, err)
 		return
 	}
 
-	log.Printf("Пользователь (ID: %s) успешно удален", id)
-	c.JSON(http.StatusOK, gin.H{"message": "Пользователь успешно удален"})
+	log.Printf("Пользователь (ID: %s) окончательно удален, политика %s", id, policy)
+	c.JSON(http.StatusOK, gin.H{"message": "Пользователь окончательно удален", "affected": affected})
 }
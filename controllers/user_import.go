@@ -0,0 +1,486 @@
+package controllers
+
+import (
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxImportSize         = 10 << 20
+	maxImportRows         = 5000
+	temporaryPasswordSize = 12
+	temporaryPasswordABC  = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+const (
+	importStatusValid     = "valid"
+	importStatusCreated   = "created"
+	importStatusInvalid   = "invalid"
+	importStatusDuplicate = "duplicate"
+)
+
+// ImportRowResult — результат обработки одной строки импорта
+type ImportRowResult struct {
+	Row               int      `json:"row"`
+	Status            string   `json:"status"`
+	Errors            []string `json:"errors,omitempty"`
+	UserID            string   `json:"user_id,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	Phone             string   `json:"phone,omitempty"`
+	TemporaryPassword string   `json:"temporary_password,omitempty"`
+}
+
+type importRow struct {
+	result  *ImportRowResult
+	request models.UserRequest
+}
+
+// Массовый импорт пользователей из CSV или JSON.
+// Колонки CSV: first_name, second_name, email, phone, role, access_rooms
+// (через точку с запятой), address, city, country, password. Пользователям
+// без пароля генерируется временный. При dry_run=true данные только проверяются
+func (h *Handler) ImportUsers(c *gin.Context) {
+	log.Println("Началась обработка запроса POST /users/import")
+
+	dryRun := c.Query("dry_run") == "true"
+	format := importFormat(c)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	var (
+		requests []models.UserRequest
+		err      error
+	)
+	switch format {
+	case "csv":
+		requests, err = parseUsersCSV(body)
+	case "json":
+		err = json.NewDecoder(body).Decode(&requests)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются форматы csv и json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось разобрать файл: " + err.Error()})
+		return
+	}
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не содержит пользователей"})
+		return
+	}
+	if len(requests) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("За один импорт допускается не более %d пользователей", maxImportRows)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	rows := validateImportRows(requests)
+	if err := h.markExistingDuplicates(ctx, rows); err != nil {
+		log.Printf("Ошибка при проверке дубликатов: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить дубликаты"})
+		return
+	}
+
+	if !dryRun {
+		if err := h.insertImportRows(ctx, c, rows); err != nil {
+			log.Printf("Ошибка при импорте пользователей: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось импортировать пользователей"})
+			return
+		}
+	}
+
+	results := make([]ImportRowResult, 0, len(rows))
+	summary := map[string]int{}
+	for _, row := range rows {
+		results = append(results, *row.result)
+		summary[row.result.Status]++
+	}
+
+	log.Printf("Импорт пользователей завершён (dry_run=%t): %v", dryRun, summary)
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": dryRun,
+		"total":   len(rows),
+		"summary": summary,
+		"data":    results,
+	})
+}
+
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	if strings.Contains(c.ContentType(), "csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+func parseUsersCSV(r io.Reader) ([]models.UserRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Excel добавляет BOM в начало файла
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"first_name", "second_name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("отсутствует обязательная колонка %s", required)
+		}
+	}
+
+	var requests []models.UserRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				// Файл мог быть получен выгрузкой с экранированием формул
+				return csvUnescape(strings.TrimSpace(record[i]))
+			}
+			return ""
+		}
+
+		var rooms []string
+		for _, room := range strings.Split(get("access_rooms"), ";") {
+			if room = strings.TrimSpace(room); room != "" {
+				rooms = append(rooms, room)
+			}
+		}
+
+		requests = append(requests, models.UserRequest{
+			FirstName:   get("first_name"),
+			SecondName:  get("second_name"),
+			Email:       get("email"),
+			Phone:       get("phone"),
+			Role:        models.Role(get("role")),
+			AccessRooms: rooms,
+			Address:     get("address"),
+			City:        get("city"),
+			Country:     get("country"),
+			Password:    get("password"),
+		})
+	}
+	return requests, nil
+}
+
+// validateImportRows проверяет строки и находит дубликаты внутри файла
+func validateImportRows(requests []models.UserRequest) []importRow {
+	rows := make([]importRow, 0, len(requests))
+	seenEmails := map[string]int{}
+	seenPhones := map[string]int{}
+
+	for i, req := range requests {
+		result := &ImportRowResult{Row: i + 1, Status: importStatusValid}
+		var errs []string
+
+		if strings.TrimSpace(req.FirstName) == "" || strings.TrimSpace(req.SecondName) == "" {
+			errs = append(errs, "Имя и фамилия обязательны")
+		}
+		if err := req.Normalize(); err != nil {
+			errs = append(errs, "Некорректный номер телефона")
+		}
+		if req.Email == "" && req.Phone == "" {
+			errs = append(errs, "Требуется email или телефон")
+		}
+		if req.Role == "" {
+			req.Role = models.RoleTeacher
+		}
+		if !isValidRole(req.Role) {
+			errs = append(errs, fmt.Sprintf("Недопустимая роль '%s'", req.Role))
+		}
+
+		result.Email, result.Phone = req.Email, req.Phone
+		if len(errs) > 0 {
+			result.Status = importStatusInvalid
+			result.Errors = errs
+			rows = append(rows, importRow{result: result, request: req})
+			continue
+		}
+
+		if first, ok := seenEmails[req.Email]; ok && req.Email != "" {
+			errs = append(errs, fmt.Sprintf("email повторяется в строке %d", first))
+		}
+		if first, ok := seenPhones[req.Phone]; ok && req.Phone != "" {
+			errs = append(errs, fmt.Sprintf("телефон повторяется в строке %d", first))
+		}
+		if len(errs) > 0 {
+			result.Status = importStatusDuplicate
+			result.Errors = errs
+		} else {
+			if req.Email != "" {
+				seenEmails[req.Email] = result.Row
+			}
+			if req.Phone != "" {
+				seenPhones[req.Phone] = result.Row
+			}
+		}
+		rows = append(rows, importRow{result: result, request: req})
+	}
+	return rows
+}
+
+// markExistingDuplicates помечает строки, чьи email или телефон уже заняты
+func (h *Handler) markExistingDuplicates(ctx context.Context, rows []importRow) error {
+	emails := []string{}
+	phones := []string{}
+	for _, row := range rows {
+		if row.result.Status != importStatusValid {
+			continue
+		}
+		if row.request.Email != "" {
+			emails = append(emails, row.request.Email)
+		}
+		if row.request.Phone != "" {
+			phones = append(phones, row.request.Phone)
+		}
+	}
+	existing, err := h.store.Users.FindByContacts(ctx, emails, phones)
+	if err != nil {
+		return err
+	}
+
+	takenEmails := map[string]string{}
+	takenPhones := map[string]string{}
+	for _, u := range existing {
+		takenEmails[u.Email] = u.ID.Hex()
+		takenPhones[u.Phone] = u.ID.Hex()
+	}
+
+	for _, row := range rows {
+		if row.result.Status != importStatusValid {
+			continue
+		}
+		if id, ok := takenEmails[row.request.Email]; ok && row.request.Email != "" {
+			row.result.Errors = append(row.result.Errors, "email уже используется пользователем "+id)
+		}
+		if id, ok := takenPhones[row.request.Phone]; ok && row.request.Phone != "" {
+			row.result.Errors = append(row.result.Errors, "телефон уже используется пользователем "+id)
+		}
+		if len(row.result.Errors) > 0 {
+			row.result.Status = importStatusDuplicate
+		}
+	}
+	return nil
+}
+
+func (h *Handler) insertImportRows(ctx context.Context, c *gin.Context, rows []importRow) error {
+	var (
+		users   []models.User
+		created []importRow
+	)
+	for _, row := range rows {
+		if row.result.Status != importStatusValid {
+			continue
+		}
+
+		user := row.request.ToUser()
+		if user.Password == "" {
+			password, err := generateTemporaryPassword()
+			if err != nil {
+				return err
+			}
+			user.Password = password
+			user.MustChangePassword = true
+			row.result.TemporaryPassword = password
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.Password = string(hashed)
+
+		if user.Role == models.RoleAdmin {
+			user.AccessRooms = []string{"*"}
+		}
+		user.Status = models.StatusActive
+		user.ID = primitive.NewObjectID()
+		user.KeyID = user.ID.Hex()
+
+		row.result.UserID = user.ID.Hex()
+		users = append(users, user)
+		created = append(created, row)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	// Каждая строка сохраняется в своей транзакции вместе с записью в
+	// журнале аудита: в транзакции MongoDB ошибка записи отменяет её
+	// целиком, поэтому конфликт одной строки не должен делить транзакцию
+	// с остальными. Прочие ошибки прерывают импорт, уже созданные строки
+	// остаются
+	for i, row := range created {
+		user := users[i]
+		err := h.audited(ctx, func(tx context.Context) error {
+			if err := h.store.Users.Create(tx, &user); err != nil {
+				return err
+			}
+			return h.recordAudit(tx, c, models.AuditCreate, auditUser, user.ID.Hex(), nil, user)
+		})
+		var dupErr *repository.DuplicateKeyError
+		switch {
+		case err == nil:
+			row.result.Status = importStatusCreated
+		case errors.As(err, &dupErr):
+			row.result.UserID = ""
+			row.result.TemporaryPassword = ""
+			row.result.Status = importStatusDuplicate
+			row.result.Errors = append(row.result.Errors, fmt.Sprintf("Значение поля '%s' уже используется", dupErr.Field))
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func generateTemporaryPassword() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(temporaryPasswordABC)))
+	for i := 0; i < temporaryPasswordSize; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(temporaryPasswordABC[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// Экспорт пользователей без учётных данных в CSV или JSON.
+// Документы читаются курсором и сразу пишутся в ответ
+func (h *Handler) ExportUsers(c *gin.Context) {
+	log.Println("Началась обработка запроса GET /users/export")
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются форматы csv и json"})
+		return
+	}
+	filter, ok := userStatusFilter(c.DefaultQuery("status", "all"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимый статус пользователя"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	each := func(fn func(models.User) error) error {
+		return h.store.Users.Each(ctx, filter, fn)
+	}
+
+	filename := "users-" + time.Now().Format("2006-01-02")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	var (
+		count int
+		err   error
+	)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		count, err = writeUsersCSV(exportWriter(c), each)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		count, err = writeUsersJSON(exportWriter(c), each)
+	}
+	if err != nil {
+		// Заголовки уже отправлены, поэтому остаётся только прервать ответ
+		log.Printf("Ошибка при выгрузке пользователей: %v", err)
+		c.Abort()
+		return
+	}
+	log.Printf("Выгружено пользователей: %d", count)
+}
+
+var userExportColumns = []string{
+	"id", "key_id", "first_name", "second_name", "email", "phone", "role",
+	"status", "access_rooms", "address", "city", "country",
+}
+
+// usersIterator передаёт пользователей по одному в fn
+type usersIterator func(fn func(models.User) error) error
+
+func writeUsersCSV(w io.Writer, each usersIterator) (int, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(userExportColumns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := each(func(user models.User) error {
+		u := models.NewUserResponse(user)
+		if err := writer.Write(csvSafe([]string{
+			u.ID.Hex(), u.KeyID, u.FirstName, u.SecondName, u.Email, u.Phone, string(u.Role),
+			string(u.Status), strings.Join(models.SplitAccessRooms(u.AccessRooms), ";"), u.Address, u.City, u.Country,
+		})); err != nil {
+			return err
+		}
+		count++
+		if count%500 == 0 {
+			writer.Flush()
+		}
+		return nil
+	})
+	writer.Flush()
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Error()
+}
+
+func writeUsersJSON(w io.Writer, each usersIterator) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := each(func(user models.User) error {
+		data, err := json.Marshal(models.NewUserResponse(user))
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	_, err = io.WriteString(w, "]")
+	return count, err
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"access-control-system/audit"
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// racingUsers отвечает конфликтом на вставку пользователя с email,
+// как если бы его успел создать другой запрос после проверки дубликатов
+type racingUsers struct {
+	repository.UserRepository
+	email string
+}
+
+func (r racingUsers) Create(ctx context.Context, user *models.User) error {
+	if user.Email == r.email {
+		return &repository.DuplicateKeyError{Field: "email"}
+	}
+	return r.UserRepository.Create(ctx, user)
+}
+
+// Конфликт одной строки не отменяет остальные строки импорта
+func TestImportRowConflictKeepsOtherRows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Current = testConfig(t)
+	clk := clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC))
+	store := repository.NewMemoryStore()
+	store.Users = racingUsers{UserRepository: store.Users, email: "taken@example.com"}
+	h := NewHandler(Deps{Store: store, Clock: clk, Audit: audit.NewTrail(store.Audit, clk)})
+	router := gin.New()
+	router.POST("/users/import", h.ImportUsers)
+
+	body := `[
+		{"first_name": "Анна", "second_name": "Смирнова", "email": "anna@example.com", "password": "anna-password", "access_rooms": ["101"]},
+		{"first_name": "Иван", "second_name": "Петров", "email": "taken@example.com", "password": "ivan-password", "access_rooms": ["101"]}
+	]`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/import?format=json", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /users/import = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Results []ImportRowResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Status != importStatusCreated || resp.Results[1].Status != importStatusDuplicate {
+		t.Fatalf("результаты импорта: %+v", resp.Results)
+	}
+
+	ctx := context.Background()
+	entries, err := store.Audit.List(ctx, repository.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].EntityID != resp.Results[0].UserID {
+		t.Fatalf("записи аудита: %+v", entries)
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"access-control-system/audit"
+	"access-control-system/config"
+	"access-control-system/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const userPurgeInterval = time.Hour
+
+// StartUserPurge периодически удаляет пользователей, деактивированных раньше,
+// чем config.Current.UserRetention(), каскадно вместе с их расписаниями. Блокирует до отмены ctx
+func (h *Handler) StartUserPurge(ctx context.Context) {
+	ticker := time.NewTicker(userPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		h.purgeDeactivatedUsers(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handler) purgeDeactivatedUsers(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cutoff := h.clock.Now().Add(-config.Current.UserRetention())
+	expired, err := h.store.Users.ListDeactivatedBefore(ctx, cutoff)
+	if err != nil {
+		log.Printf("Ошибка при поиске деактивированных пользователей: %v", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	purged := 0
+	for _, u := range expired {
+		// Пользователя могли восстановить после выборки
+		_, err := h.deleteUserWithPolicy(ctx, u.ID, PolicyCascade, "", cutoff,
+			func(tx context.Context, before interface{}, affected map[string]int64) error {
+				return h.appendAudit(tx, models.AuditEntry{
+					Actor:    actorSystem,
+					Action:   models.AuditDelete,
+					Entity:   auditUser,
+					EntityID: u.ID.Hex(),
+					Changes:  append(audit.Diff(before, nil), affectedChange(affected)),
+				})
+			})
+		if errors.Is(err, errNotExpired) || errors.Is(err, errEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Ошибка при удалении деактивированного пользователя %s: %v", u.ID.Hex(), err)
+			continue
+		}
+		purged++
+	}
+	log.Printf("Удалено деактивированных пользователей: %d", purged)
+}
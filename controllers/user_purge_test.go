@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPurgeSkipsRestoredAndRecentUsers(t *testing.T) {
+	config.Current = config.Default()
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	store := repository.NewMemoryStore()
+	h := NewHandler(Deps{Store: store, Clock: clock.NewManual(now)})
+	ctx := context.Background()
+
+	old := now.Add(-2 * config.Current.UserRetention())
+	recent := now.Add(-time.Hour)
+	users := map[string]models.User{
+		"expired":  {Status: models.StatusDeactivated, DeactivatedAt: &old},
+		"recent":   {Status: models.StatusDeactivated, DeactivatedAt: &recent},
+		"restored": {Status: models.StatusActive},
+	}
+	for name, u := range users {
+		u.ID = primitive.NewObjectID()
+		u.KeyID = u.ID.Hex()
+		u.Email = name + "@example.com"
+		if err := store.Users.Create(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+		users[name] = u
+	}
+
+	cutoff := now.Add(-config.Current.UserRetention())
+	for _, name := range []string{"recent", "restored"} {
+		if _, err := h.deleteUserWithPolicy(ctx, users[name].ID, PolicyCascade, "", cutoff, noAudit); !errors.Is(err, errNotExpired) {
+			t.Fatalf("%s: ошибка %v, ожидалась errNotExpired", name, err)
+		}
+	}
+
+	h.purgeDeactivatedUsers(ctx)
+	for name, u := range users {
+		_, err := store.Users.FindByID(ctx, u.ID)
+		if deleted := errors.Is(err, repository.ErrNotFound); deleted != (name == "expired") {
+			t.Errorf("%s: удалён = %t", name, deleted)
+		}
+	}
+}
+
+func noAudit(context.Context, interface{}, map[string]int64) error { return nil }
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"access-control-system/config"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookRequest — поля подписки. При обновлении пустые поля не меняются
+type WebhookRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret — ключ подписи. Если не задан при создании, создаётся случайный
+	Secret string `json:"secret"`
+	Active *bool  `json:"active"`
+	// RotateSecret заменяет ключ подписи на новый случайный
+	RotateSecret bool `json:"rotate_secret"`
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func cleanEventTypes(types []string) []string {
+	var cleaned []string
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" && !contains(cleaned, t) {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return cleaned
+}
+
+func contains(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookFromParam находит подписку по :id и отвечает ошибкой, если её нет
+func (h *Handler) webhookFromParam(ctx context.Context, c *gin.Context) (models.Webhook, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID подписки"})
+		return models.Webhook{}, false
+	}
+	w, err := h.store.Webhooks.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Подписка не найдена"})
+		return w, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения подписки"})
+		return w, false
+	}
+	return w, true
+}
+
+// Создание подписки. Ключ подписи возвращается только в этом ответе
+// и при его замене
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+		return
+	}
+
+	w := models.Webhook{
+		ID:         primitive.NewObjectID(),
+		Name:       strings.TrimSpace(req.Name),
+		URL:        strings.TrimSpace(req.URL),
+		EventTypes: cleanEventTypes(req.EventTypes),
+		Secret:     req.Secret,
+		Active:     req.Active == nil || *req.Active,
+		CreatedAt:  h.clock.Now(),
+	}
+	if !validWebhookURL(w.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url должен быть адресом http или https"})
+		return
+	}
+	if len(w.EventTypes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите хотя бы один тип события в event_types или \"*\""})
+		return
+	}
+	if w.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать ключ подписи"})
+			return
+		}
+		w.Secret = secret
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	err := h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Webhooks.Create(tx, &w); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditCreate, auditWebhook, w.ID.Hex(), nil, w, models.AuditChange{Field: "secret"})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать подписку"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Подписка создана", "data": w, "secret": w.Secret})
+}
+
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	webhooks, err := h.store.Webhooks.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения подписок"})
+		return
+	}
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": webhooks})
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	w, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": w})
+}
+
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные: " + err.Error()})
+		return
+	}
+
+	fields := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		fields["name"] = name
+	}
+	if u := strings.TrimSpace(req.URL); u != "" {
+		if !validWebhookURL(u) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url должен быть адресом http или https"})
+			return
+		}
+		fields["url"] = u
+	}
+	if req.EventTypes != nil {
+		types := cleanEventTypes(req.EventTypes)
+		if len(types) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите хотя бы один тип события в event_types или \"*\""})
+			return
+		}
+		fields["event_types"] = types
+	}
+	if req.Active != nil {
+		fields["active"] = *req.Active
+	}
+	secret := req.Secret
+	if req.RotateSecret && secret == "" {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать ключ подписи"})
+			return
+		}
+	}
+	if secret != "" {
+		fields["secret"] = secret
+	}
+	if len(fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нет данных для обновления"})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	before, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+	// Ключ подписи не сериализуется, его замена отмечается отдельно
+	var secretChange []models.AuditChange
+	if secret != "" {
+		secretChange = append(secretChange, models.AuditChange{Field: "secret"})
+	}
+	var w models.Webhook
+	err := h.audited(ctx, func(tx context.Context) error {
+		if err := h.store.Webhooks.Update(tx, before.ID, fields); err != nil {
+			return err
+		}
+		var err error
+		if w, err = h.store.Webhooks.FindByID(tx, before.ID); err != nil {
+			return err
+		}
+		return h.recordAudit(tx, c, models.AuditUpdate, auditWebhook, w.ID.Hex(), before, w, secretChange...)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить подписку"})
+		return
+	}
+
+	resp := gin.H{"message": "Подписка обновлена", "data": w}
+	if secret != "" {
+		resp["secret"] = secret
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Удаление подписки вместе с историей и очередью её доставок
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	w, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+
+	var deleted int64
+	err := h.audited(ctx, func(ctx context.Context) error {
+		if err := h.store.Webhooks.Delete(ctx, w.ID); err != nil {
+			return err
+		}
+		var err error
+		if deleted, err = h.store.Deliveries.DeleteByWebhook(ctx, w.ID); err != nil {
+			return err
+		}
+		return h.recordAudit(ctx, c, models.AuditDelete, auditWebhook, w.ID.Hex(), w, nil,
+			affectedChange(map[string]int64{"deliveries_deleted": deleted}))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить подписку"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Подписка удалена", "deliveries_deleted": deleted})
+}
+
+// Отправка тестового события подписке. Результат попытки возвращается
+// в ответе и сохраняется в истории доставок
+func (h *Handler) TestWebhook(c *gin.Context) {
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	w, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+
+	testCtx, cancelTest := context.WithTimeout(c.Request.Context(), 2*config.Current.Webhooks.Timeout)
+	defer cancelTest()
+	delivery, err := h.webhooks.Test(testCtx, w)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить результат проверки"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"delivered": delivery.Status == models.DeliveryDelivered,
+		"data":      delivery,
+	})
+}
+
+// История доставок подписки, новые первыми. ?status=pending|delivered|dead, ?limit=
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	status := models.DeliveryStatus(c.Query("status"))
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status должен быть pending, delivered или dead"})
+		return
+	}
+	limit := defaultDeliveryLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit должен быть от 1 до " + strconv.Itoa(maxDeliveryLimit)})
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	w, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+	deliveries, err := h.store.Deliveries.ListByWebhook(ctx, w.ID, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения истории доставок"})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+// Повторная отправка доставки из dead. Лимит webhooks.max_attempts
+// отсчитывается заново
+func (h *Handler) RetryWebhookDelivery(c *gin.Context) {
+	deliveryID, err := primitive.ObjectIDFromHex(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID доставки"})
+		return
+	}
+
+	ctx, cancel := config.QueryContext()
+	defer cancel()
+
+	w, ok := h.webhookFromParam(ctx, c)
+	if !ok {
+		return
+	}
+	delivery, err := h.store.Deliveries.FindByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && delivery.WebhookID != w.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доставка не найдена"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доставки"})
+		return
+	}
+
+	err = h.store.Deliveries.Requeue(ctx, deliveryID, h.clock.Now())
+	if errors.Is(err, repository.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Повторить можно только доставку со статусом dead"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось поставить доставку в очередь"})
+		return
+	}
+	h.webhooks.Wake()
+	c.JSON(http.StatusOK, gin.H{"message": "Доставка поставлена в очередь"})
+}
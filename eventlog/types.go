@@ -0,0 +1,211 @@
+package eventlog
+
+import (
+	"access-control-system/models"
+	"sort"
+	"strings"
+)
+
+// Языки текста событий
+const (
+	LangRU      = "ru"
+	LangEN      = "en"
+	DefaultLang = LangRU
+)
+
+// Известные типы событий
+const (
+	TypeAccessGranted    = "access_granted"
+	TypeInactiveUser     = "inactive_user_access"
+	TypeNoRoomAccess     = "unauthorized_door_access"
+	TypeNoSchedule       = "unauthorized_schedule_access"
+	TypeOutsideSchedule  = "unauthorized_time_access"
+	TypeStaleOpen        = "stale_policy_open"
+	TypeStaleClosed      = "stale_policy_closed"
+	TypeUnknownKey       = "unknown_key"
+	TypeOfflineMismatch  = "offline_access_mismatch"
+	TypeAdminLoginDenied = "admin_access_denied"
+)
+
+// Категории типов событий
+const (
+	CategoryAccess = "access"
+	CategoryDevice = "device"
+	CategoryAuth   = "auth"
+)
+
+// EventType — описание типа события. Messages — шаблоны текста по языку.
+// В шаблоне {room}, {controller_id}, {reason}, {user_id}, {actor},
+// {source_ip} заменяются полями записи, остальные {ключ} — значениями Params
+type EventType struct {
+	Type        string            `json:"type"`
+	Category    string            `json:"category"`
+	Description string            `json:"description"`
+	Messages    map[string]string `json:"messages"`
+}
+
+var registry = map[string]EventType{}
+
+// Register добавляет тип события в реестр или заменяет его описание
+func Register(t EventType) {
+	registry[t.Type] = t
+}
+
+// Lookup возвращает описание типа события
+func Lookup(eventType string) (EventType, bool) {
+	t, ok := registry[eventType]
+	return t, ok
+}
+
+// Types возвращает известные типы событий по алфавиту
+func Types() []EventType {
+	types := make([]EventType, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// offlineSuffix дописывается к тексту проходов, загруженных контроллером,
+// если шаблон сам не упоминает контроллер
+var offlineSuffix = map[string]string{
+	LangRU: " (офлайн, контроллер {controller_id}, {offline_time})",
+	LangEN: " (offline, controller {controller_id}, {offline_time})",
+}
+
+// Render собирает текст записи на языке lang. Сохранённый Message
+// возвращается как есть: это произвольное событие или старая запись
+func Render(entry models.Log, lang string) string {
+	if entry.Message != "" {
+		return entry.Message
+	}
+	t, ok := registry[entry.EventType]
+	if !ok {
+		return entry.EventType
+	}
+	tmpl, ok := t.Messages[lang]
+	if !ok {
+		lang, tmpl = DefaultLang, t.Messages[DefaultLang]
+	}
+	if entry.ControllerID != "" && !strings.Contains(tmpl, "{controller_id}") {
+		tmpl += offlineSuffix[lang]
+	}
+
+	var userID string
+	if !entry.UserID.IsZero() {
+		userID = entry.UserID.Hex()
+	}
+	// При совпадении ключей побеждает первая пара, поля важнее Params
+	pairs := []string{
+		"{room}", entry.Room,
+		"{controller_id}", entry.ControllerID,
+		"{reason}", entry.Reason,
+		"{user_id}", userID,
+		"{actor}", entry.Actor,
+		"{source_ip}", entry.SourceIP,
+	}
+	for k, v := range entry.Params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// Language выбирает поддерживаемый язык из ?lang= или Accept-Language
+func Language(query, acceptLanguage string) string {
+	for _, candidate := range append([]string{query}, strings.Split(acceptLanguage, ",")...) {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(candidate, ";", 2)[0]))
+		tag = strings.SplitN(tag, "-", 2)[0]
+		if tag == LangRU || tag == LangEN {
+			return tag
+		}
+	}
+	return DefaultLang
+}
+
+func init() {
+	for _, t := range []EventType{
+		{
+			Type: TypeAccessGranted, Category: CategoryAccess,
+			Description: "Проход по расписанию. По этим событиям строится отчёт о посещаемости",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} прошёл в комнату {room}",
+				LangEN: "User {user_name} entered room {room}",
+			},
+		},
+		{
+			Type: TypeInactiveUser, Category: CategoryAccess,
+			Description: "Попытка доступа пользователя с неактивной учётной записью",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} со статусом '{status}' пытался получить доступ к комнате {room}",
+				LangEN: "User {user_name} with status '{status}' tried to access room {room}",
+			},
+		},
+		{
+			Type: TypeNoRoomAccess, Category: CategoryAccess,
+			Description: "Попытка доступа в комнату без разрешения",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} пытался получить доступ к комнате {room} без разрешения",
+				LangEN: "User {user_name} tried to access room {room} without permission",
+			},
+		},
+		{
+			Type: TypeNoSchedule, Category: CategoryAccess,
+			Description: "Попытка доступа без расписания на этот день",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} пытался войти в комнату {room} без действующего расписания на {day}",
+				LangEN: "User {user_name} tried to enter room {room} with no schedule for {day}",
+			},
+		},
+		{
+			Type: TypeOutsideSchedule, Category: CategoryAccess,
+			Description: "Попытка доступа вне времени расписания",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} пытался войти в комнату {room} в неразрешённое время ({time}). Допустимое время: {allowed}",
+				LangEN: "User {user_name} tried to enter room {room} outside the allowed time ({time}). Allowed: {allowed}",
+			},
+		},
+		{
+			Type: TypeStaleOpen, Category: CategoryAccess,
+			Description: "Пропуск по аварийной политике комнаты, пока база недоступна",
+			Messages: map[string]string{
+				LangRU: "База недоступна: пользователь {user_id} пропущен в комнату {room} без проверки прав",
+				LangEN: "Database unavailable: user {user_id} let into room {room} without permission check",
+			},
+		},
+		{
+			Type: TypeStaleClosed, Category: CategoryAccess,
+			Description: "Отказ по аварийной политике комнаты, пока база недоступна",
+			Messages: map[string]string{
+				LangRU: "База недоступна: пользователю {user_id} отказано в доступе к комнате {room} без проверки прав",
+				LangEN: "Database unavailable: user {user_id} denied access to room {room} without permission check",
+			},
+		},
+		{
+			Type: TypeUnknownKey, Category: CategoryDevice,
+			Description: "Контроллер записал ключ, не принадлежащий ни одному пользователю",
+			Messages: map[string]string{
+				LangRU: "Неизвестный ключ {key_id} у комнаты {room}",
+				LangEN: "Unknown key {key_id} at room {room}",
+			},
+		},
+		{
+			Type: TypeOfflineMismatch, Category: CategoryDevice,
+			Description: "Контроллер без связи с сервером принял решение, противоположное решению сервера",
+			Messages: map[string]string{
+				LangRU: "Контроллер {controller_id} без связи с сервером принял решение {controller_status} по ключу {key_id} в комнате {room}, сервер решил бы иначе ({reason}, {offline_time})",
+				LangEN: "Controller {controller_id} decided {controller_status} offline for key {key_id} at room {room}, the server would decide otherwise ({reason}, {offline_time})",
+			},
+		},
+		{
+			Type: TypeAdminLoginDenied, Category: CategoryAuth,
+			Description: "Попытка входа в панель администратора без роли администратора",
+			Messages: map[string]string{
+				LangRU: "Пользователь {user_name} с ролью '{role}' пытался авторизоваться как администратор",
+				LangEN: "User {user_name} with role '{role}' tried to sign in as administrator",
+			},
+		},
+	} {
+		Register(t)
+	}
+}
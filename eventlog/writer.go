@@ -0,0 +1,86 @@
+package eventlog
+
+import (
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const defaultQueueSize = 1024
+
+// Writer записывает события журнала в фоне, не задерживая ответы на запросы.
+// При переполнении очереди и после Close запись выполняется синхронно
+type Writer struct {
+	logs    repository.LogRepository
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan models.Log
+	done   chan struct{}
+}
+
+// NewWriter создаёт и запускает writer. timeout ограничивает одну запись в хранилище
+func NewWriter(logs repository.LogRepository, timeout time.Duration) *Writer {
+	w := &Writer{
+		logs:    logs,
+		timeout: timeout,
+		queue:   make(chan models.Log, defaultQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	for entry := range w.queue {
+		w.insert(entry)
+	}
+}
+
+func (w *Writer) insert(entry models.Log) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	if err := w.logs.Insert(ctx, &entry); err != nil {
+		log.Printf("Ошибка записи лога: %v", err)
+	}
+}
+
+// Write ставит событие в очередь на запись
+func (w *Writer) Write(entry models.Log) {
+	w.mu.RLock()
+	if !w.closed {
+		select {
+		case w.queue <- entry:
+			w.mu.RUnlock()
+			return
+		default:
+		}
+	}
+	w.mu.RUnlock()
+	w.insert(entry)
+}
+
+// Close прекращает приём событий в очередь и ждёт записи уже поставленных.
+// Если ctx завершится раньше, оставшиеся события теряются
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		log.Printf("Не записано событий журнала: %d", len(w.queue))
+		return ctx.Err()
+	}
+}
@@ -1,31 +1,42 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"access-control-system/app"
 	"access-control-system/config"
-	"access-control-system/routes"
-
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	config.ConnectDB()
-
-
-	router := gin.Default()
+	configPath := flag.String("config", "", "путь к YAML-файлу конфигурации")
+	printConfig := flag.Bool("print-config", false, "вывести действующую конфигурацию без секретов и выйти")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configPath)
+	if *printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("Ошибка при выводе конфигурации: %v", err)
+		}
+		fmt.Print(out)
+		return
+	}
 
-	config.SetupCORS(router)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	routes.RegisterPublicRoutes(router)
-	routes.UserRoutes(router)
-	routes.RegisterRoutes(router)
-	routes.RoomRoutes(router)
-	routes.ScheduleRoutes(router)
-	routes.RegisterAdminRoutes(router)
-	routes.LogRoutes(router)
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Ошибка при запуске сервиса: %v", err)
+	}
 
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal("Ошибка при запуске сервера:", err)
+	if err := application.Run(ctx); err != nil {
+		log.Fatalf("Сервис остановлен с ошибкой: %v", err)
 	}
 }
@@ -0,0 +1,326 @@
+// Package metrics — минимальный реестр метрик с выводом в текстовом
+// формате Prometheus (https://prometheus.io/docs/instrumenting/exposition_formats/)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets — границы гистограмм длительности в секундах
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default — реестр метрик сервиса
+var Default = NewRegistry()
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry хранит метрики и отдаёт их по HTTP
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: метрика " + name + " уже зарегистрирована")
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText записывает все метрики в текстовом формате Prometheus
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// desc — общие для всех типов метрик имя, описание и метки
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s ожидает метки %v, получено %d значений", d.name, d.labels, len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString форматирует метки ряда, extra добавляется последней (le у гистограмм)
+func (d *desc) labelString(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// series — ряды одной метрики по значениям меток
+type series[T any] struct {
+	desc
+	mu     sync.Mutex
+	rows   map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func (s *series[T]) with(values []string) *T {
+	key := s.key(values)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if row, ok := s.rows[key]; ok {
+		return row
+	}
+	row := s.create()
+	s.rows[key] = row
+	s.values[key] = append([]string(nil), values...)
+	return row
+}
+
+// sorted возвращает ключи рядов в стабильном порядке
+func (s *series[T]) sorted() []string {
+	keys := make([]string, 0, len(s.rows))
+	for key := range s.rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newSeries[T any](name, help, kind string, labels []string, create func() *T) *series[T] {
+	return &series[T]{
+		desc:   desc{name: name, help: help, kind: kind, labels: labels},
+		rows:   map[string]*T{},
+		values: map[string][]string{},
+		create: create,
+	}
+}
+
+// Counter — монотонно растущий счётчик
+type Counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (c *Counter) Inc() { c.Add(1) }
+
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.v += v
+	c.mu.Unlock()
+}
+
+type CounterVec struct{ s *series[Counter] }
+
+// NewCounter регистрирует счётчик с метками labels
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{s: newSeries(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(name, v)
+	return v
+}
+
+// With возвращает ряд для значений меток в порядке их объявления
+func (v *CounterVec) With(values ...string) *Counter { return v.s.with(values) }
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+	v.s.header(w)
+	for _, key := range v.s.sorted() {
+		c := v.s.rows[key]
+		c.mu.Lock()
+		fmt.Fprintf(w, "%s%s %s\n", v.s.name, v.s.labelString(v.s.values[key]), formatFloat(c.v))
+		c.mu.Unlock()
+	}
+}
+
+// Gauge — значение, которое может расти и уменьшаться
+type Gauge struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.v = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.v += v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+type GaugeVec struct{ s *series[Gauge] }
+
+// NewGauge регистрирует показатель с метками labels
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{s: newSeries(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(name, v)
+	return v
+}
+
+func (v *GaugeVec) With(values ...string) *Gauge { return v.s.with(values) }
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+	v.s.header(w)
+	for _, key := range v.s.sorted() {
+		g := v.s.rows[key]
+		g.mu.Lock()
+		fmt.Fprintf(w, "%s%s %s\n", v.s.name, v.s.labelString(v.s.values[key]), formatFloat(g.v))
+		g.mu.Unlock()
+	}
+}
+
+// gaugeFunc вычисляет значение в момент чтения метрик
+type gaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc регистрирует показатель, значение которого возвращает fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	g.header(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// Histogram считает наблюдения по корзинам с верхними границами buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+type HistogramVec struct{ s *series[Histogram] }
+
+// NewHistogram регистрирует гистограмму. Если buckets пуст, используются DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	v := &HistogramVec{s: newSeries(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	})}
+	r.register(name, v)
+	return v
+}
+
+func (v *HistogramVec) With(values ...string) *Histogram { return v.s.with(values) }
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.s.mu.Lock()
+	defer v.s.mu.Unlock()
+	v.s.header(w)
+	for _, key := range v.s.sorted() {
+		h := v.s.rows[key]
+		values := v.s.values[key]
+		h.mu.Lock()
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.s.name, v.s.labelString(values, "le", formatFloat(upper)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.s.name, v.s.labelString(values, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.s.name, v.s.labelString(values), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.s.name, v.s.labelString(values), h.count)
+		h.mu.Unlock()
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
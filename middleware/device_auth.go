@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"access-control-system/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceAuth пропускает только контроллер, указанный в пути (:controller_id),
+// с ключом из конфигурации в заголовке X-Device-Key
+func DeviceAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controllerID := c.Param("controller_id")
+		device, ok := config.Current.Devices[controllerID]
+		if !ok || !keyMatches(c.GetHeader("X-Device-Key"), device.Key) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неизвестный контроллер или неверный ключ устройства"})
+			c.Abort()
+			return
+		}
+
+		c.Set("device", controllerID)
+		c.Next()
+	}
+}
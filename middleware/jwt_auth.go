@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"access-control-system/config"
 	"access-control-system/controllers"
 	"access-control-system/models"
 	"net/http"
@@ -8,47 +9,96 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 
 func JWTAuthMiddleware() gin.HandlerFunc {
+	return jwtAuth(false)
+}
+
+// JWTAuthOrQuery принимает токен и из параметра ?token=: EventSource и
+// WebSocket в браузере не умеют передавать заголовок Authorization
+func JWTAuthOrQuery() gin.HandlerFunc {
+	return jwtAuth(true)
+}
+
+func jwtAuth(allowQuery bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		queryToken := ""
+		if allowQuery {
+			queryToken = c.Query("token")
+		}
+		if authHeader == "" && queryToken == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Отсутствует заголовок авторизации"})
 			c.Abort()
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный формат заголовка авторизации"})
-			c.Abort()
-			return
+		tokenString := queryToken
+		if authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный формат заголовка авторизации"})
+				c.Abort()
+				return
+			}
+			tokenString = parts[1]
 		}
 
-		tokenString := parts[1]
-		claims := &controllers.Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte("my_secret_key"), nil
-		})
-		if err != nil || !token.Valid {
+		user, ok := parseToken(tokenString)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный или просроченный токен"})
 			c.Abort()
 			return
 		}
 
-		user := models.User{
-			Role: claims.Role,
-		}
-		if strings.Contains(claims.Identifier, "@") {
-			user.Email = claims.Identifier
-		} else {
-			user.Phone = claims.Identifier
+		// Пользователь, уже проверенный по базе после Identify, не заменяется
+		// данными из токена
+		if current, ok := c.Get("user"); !ok || current.(models.User).ID != user.ID {
+			c.Set("user", user)
 		}
+		c.Next()
+	}
+}
 
-		c.Set("user", user)
+// Identify определяет пользователя по токену, если он передан, но не
+// требует авторизации: маршруты без JWTAuthMiddleware доступны и без токена,
+// а журнал аудита получает автора изменения, когда он известен
+func Identify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			if user, ok := parseToken(token); ok {
+				c.Set("user", user)
+			}
+		}
 		c.Next()
 	}
 }
+
+func parseToken(tokenString string) (models.User, bool) {
+	claims := &controllers.Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Current.JWT.Secret), nil
+	})
+	if err != nil || !token.Valid {
+		return models.User{}, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return models.User{}, false
+	}
+	user := models.User{
+		ID:   id,
+		Role: claims.Role,
+	}
+	if strings.Contains(claims.Identifier, "@") {
+		user.Email = claims.Identifier
+	} else {
+		user.Phone = claims.Identifier
+	}
+	return user, true
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Параметры запроса, значения которых не пишутся в журнал запросов:
+// ?token= несёт JWT для EventSource и WebSocket
+var redactedQueryParams = []string{"token"}
+
+// Logger пишет журнал запросов в формате gin, скрывая значения
+// redactedQueryParams. Запросы к skipPaths не журналируются
+func Logger(skipPaths ...string) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: skipPaths, Formatter: formatLog})
+}
+
+func formatLog(p gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if p.IsOutputColor() {
+		statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+	}
+	if p.Latency > time.Minute {
+		p.Latency = p.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, p.StatusCode, resetColor,
+		p.Latency,
+		p.ClientIP,
+		methodColor, p.Method, resetColor,
+		redactPath(p.Path),
+		p.ErrorMessage,
+	)
+}
+
+// redactPath заменяет значения redactedQueryParams в пути с запросом,
+// не трогая остальные параметры
+func redactPath(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		for _, secret := range redactedQueryParams {
+			if strings.EqualFold(name, secret) {
+				pairs[i] = name + "=[скрыто]"
+			}
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
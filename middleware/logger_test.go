@@ -0,0 +1,21 @@
+package middleware
+
+import "testing"
+
+func TestRedactPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/stream", "/stream"},
+		{"/stream?types=access", "/stream?types=access"},
+		{"/stream?types=access&token=eyJhbGciOi.payload.sig", "/stream?types=access&token=[скрыто]"},
+		{"/stream?%74oken=eyJhbGciOi.payload.sig", "/stream?token=[скрыто]"},
+		{"/stream?token", "/stream?token=[скрыто]"},
+	}
+	for _, tt := range tests {
+		if got := redactPath(tt.path); got != tt.want {
+			t.Errorf("redactPath(%q) = %q, ожидалось %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"access-control-system/config"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedCaller пропускает контроллеры дверей (заголовки X-Device-ID
+// и X-Device-Key) и внутренние сервисы (X-Service-Key) из конфигурации
+func TrustedCaller() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := c.GetHeader("X-Device-ID"); id != "" {
+			device, ok := config.Current.Devices[id]
+			if ok && keyMatches(c.GetHeader("X-Device-Key"), device.Key) {
+				c.Set("device", id)
+				c.Next()
+				return
+			}
+		} else if key := c.GetHeader("X-Service-Key"); key != "" {
+			for name, service := range config.Current.Services {
+				if keyMatches(key, service.Key) {
+					c.Set("service", name)
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Запрос доступен только контроллерам дверей и внутренним сервисам"})
+		c.Abort()
+	}
+}
+
+func keyMatches(got, want string) bool {
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -2,11 +2,31 @@ package models
 
 import (
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Решение, принятое контроллером двери
+const (
+	AccessStatusGranted = "granted"
+	AccessStatusDenied  = "denied"
 )
 
+// AccessLog — проход, зафиксированный контроллером двери без связи с
+// сервером. KeyID, RoomID, AccessTime и Status передаёт контроллер,
+// остальные поля заполняет сервер при сверке
 type AccessLog struct {
-	KeyID      string    `json:"key_id" bson:"key_id" binding:"required"`
-	RoomID     string    `json:"room_id" bson:"room_id" binding:"required"`
-	AccessTime time.Time `json:"access_time" bson:"access_time" binding:"required"`
-	Status     string    `json:"status" bson:"status" binding:"required"` 
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	KeyID      string             `json:"key_id" bson:"key_id" binding:"required"`
+	RoomID     string             `json:"room_id" bson:"room_id" binding:"required"`
+	AccessTime time.Time          `json:"access_time" bson:"access_time" binding:"required"`
+	Status     string             `json:"status" bson:"status" binding:"required"` 
+
+	ControllerID string             `json:"controller_id" bson:"controller_id"`
+	UserID       primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	// ServerReason — решение, которое принял бы сервер по текущим данным
+	ServerReason string `json:"server_reason" bson:"server_reason"`
+	// Mismatch — решение контроллера расходится с решением сервера
+	Mismatch   bool      `json:"mismatch" bson:"mismatch"`
+	UploadedAt time.Time `json:"uploaded_at" bson:"uploaded_at"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "strings"
+
+// SplitAccessRooms разворачивает записи access_rooms, которые могут
+// содержать несколько номеров через запятую
+func SplitAccessRooms(entries []string) []string {
+	var rooms []string
+	for _, entry := range entries {
+		for _, room := range strings.Split(entry, ",") {
+			if room = strings.TrimSpace(room); room != "" {
+				rooms = append(rooms, room)
+			}
+		}
+	}
+	return rooms
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AlertSeverity string
+
+const (
+	SeverityLow      AlertSeverity = "low"
+	SeverityMedium   AlertSeverity = "medium"
+	SeverityHigh     AlertSeverity = "high"
+	SeverityCritical AlertSeverity = "critical"
+)
+
+func (s AlertSeverity) Valid() bool {
+	switch s {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return true
+	}
+	return false
+}
+
+// Типы правил оповещений
+const (
+	// RuleThreshold — не меньше Count событий за Window по одному ключу GroupBy
+	RuleThreshold = "threshold"
+	// RuleOutOfHours — попытки в нерабочее время [From, To) по времени комнаты,
+	// не меньше Count за Window и не меньше чем на MinFloors этажах
+	RuleOutOfHours = "out_of_hours"
+	// RuleImpossibleTravel — проход в другом корпусе быстрее MinTravel после предыдущего
+	RuleImpossibleTravel = "impossible_travel"
+	// RuleAccessAfterDenial — попытка в другой комнате в течение Window после отказа
+	RuleAccessAfterDenial = "access_after_denial"
+)
+
+// Какие решения о доступе учитывает правило
+const (
+	OutcomeAny     = "any"
+	OutcomeGranted = "granted"
+	OutcomeDenied  = "denied"
+)
+
+// Ключи группировки событий правила threshold
+const (
+	GroupByUser     = "user"
+	GroupByRoom     = "room"
+	GroupByBuilding = "building"
+)
+
+// AlertRule — правило оповещения. Используются только поля, нужные его типу
+type AlertRule struct {
+	Name     string        `yaml:"name" json:"name"`
+	Type     string        `yaml:"type" json:"type"`
+	Severity AlertSeverity `yaml:"severity" json:"severity"`
+	// EventTypes — причины решений о доступе. Пусто — любые
+	EventTypes []string `yaml:"event_types" json:"event_types,omitempty"`
+	// Outcome — any, granted или denied. Пусто — any
+	Outcome string        `yaml:"outcome" json:"outcome,omitempty"`
+	GroupBy string        `yaml:"group_by" json:"group_by,omitempty"`
+	Count   int           `yaml:"count" json:"count,omitempty"`
+	Window  time.Duration `yaml:"window" json:"window,omitempty"`
+	// From и To — начало и конец нерабочего времени, "15:04"
+	From      string        `yaml:"from" json:"from,omitempty"`
+	To        string        `yaml:"to" json:"to,omitempty"`
+	MinFloors int           `yaml:"min_floors" json:"min_floors,omitempty"`
+	MinTravel time.Duration `yaml:"min_travel" json:"min_travel,omitempty"`
+	// Cooldown — пауза перед повторным оповещением по тому же ключу.
+	// Пусто — Window, а без него минута
+	Cooldown time.Duration `yaml:"cooldown" json:"cooldown,omitempty"`
+}
+
+// Validate проверяет поля, обязательные для типа правила
+func (r AlertRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("не задано имя правила")
+	}
+	if !r.Severity.Valid() {
+		return fmt.Errorf("%s: severity должен быть low, medium, high или critical", r.Name)
+	}
+	switch r.Outcome {
+	case "", OutcomeAny, OutcomeGranted, OutcomeDenied:
+	default:
+		return fmt.Errorf("%s: outcome должен быть any, granted или denied", r.Name)
+	}
+
+	switch r.Type {
+	case RuleThreshold:
+		if r.Count < 2 || r.Window <= 0 {
+			return fmt.Errorf("%s: для threshold нужны count не меньше 2 и положительный window", r.Name)
+		}
+		switch r.GroupBy {
+		case GroupByUser, GroupByRoom, GroupByBuilding:
+		default:
+			return fmt.Errorf("%s: group_by должен быть user, room или building", r.Name)
+		}
+	case RuleOutOfHours:
+		_, errFrom := time.Parse("15:04", r.From)
+		_, errTo := time.Parse("15:04", r.To)
+		if errFrom != nil || errTo != nil || r.From == r.To {
+			return fmt.Errorf("%s: from и to должны быть разным временем в формате 15:04", r.Name)
+		}
+		if (r.Count > 1 || r.MinFloors > 1) && r.Window <= 0 {
+			return fmt.Errorf("%s: для count или min_floors больше 1 нужен window", r.Name)
+		}
+	case RuleImpossibleTravel:
+		if r.MinTravel <= 0 {
+			return fmt.Errorf("%s: для impossible_travel нужен положительный min_travel", r.Name)
+		}
+	case RuleAccessAfterDenial:
+		if r.Window <= 0 {
+			return fmt.Errorf("%s: для access_after_denial нужен положительный window", r.Name)
+		}
+	default:
+		return fmt.Errorf("%s: неизвестный тип правила %q", r.Name, r.Type)
+	}
+	return nil
+}
+
+type AlertStatus string
+
+const (
+	AlertOpen         AlertStatus = "open"
+	AlertAcknowledged AlertStatus = "acknowledged"
+	AlertResolved     AlertStatus = "resolved"
+)
+
+// Alert — срабатывание правила оповещения
+type Alert struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Rule     string             `json:"rule" bson:"rule"`
+	RuleType string             `json:"rule_type" bson:"rule_type"`
+	Severity AlertSeverity      `json:"severity" bson:"severity"`
+	Message  string             `json:"message" bson:"message"`
+	UserID   primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Room     string             `json:"room,omitempty" bson:"room,omitempty"`
+	Building string             `json:"building,omitempty" bson:"building,omitempty"`
+	// EventIDs — номера событий потока, вызвавших срабатывание
+	EventIDs  []uint64    `json:"event_ids" bson:"event_ids"`
+	Status    AlertStatus `json:"status" bson:"status"`
+	CreatedAt time.Time   `json:"created_at" bson:"created_at"`
+
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty" bson:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty" bson:"acknowledged_at,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty" bson:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	Note           string     `json:"note,omitempty" bson:"note,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Действия в журнале аудита
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditChange — изменение поля сущности. Before и After — значения в JSON,
+// отсутствуют при создании и удалении соответственно
+type AuditChange struct {
+	Field  string          `json:"field" bson:"field"`
+	Before json.RawMessage `json:"before,omitempty" bson:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// AuditEntry — запись журнала аудита. Записи образуют цепочку: Hash
+// вычисляется от содержимого записи и PrevHash, поэтому изменение или
+// удаление записи обнаруживается при проверке
+type AuditEntry struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// Seq — номер записи в цепочке, начиная с 1, без пропусков
+	Seq       int64     `json:"seq" bson:"seq"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	// Actor — email или телефон из токена, контроллер, сервис или system
+	Actor     string        `json:"actor" bson:"actor"`
+	Action    string        `json:"action" bson:"action"`
+	Entity    string        `json:"entity" bson:"entity"`
+	EntityID  string        `json:"entity_id" bson:"entity_id"`
+	Changes   []AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	RequestID string        `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	SourceIP  string        `json:"source_ip,omitempty" bson:"source_ip,omitempty"`
+	PrevHash  string        `json:"prev_hash" bson:"prev_hash"`
+	Hash      string        `json:"hash" bson:"hash"`
+}
@@ -6,10 +6,27 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Log — событие журнала. Текст известных типов событий собирается при
+// чтении из полей и Params на языке запроса, Message хранится только
+// для произвольных событий и записей, сделанных до появления полей
 type Log struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	EventType string             `json:"event_type" bson:"event_type"` 
-	Message   string             `json:"message" bson:"message"`
-	UserID    primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
-	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+	Message   string             `json:"message" bson:"message,omitempty"`
+	// UserID — пользователь, к которому относится событие
+	UserID primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	// Actor — кто вызвал событие: email или телефон из токена
+	Actor        string `json:"actor,omitempty" bson:"actor,omitempty"`
+	Room         string `json:"room,omitempty" bson:"room,omitempty"`
+	ControllerID string `json:"controller_id,omitempty" bson:"controller_id,omitempty"`
+	// Reason — код решения о доступе
+	Reason     string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	ScheduleID *primitive.ObjectID `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	SourceIP   string              `json:"source_ip,omitempty" bson:"source_ip,omitempty"`
+	// Params — остальные значения для текста события
+	Params    map[string]string `json:"params,omitempty" bson:"params,omitempty"`
+	Timestamp time.Time         `json:"timestamp" bson:"timestamp"`
+	// RestoredAt — когда запись восстановлена из архива. Такие записи
+	// удаляются повторно без архивирования
+	RestoredAt *time.Time `json:"restored_at,omitempty" bson:"restored_at,omitempty"`
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Код страны по умолчанию для номеров, введённых без международного префикса
+const defaultPhoneCountryCode = "7"
+
+var ErrInvalidPhone = errors.New("некорректный номер телефона")
+
+// NormalizeEmail приводит адрес электронной почты к нижнему регистру
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone приводит номер телефона к формату E.164 (+77011234567).
+// Номера с национальным префиксом 8 и без кода страны считаются казахстанскими
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return "", nil
+	}
+
+	international := strings.HasPrefix(phone, "+")
+	var digits strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" -().", r):
+		// Префикс + допустим только первым символом
+		case r == '+' && i == 0:
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	d := digits.String()
+
+	switch {
+	case international:
+	case strings.HasPrefix(d, "00"):
+		d = d[2:]
+	case len(d) == 11 && d[0] == '8':
+		d = defaultPhoneCountryCode + d[1:]
+	case len(d) == 11 && strings.HasPrefix(d, defaultPhoneCountryCode):
+	case len(d) == 10:
+		d = defaultPhoneCountryCode + d
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + d, nil
+}
+
+// Normalize приводит контактные данные запроса к каноническому виду
+func (r *UserRequest) Normalize() error {
+	r.Email = NormalizeEmail(r.Email)
+	phone, err := NormalizePhone(r.Phone)
+	if err != nil {
+		return err
+	}
+	r.Phone = phone
+	return nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      bool
+	}{
+		{in: "+7 (701) 123-45-67", want: "+77011234567"},
+		{in: "  +7 701 123 45 67 ", want: "+77011234567"},
+		{in: "8 701 123 45 67", want: "+77011234567"},
+		{in: "7011234567", want: "+77011234567"},
+		{in: "0049 30 1234567", want: "+49301234567"},
+		{in: "", want: ""},
+		{in: "++7 701 123 45 67", err: true},
+		{in: "+ + 7 701 123 45 67", err: true},
+		{in: "7 +701 123 45 67", err: true},
+		{in: "+7 701 abc", err: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("NormalizePhone(%q) = %q, ожидалась ошибка", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v, ожидалось %q", tt.in, got, err, tt.want)
+		}
+	}
+}
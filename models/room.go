@@ -7,5 +7,23 @@ type Room struct {
 	RoomNumber         string             `bson:"room_number" json:"room_number"`
 	Floor              int                `bson:"floor" json:"floor"`
 	AccessControllerID string             `bson:"access_controller_id" json:"access_controller_id"`
+	Building           string             `bson:"building,omitempty" json:"building,omitempty"`
+	Timezone           string             `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// StalePolicy — что делать с дверью, когда данные о доступе устарели
+	// и база недоступна. Пусто — политика по умолчанию из конфигурации
+	StalePolicy StalePolicy `bson:"stale_policy,omitempty" json:"stale_policy,omitempty"`
+}
+
+type StalePolicy string
+
+const (
+	// StaleFailClosed — отказывать в доступе
+	StaleFailClosed StalePolicy = "fail_closed"
+	// StaleFailOpen — пропускать всех (например, эвакуационные выходы)
+	StaleFailOpen StalePolicy = "fail_open"
+)
+
+func (p StalePolicy) Valid() bool {
+	return p == StaleFailClosed || p == StaleFailOpen
 }
 	
\ No newline at end of file
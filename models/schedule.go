@@ -1,6 +1,11 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Schedule struct {
 	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
@@ -13,3 +18,24 @@ type Schedule struct {
 	RoomNumber string             `json:"room_number" bson:"room_number"`
 	Subject    string             `json:"subject" bson:"subject"`
 }
+
+// Interval возвращает начало и конец занятия в день day в часовом поясе day.
+// Время расписания — "15:04" или "15:04:05"
+func (s Schedule) Interval(day time.Time) (start, end time.Time, err error) {
+	if start, err = clockTime(day, s.StartTime); err != nil {
+		return start, end, err
+	}
+	end, err = clockTime(day, s.EndTime)
+	return start, end, err
+}
+
+func clockTime(day time.Time, clock string) (time.Time, error) {
+	if len(clock) == 5 {
+		clock += ":00"
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", day.Format("2006-01-02")+" "+clock, day.Location())
+	if err != nil {
+		return t, fmt.Errorf("некорректное время %q", clock)
+	}
+	return t, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -12,18 +14,41 @@ const (
 	RoleStaff   Role = "персонал"
 )
 
+type UserStatus string
+
+const (
+	StatusActive      UserStatus = "active"
+	StatusSuspended   UserStatus = "suspended"
+	StatusDeactivated UserStatus = "deactivated"
+)
+
 type User struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	KeyID       string             `json:"key_id" bson:"key_id"`
-	FirstName   string             `json:"first_name" bson:"first_name"`
-	SecondName  string             `json:"second_name" bson:"second_name"`
-	Email       string             `json:"email" bson:"email"`
-	AccessRooms []string           `json:"access_rooms" bson:"access_rooms"`
-	Photos      []string           `json:"photos,omitempty" bson:"photos,omitempty"`
-	Address     string             `json:"address,omitempty" bson:"address,omitempty"`
-	Phone       string             `json:"phone,omitempty" bson:"phone,omitempty"`
-	Country     string             `json:"country,omitempty" bson:"country,omitempty"`
-	City        string             `json:"city,omitempty" bson:"city,omitempty"`
-	Role        Role               `json:"role" bson:"role"`
-	Password    string             `json:"password,omitempty" bson:"password"`
+	ID                 primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	KeyID              string             `json:"key_id" bson:"key_id"`
+	FirstName          string             `json:"first_name" bson:"first_name"`
+	SecondName         string             `json:"second_name" bson:"second_name"`
+	Email              string             `json:"email" bson:"email"`
+	AccessRooms        []string           `json:"access_rooms" bson:"access_rooms"`
+	Photos             []string           `json:"photos,omitempty" bson:"photos,omitempty"`
+	Address            string             `json:"address,omitempty" bson:"address,omitempty"`
+	Phone              string             `json:"phone,omitempty" bson:"phone,omitempty"`
+	Country            string             `json:"country,omitempty" bson:"country,omitempty"`
+	City               string             `json:"city,omitempty" bson:"city,omitempty"`
+	Role               Role               `json:"role" bson:"role"`
+	Password           string             `json:"-" bson:"password"`
+	Status             UserStatus         `json:"status" bson:"status,omitempty"`
+	DeactivatedAt      *time.Time         `json:"deactivated_at,omitempty" bson:"deactivated_at,omitempty"`
+	MustChangePassword bool               `json:"must_change_password,omitempty" bson:"must_change_password,omitempty"`
+}
+
+// Документы, созданные до появления статуса, его не содержат и считаются активными
+func (u User) EffectiveStatus() UserStatus {
+	if u.Status == "" {
+		return StatusActive
+	}
+	return u.Status
+}
+
+func (u User) IsActive() bool {
+	return u.EffectiveStatus() == StatusActive
 }
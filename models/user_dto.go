@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRequest — входные данные для создания и обновления пользователя
+type UserRequest struct {
+	FirstName   string   `json:"first_name"`
+	SecondName  string   `json:"second_name"`
+	Email       string   `json:"email"`
+	AccessRooms []string `json:"access_rooms"`
+	Photos      []string `json:"photos"`
+	Address     string   `json:"address"`
+	Phone       string   `json:"phone"`
+	Country     string   `json:"country"`
+	City        string   `json:"city"`
+	Role        Role     `json:"role"`
+	Password    string   `json:"password"`
+}
+
+func (r UserRequest) ToUser() User {
+	return User{
+		FirstName:   r.FirstName,
+		SecondName:  r.SecondName,
+		Email:       r.Email,
+		AccessRooms: r.AccessRooms,
+		Photos:      r.Photos,
+		Address:     r.Address,
+		Phone:       r.Phone,
+		Country:     r.Country,
+		City:        r.City,
+		Role:        r.Role,
+		Password:    r.Password,
+	}
+}
+
+// UserResponse — представление пользователя для клиентов.
+// Структура намеренно не содержит полей с учётными данными
+type UserResponse struct {
+	ID                 primitive.ObjectID `json:"id"`
+	KeyID              string             `json:"key_id"`
+	FirstName          string             `json:"first_name"`
+	SecondName         string             `json:"second_name"`
+	Email              string             `json:"email"`
+	AccessRooms        []string           `json:"access_rooms"`
+	Photos             []string           `json:"photos,omitempty"`
+	Address            string             `json:"address,omitempty"`
+	Phone              string             `json:"phone,omitempty"`
+	Country            string             `json:"country,omitempty"`
+	City               string             `json:"city,omitempty"`
+	Role               Role               `json:"role"`
+	Status             UserStatus         `json:"status"`
+	DeactivatedAt      *time.Time         `json:"deactivated_at,omitempty"`
+	MustChangePassword bool               `json:"must_change_password,omitempty"`
+}
+
+func NewUserResponse(u User) UserResponse {
+	return UserResponse{
+		ID:                 u.ID,
+		KeyID:              u.KeyID,
+		FirstName:          u.FirstName,
+		SecondName:         u.SecondName,
+		Email:              u.Email,
+		AccessRooms:        u.AccessRooms,
+		Photos:             u.Photos,
+		Address:            u.Address,
+		Phone:              u.Phone,
+		Country:            u.Country,
+		City:               u.City,
+		Role:               u.Role,
+		Status:             u.EffectiveStatus(),
+		DeactivatedAt:      u.DeactivatedAt,
+		MustChangePassword: u.MustChangePassword,
+	}
+}
+
+func NewUserResponses(users []User) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		responses = append(responses, NewUserResponse(u))
+	}
+	return responses
+}
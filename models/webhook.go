@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookAllEvents в EventTypes подписывает на все события
+const WebhookAllEvents = "*"
+
+// Webhook — подписка внешней системы на события. Тело каждого запроса
+// подписывается HMAC-SHA256 с ключом Secret
+type Webhook struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name"`
+	URL        string             `json:"url" bson:"url"`
+	EventTypes []string           `json:"event_types" bson:"event_types"`
+	Secret     string             `json:"-" bson:"secret"`
+	Active     bool               `json:"active" bson:"active"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead — попытки исчерпаны, доставку можно повторить вручную
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery — отправка одного события одной подписке
+type WebhookDelivery struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WebhookID primitive.ObjectID `json:"webhook_id" bson:"webhook_id"`
+	EventType string             `json:"event_type" bson:"event_type"`
+	// Payload — тело запроса. Не меняется между попытками
+	Payload       string           `json:"payload" bson:"payload"`
+	Status        DeliveryStatus   `json:"status" bson:"status"`
+	Test          bool             `json:"test,omitempty" bson:"test,omitempty"`
+	Attempts      []WebhookAttempt `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time        `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt     time.Time        `json:"created_at" bson:"created_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	// RequeuedAt — когда доставка из dead была возвращена в очередь.
+	// Попытки до этого момента не учитываются в лимите
+	RequeuedAt *time.Time `json:"requeued_at,omitempty" bson:"requeued_at,omitempty"`
+}
+
+// AttemptsSinceRequeue — число попыток после последнего возврата в очередь
+func (d WebhookDelivery) AttemptsSinceRequeue() int {
+	if d.RequeuedAt == nil {
+		return len(d.Attempts)
+	}
+	n := 0
+	for _, a := range d.Attempts {
+		if !a.At.Before(*d.RequeuedAt) {
+			n++
+		}
+	}
+	return n
+}
+
+// WebhookAttempt — результат одной попытки доставки
+type WebhookAttempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms" bson:"duration_ms"`
+}
@@ -0,0 +1,227 @@
+// Package pdf строит простые текстовые отчёты PDF без внешних зависимостей:
+// заголовки, абзацы и таблицы. Используются стандартные шрифты PDF, в них
+// нет кириллицы, поэтому текст транслитерируется в латиницу
+package pdf
+
+import (
+	"access-control-system/search"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Размеры страницы A4 и поля в пунктах
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+	lineGap    = 1.35
+)
+
+// Шрифты ресурсов страницы
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontMono    = "F3"
+)
+
+// maxCellWidth — предел ширины столбца таблицы в символах
+const maxCellWidth = 40
+
+// Document — отчёт, собираемый сверху вниз. Страницы добавляются
+// автоматически, номера страниц проставляются при записи
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func New(title string) *Document {
+	d := &Document{title: title}
+	d.Heading(title)
+	return d
+}
+
+// Heading добавляет заголовок раздела
+func (d *Document) Heading(text string) {
+	d.space(8)
+	d.line(fontBold, 14, margin, Latin(text))
+	d.space(4)
+}
+
+// Text добавляет абзац с переносом по словам
+func (d *Document) Text(text string) {
+	const size = 10
+	// Средняя ширина символа Helvetica — около половины кегля
+	perLine := int((pageWidth - 2*margin) / (size * 0.5))
+	for _, line := range wrap(Latin(text), perLine) {
+		d.line(fontRegular, size, margin, line)
+	}
+}
+
+// Table добавляет таблицу моноширинным шрифтом. Ширина столбцов
+// подбирается по содержимому, длинные значения обрезаются
+func (d *Document) Table(header []string, rows [][]string) {
+	const size = 8
+	widths := make([]int, len(header))
+	cells := func(row []string) []string {
+		out := make([]string, len(header))
+		for i := range header {
+			if i < len(row) {
+				out[i] = Latin(row[i])
+			}
+			if n := len(out[i]); n > widths[i] {
+				widths[i] = min(n, maxCellWidth)
+			}
+		}
+		return out
+	}
+	head := cells(header)
+	body := make([][]string, len(rows))
+	for i, row := range rows {
+		body[i] = cells(row)
+	}
+
+	format := func(row []string) string {
+		var b strings.Builder
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				cell = cell[:widths[i]-1] + "~"
+			}
+			b.WriteString(cell)
+			b.WriteString(strings.Repeat(" ", widths[i]-len(cell)+2))
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	total := 0
+	for _, w := range widths {
+		total += w + 2
+	}
+
+	d.space(2)
+	d.line(fontMono, size, margin, format(head))
+	d.line(fontMono, size, margin, strings.Repeat("-", total))
+	for _, row := range body {
+		d.line(fontMono, size, margin, format(row))
+	}
+	d.space(6)
+}
+
+func (d *Document) space(points float64) {
+	if len(d.pages) > 0 {
+		d.y -= points
+	}
+}
+
+// line выводит строку, начиная новую страницу, если текущая заполнена
+func (d *Document) line(font string, size, x float64, text string) {
+	if len(d.pages) == 0 || d.y-size*lineGap < margin+20 {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = pageHeight - margin
+	}
+	d.y -= size * lineGap
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, escape(text))
+}
+
+// WriteTo записывает документ в формате PDF 1.4
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.line(fontRegular, 10, margin, "")
+	}
+
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1 — каталог, 2 — дерево страниц, 3-5 — шрифты, 6 — сведения о документе,
+	// далее пары объектов страница и её содержимое
+	const firstPage = 7
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, base := range []string{"Helvetica", "Helvetica-Bold", "Courier"} {
+		object("<< /Type /Font /Subtype /Type1 /BaseFont /" + base + " /Encoding /WinAnsiEncoding >>")
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (access-control-system) >>", escape(Latin(d.title))))
+
+	for i, page := range d.pages {
+		fmt.Fprintf(page, "BT /%s 8 Tf %.2f %.2f Td (%s) Tj ET\n", fontRegular, pageWidth-margin-60, margin-20.0,
+			escape(fmt.Sprintf("Page %d / %d", i+1, len(d.pages))))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Замены символов, которых нет в стандартных шрифтах
+var replacements = map[rune]string{
+	'—': "-", '–': "-", '«': "\"", '»': "\"", '№': "No", '…': "...", '’': "'",
+}
+
+// Latin транслитерирует кириллицу с сохранением регистра и заменяет
+// остальные символы вне ASCII
+func Latin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Cyrillic, r):
+			latin := search.Transliterate(string(r))
+			if unicode.IsUpper(r) && latin != "" {
+				latin = strings.ToUpper(latin[:1]) + latin[1:]
+			}
+			b.WriteString(latin)
+		case replacements[r] != "":
+			b.WriteString(replacements[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", " ", "\n", " ").Replace(s)
+}
+
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,403 @@
+// Package policy хранит в памяти данные, нужные для решений о доступе:
+// пользователей с их правами, комнаты и расписания. Кэш позволяет
+// открывать двери без запросов к базе и переживать её недоступность
+package policy
+
+import (
+	"access-control-system/clock"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Изменения, пришедшие подряд, применяются одной перезагрузкой
+	reloadDebounce = 200 * time.Millisecond
+	// Пауза перед повторной подпиской на поток изменений
+	watchRetryDelay = time.Minute
+	// Пока работает поток изменений, кэш перезагружается целиком не чаще
+	// этого интервала, а между перезагрузками применяются изменения из потока
+	fullReloadInterval = 15 * time.Minute
+)
+
+var (
+	cacheReloads = metrics.Default.NewCounter("policy_cache_reloads_total",
+		"Перезагрузки кэша политик доступа по причине и результату", "trigger", "result")
+	cacheEntries = metrics.Default.NewGauge("policy_cache_entries",
+		"Записи в кэше политик доступа", "kind")
+	cacheLoadedAt = metrics.Default.NewGauge("policy_cache_loaded_timestamp_seconds",
+		"Время последней успешной загрузки кэша политик (Unix)")
+	cacheWatching = metrics.Default.NewGauge("policy_cache_change_stream_active",
+		"1, если кэш получает изменения из потока изменений MongoDB")
+	cacheChanges = metrics.Default.NewCounter("policy_cache_changes_total",
+		"Изменения документов, применённые к кэшу политик из потока изменений", "collection", "result")
+)
+
+type scheduleKey struct {
+	userID primitive.ObjectID
+	room   string
+	day    string
+}
+
+// Snapshot — согласованный на момент загрузки срез данных. Не изменяется
+// после создания и безопасен для одновременного чтения
+type Snapshot struct {
+	// LoadedAt — момент, на который данные снимка актуальны
+	LoadedAt time.Time
+
+	users     map[primitive.ObjectID]models.User
+	rooms     map[string]models.Room
+	schedules map[scheduleKey][]models.Schedule
+	// scheduleKeys — где в schedules лежит расписание с данным ID
+	scheduleKeys map[primitive.ObjectID]scheduleKey
+}
+
+func (s *Snapshot) User(id primitive.ObjectID) (models.User, bool) {
+	u, ok := s.users[id]
+	return u, ok
+}
+
+func (s *Snapshot) Room(number string) (models.Room, bool) {
+	r, ok := s.rooms[number]
+	return r, ok
+}
+
+// Schedules возвращает расписания пользователя в комнате на день недели
+func (s *Snapshot) Schedules(userID primitive.ObjectID, room, day string) []models.Schedule {
+	return s.schedules[scheduleKey{userID: userID, room: room, day: day}]
+}
+
+// Cache держит последний Snapshot и обновляет его по изменениям в базе
+type Cache struct {
+	store  *repository.Store
+	clock  clock.Clock
+	maxAge time.Duration
+	poll   time.Duration
+
+	snapshot atomic.Pointer[Snapshot]
+	changed  chan struct{}
+	// watching — поток изменений открыт и доставляет изменения
+	watching atomic.Bool
+
+	// reloadMu упорядочивает перезагрузки и применение изменений
+	reloadMu   sync.Mutex
+	fullLoadAt time.Time
+	// watchedSince — когда открыт текущий поток изменений. Снимок можно
+	// продлевать, только если полная загрузка началась не раньше: иначе
+	// изменения между ними могли быть пропущены
+	watchedSince time.Time
+}
+
+// New создаёт пустой кэш. Данные загружаются в Run или Refresh.
+// Снимок старше maxAge не используется для обычных решений
+func New(store *repository.Store, clk clock.Clock, maxAge, poll time.Duration) *Cache {
+	return &Cache{
+		store:   store,
+		clock:   clk,
+		maxAge:  maxAge,
+		poll:    poll,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Snapshot возвращает последний снимок (nil, если данные ещё не загружены)
+// и признак того, что он не старше maxAge. Безопасен для nil-кэша
+func (c *Cache) Snapshot() (*Snapshot, bool) {
+	if c == nil {
+		return nil, false
+	}
+	s := c.snapshot.Load()
+	if s == nil {
+		return nil, false
+	}
+	return s, c.clock.Now().Sub(s.LoadedAt) <= c.maxAge
+}
+
+// Age возвращает возраст снимка или -1, если данных нет
+func (c *Cache) Age() time.Duration {
+	s, _ := c.Snapshot()
+	if s == nil {
+		return -1
+	}
+	return c.clock.Now().Sub(s.LoadedAt)
+}
+
+// Refresh загружает данные из базы и заменяет снимок
+func (c *Cache) Refresh(ctx context.Context) error {
+	return c.reload(ctx, "manual")
+}
+
+func (c *Cache) reload(ctx context.Context, trigger string) error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	s, err := c.load(ctx)
+	if err != nil {
+		cacheReloads.With(trigger, "error").Inc()
+		return err
+	}
+	c.fullLoadAt = s.LoadedAt
+	c.publish(s)
+	cacheReloads.With(trigger, "ok").Inc()
+	return nil
+}
+
+func (c *Cache) publish(s *Snapshot) {
+	c.snapshot.Store(s)
+	cacheLoadedAt.With().Set(float64(s.LoadedAt.Unix()))
+	cacheEntries.With("users").Set(float64(len(s.users)))
+	cacheEntries.With("rooms").Set(float64(len(s.rooms)))
+	cacheEntries.With("schedules").Set(float64(len(s.scheduleKeys)))
+}
+
+// load читает пользователей (без хэшей паролей), комнаты и недельные
+// расписания — в них всегда входит «сегодня» любого часового пояса
+func (c *Cache) load(ctx context.Context) (*Snapshot, error) {
+	s := &Snapshot{
+		LoadedAt:     c.clock.Now(),
+		users:        map[primitive.ObjectID]models.User{},
+		rooms:        map[string]models.Room{},
+		schedules:    map[scheduleKey][]models.Schedule{},
+		scheduleKeys: map[primitive.ObjectID]scheduleKey{},
+	}
+
+	err := c.store.Users.Each(ctx, repository.UserFilter{All: true}, func(u models.User) error {
+		s.users[u.ID] = u
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rooms, err := c.store.Rooms.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rooms {
+		s.rooms[r.RoomNumber] = r
+	}
+
+	schedules, err := c.store.Schedules.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sch := range schedules {
+		s.addSchedule(sch)
+	}
+	return s, nil
+}
+
+func (s *Snapshot) addSchedule(sch models.Schedule) {
+	key := scheduleKey{userID: sch.UserID, room: sch.RoomNumber, day: sch.Day}
+	s.schedules[key] = append(s.schedules[key], sch)
+	s.scheduleKeys[sch.ID] = key
+}
+
+// removeSchedule убирает расписание id, не изменяя срезы, которые могут
+// читать обработчики предыдущего снимка
+func (s *Snapshot) removeSchedule(id primitive.ObjectID) {
+	key, ok := s.scheduleKeys[id]
+	if !ok {
+		return
+	}
+	delete(s.scheduleKeys, id)
+	kept := make([]models.Schedule, 0, len(s.schedules[key]))
+	for _, sch := range s.schedules[key] {
+		if sch.ID != id {
+			kept = append(kept, sch)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.schedules, key)
+		return
+	}
+	s.schedules[key] = kept
+}
+
+// apply применяет к снимку изменение одного документа: перечитывает его
+// из базы и создаёт новый снимок, копируя только затронутую коллекцию.
+// Изменение без ID (коллекция удалена или хранилище не сообщает ID)
+// запрашивает полную перезагрузку
+func (c *Cache) apply(ctx context.Context, change repository.Change) error {
+	if change.ID.IsZero() {
+		c.requestReload()
+		return nil
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	cur := c.snapshot.Load()
+	if cur == nil {
+		// Снимок ещё не загружен, изменение войдёт в первую загрузку
+		return nil
+	}
+	next := *cur
+	next.LoadedAt = c.clock.Now()
+
+	switch change.Collection {
+	case repository.UsersCollection:
+		user, err := c.store.Users.FindByID(ctx, change.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		next.users = maps.Clone(cur.users)
+		if err != nil || change.Deleted {
+			delete(next.users, change.ID)
+		} else {
+			user.Password = ""
+			next.users[user.ID] = user
+		}
+	case repository.RoomsCollection:
+		room, err := c.store.Rooms.FindByID(ctx, change.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		next.rooms = maps.Clone(cur.rooms)
+		for number, r := range next.rooms {
+			if r.ID == change.ID {
+				delete(next.rooms, number)
+			}
+		}
+		if err == nil && !change.Deleted {
+			next.rooms[room.RoomNumber] = room
+		}
+	case repository.SchedulesCollection:
+		sch, err := c.store.Schedules.FindByID(ctx, change.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		next.schedules = maps.Clone(cur.schedules)
+		next.scheduleKeys = maps.Clone(cur.scheduleKeys)
+		next.removeSchedule(change.ID)
+		if err == nil && !change.Deleted {
+			next.addSchedule(sch)
+		}
+	default:
+		return nil
+	}
+	c.publish(&next)
+	return nil
+}
+
+// touch продлевает актуальность снимка, пока поток изменений доставляет
+// все изменения с последней полной загрузки. Возвращает false, если пора
+// перезагрузить кэш целиком
+func (c *Cache) touch() bool {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	cur := c.snapshot.Load()
+	now := c.clock.Now()
+	if cur == nil || !c.watching.Load() || c.fullLoadAt.Before(c.watchedSince) ||
+		now.Sub(c.fullLoadAt) >= fullReloadInterval {
+		return false
+	}
+	next := *cur
+	next.LoadedAt = now
+	c.publish(&next)
+	return true
+}
+
+// Invalidate запрашивает перезагрузку кэша после изменения пользователей,
+// комнат или расписаний, не дожидаясь её. Пока поток изменений открыт,
+// изменение придёт из него и перезагрузка не выполняется
+func (c *Cache) Invalidate() {
+	if c == nil || c.watching.Load() {
+		return
+	}
+	c.requestReload()
+}
+
+func (c *Cache) requestReload() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run загружает кэш и поддерживает его актуальным до отмены ctx:
+// по потоку изменений, если он доступен, иначе перезагрузкой по таймеру
+func (c *Cache) Run(ctx context.Context) {
+	if err := c.reload(ctx, "startup"); err != nil {
+		log.Printf("Кэш политик доступа не загружен: %v", err)
+	}
+
+	go c.watch(ctx)
+
+	ticker := time.NewTicker(c.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.touch() {
+				c.reloadLogged(ctx, "poll")
+			}
+		case <-c.changed:
+			select {
+			case <-time.After(reloadDebounce):
+			case <-ctx.Done():
+				return
+			}
+			c.reloadLogged(ctx, "change")
+		}
+	}
+}
+
+func (c *Cache) reloadLogged(ctx context.Context, trigger string) {
+	if err := c.reload(ctx, trigger); err != nil && ctx.Err() == nil {
+		log.Printf("Ошибка обновления кэша политик доступа (%s): %v", trigger, err)
+	}
+}
+
+// watch подписывается на изменения пользователей, комнат и расписаний.
+// Пока поток изменений недоступен, кэш обновляется только по таймеру
+func (c *Cache) watch(ctx context.Context) {
+	for {
+		err := c.store.Watch(ctx, c.watchOpened, func(change repository.Change) {
+			if err := c.apply(ctx, change); err != nil {
+				cacheChanges.With(change.Collection, "error").Inc()
+				if ctx.Err() == nil {
+					log.Printf("Ошибка применения изменения %s %s к кэшу политик: %v", change.Collection, change.ID.Hex(), err)
+				}
+				c.requestReload()
+				return
+			}
+			cacheChanges.With(change.Collection, "ok").Inc()
+		}, repository.UsersCollection, repository.RoomsCollection, repository.SchedulesCollection)
+		c.watching.Store(false)
+		cacheWatching.With().Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Поток изменений недоступен, кэш политик обновляется раз в %s: %v", c.poll, err)
+
+		select {
+		case <-time.After(watchRetryDelay):
+			// Изменения, пропущенные без подписки, подхватит перезагрузка
+			c.requestReload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchOpened отмечает, что поток изменений открыт, и запрашивает полную
+// перезагрузку: изменения до открытия потока в нём не придут
+func (c *Cache) watchOpened() {
+	c.reloadMu.Lock()
+	c.watchedSince = c.clock.Now()
+	c.reloadMu.Unlock()
+	c.watching.Store(true)
+	cacheWatching.With().Set(1)
+	c.requestReload()
+}
@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"access-control-system/clock"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyChanges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(now)
+	store := repository.NewMemoryStore()
+
+	user := models.User{ID: primitive.NewObjectID(), Email: "ivan@example.com", Password: "hash", Status: models.StatusActive}
+	if err := store.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	room := models.Room{ID: primitive.NewObjectID(), RoomNumber: "101"}
+	if err := store.Rooms.CreateMany(ctx, []models.Room{room}); err != nil {
+		t.Fatal(err)
+	}
+	schedule := models.Schedule{ID: primitive.NewObjectID(), UserID: user.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "101"}
+	if err := store.Schedules.Create(ctx, &schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(store, clk, time.Minute, time.Minute)
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := c.Snapshot()
+
+	clk.Advance(time.Minute)
+	if err := store.Users.Update(ctx, user.ID, map[string]interface{}{"status": models.StatusSuspended}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(ctx, repository.Change{Collection: repository.UsersCollection, ID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	snap, fresh := c.Snapshot()
+	if got, _ := snap.User(user.ID); got.Status != models.StatusSuspended || got.Password != "" {
+		t.Fatalf("пользователь в кэше: статус %s, пароль %q", got.Status, got.Password)
+	}
+	if !fresh || !snap.LoadedAt.Equal(clk.Now()) {
+		t.Fatalf("снимок не обновлён: %s", snap.LoadedAt)
+	}
+	if got, _ := before.User(user.ID); got.Status != models.StatusActive {
+		t.Fatal("изменение попало в предыдущий снимок")
+	}
+
+	if err := store.Schedules.Update(ctx, schedule.ID, map[string]interface{}{"day": "Tuesday"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(ctx, repository.Change{Collection: repository.SchedulesCollection, ID: schedule.ID}); err != nil {
+		t.Fatal(err)
+	}
+	snap, _ = c.Snapshot()
+	if len(snap.Schedules(user.ID, "101", "Monday")) != 0 || len(snap.Schedules(user.ID, "101", "Tuesday")) != 1 {
+		t.Fatal("расписание не перенесено на вторник")
+	}
+	if len(before.Schedules(user.ID, "101", "Monday")) != 1 {
+		t.Fatal("изменение расписания попало в предыдущий снимок")
+	}
+
+	if err := store.Rooms.Delete(ctx, room.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(ctx, repository.Change{Collection: repository.RoomsCollection, ID: room.ID, Deleted: true}); err != nil {
+		t.Fatal(err)
+	}
+	snap, _ = c.Snapshot()
+	if _, ok := snap.Room("101"); ok {
+		t.Fatal("удалённая комната осталась в кэше")
+	}
+}
+
+func TestTouchWhileWatching(t *testing.T) {
+	now := time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(now)
+	c := New(repository.NewMemoryStore(), clk, time.Minute, time.Minute)
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(2 * time.Minute)
+	if c.touch() {
+		t.Fatal("снимок продлён без потока изменений")
+	}
+	// Поток открыт после загрузки: изменения между ними могли быть потеряны
+	c.watchOpened()
+	if c.touch() {
+		t.Fatal("снимок продлён без загрузки после открытия потока")
+	}
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(2 * time.Minute)
+	if !c.touch() {
+		t.Fatal("снимок не продлён при активном потоке изменений")
+	}
+	if _, fresh := c.Snapshot(); !fresh {
+		t.Fatal("продлённый снимок устарел")
+	}
+	clk.Advance(fullReloadInterval)
+	if c.touch() {
+		t.Fatal("полная перезагрузка не запрошена после fullReloadInterval")
+	}
+}
+
+// Кэш считается подписанным, только пока поток изменений открыт
+func TestWatchingFollowsStream(t *testing.T) {
+	c := New(repository.NewMemoryStore(), clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)), time.Minute, time.Minute)
+	if c.watching.Load() {
+		t.Fatal("кэш подписан до открытия потока")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.watch(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.watching.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("поток открыт, а кэш не подписан")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-c.changed:
+	default:
+		t.Fatal("после открытия потока не запрошена перезагрузка")
+	}
+	cancel()
+	<-done
+	if c.watching.Load() {
+		t.Fatal("кэш подписан после закрытия потока")
+	}
+}
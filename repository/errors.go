@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrNotFound = errors.New("документ не найден")
+	// ErrConflict возвращается, если текущее состояние документа не допускает изменение
+	ErrConflict = errors.New("состояние документа не допускает изменение")
+)
+
+// DuplicateKeyError — нарушение уникального индекса
+type DuplicateKeyError struct {
+	Field string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("значение поля '%s' уже используется", e.Field)
+}
+
+const duplicateKeyCode = 11000
+
+// Соответствие имён уникальных индексов полям, которые они защищают
+var uniqueIndexFields = map[string]string{
+	"email_unique":       "email",
+	"phone_unique":       "phone",
+	"key_id_unique":      "key_id",
+	"room_number_unique": "room_number",
+	"audit_seq_unique":   "seq",
+}
+
+var (
+	dupIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+	dupKeyPattern   = regexp.MustCompile(`dup key: \{ ?"?(\w+)"?:`)
+)
+
+func duplicateKeyFieldFromMessage(msg string) string {
+	if m := dupIndexPattern.FindStringSubmatch(msg); m != nil {
+		if field, ok := uniqueIndexFields[m[1]]; ok {
+			return field
+		}
+	}
+	if m := dupKeyPattern.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// translateError переводит ошибки драйвера в ошибки репозитория
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return &DuplicateKeyError{Field: duplicateKeyFieldFromMessage(err.Error())}
+	default:
+		return err
+	}
+}
+
+// IsUnavailable сообщает, что операция не выполнена из-за недоступности
+// базы (сеть, выбор сервера, таймаут), а не из-за самих данных
+func IsUnavailable(err error) bool {
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, mongo.ErrClientDisconnected)
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memoryDB хранит коллекции в памяти. Все репозитории хранилища делят
+// одну блокировку, поэтому транзакция видит и изменяет согласованные данные
+type memoryDB struct {
+	mu         sync.RWMutex
+	users      []models.User
+	rooms      []models.Room
+	schedules  []models.Schedule
+	logs       []models.Log
+	access     []models.AccessLog
+	webhooks   []models.Webhook
+	deliveries []models.WebhookDelivery
+	alerts     []models.Alert
+	audit      []models.AuditEntry
+	// touched — коллекции, изменённые текущей транзакцией
+	touched map[string]bool
+
+	watchMu  sync.Mutex
+	watchers map[*memoryWatcher]struct{}
+}
+
+// memoryWatcher получает имена изменённых коллекций из collections
+type memoryWatcher struct {
+	collections map[string]bool
+	changes     chan string
+}
+
+// txKey помечает контекст транзакции, внутри которой блокировка уже захвачена
+type txKey struct{}
+
+// NewMemoryStore создаёт потокобезопасное хранилище в памяти для тестов
+// и запуска без MongoDB. Уникальность полей и транзакции соблюдаются так же,
+// как в MongoDB
+func NewMemoryStore() *Store {
+	db := &memoryDB{}
+	return &Store{
+		Users:      &memoryUserRepository{db: db},
+		Rooms:      &memoryRoomRepository{db: db},
+		Schedules:  &memoryScheduleRepository{db: db},
+		Logs:       &memoryLogRepository{db: db},
+		Access:     &memoryAccessLogRepository{db: db},
+		Webhooks:   &memoryWebhookRepository{db: db},
+		Deliveries: &memoryDeliveryRepository{db: db},
+		Alerts:     &memoryAlertRepository{db: db},
+		Audit:      &memoryAuditRepository{db: db},
+		backend:    db,
+	}
+}
+
+func (db *memoryDB) inTransaction(ctx context.Context) bool {
+	return ctx.Value(txKey{}) == db
+}
+
+// lock захватывает блокировку на запись коллекции coll, если вызов не
+// находится внутри транзакции этого хранилища, и возвращает функцию
+// освобождения, которая уведомляет подписчиков об изменении
+func (db *memoryDB) lock(ctx context.Context, coll string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if db.inTransaction(ctx) {
+		db.touched[coll] = true
+		return func() {}, nil
+	}
+	db.mu.Lock()
+	return func() {
+		db.mu.Unlock()
+		db.notify(coll)
+	}, nil
+}
+
+func (db *memoryDB) rlock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if db.inTransaction(ctx) {
+		return func() {}, nil
+	}
+	db.mu.RLock()
+	return db.mu.RUnlock, nil
+}
+
+// withTransaction выполняет fn под блокировкой хранилища и восстанавливает
+// прежнее состояние, если fn вернула ошибку
+func (db *memoryDB) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if db.inTransaction(ctx) {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	touched, err := db.runTransaction(ctx, fn)
+	for coll := range touched {
+		db.notify(coll)
+	}
+	return err
+}
+
+// runTransaction выполняет fn под блокировкой и возвращает изменённые
+// коллекции, если изменения сохранены
+func (db *memoryDB) runTransaction(ctx context.Context, fn func(ctx context.Context) error) (map[string]bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.touched = map[string]bool{}
+	defer func() { db.touched = nil }()
+
+	users := make([]models.User, len(db.users))
+	for i, u := range db.users {
+		users[i] = cloneUser(u)
+	}
+	rooms := append([]models.Room(nil), db.rooms...)
+	schedules := append([]models.Schedule(nil), db.schedules...)
+	logs := append([]models.Log(nil), db.logs...)
+	access := append([]models.AccessLog(nil), db.access...)
+	webhooks := make([]models.Webhook, len(db.webhooks))
+	for i, w := range db.webhooks {
+		webhooks[i] = cloneWebhook(w)
+	}
+	deliveries := make([]models.WebhookDelivery, len(db.deliveries))
+	for i, d := range db.deliveries {
+		deliveries[i] = cloneDelivery(d)
+	}
+	alerts := make([]models.Alert, len(db.alerts))
+	for i, a := range db.alerts {
+		alerts[i] = cloneAlert(a)
+	}
+	audit := make([]models.AuditEntry, len(db.audit))
+	for i, e := range db.audit {
+		audit[i] = cloneAuditEntry(e)
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, db)); err != nil {
+		db.users, db.rooms, db.schedules, db.logs, db.access = users, rooms, schedules, logs, access
+		db.webhooks, db.deliveries, db.alerts, db.audit = webhooks, deliveries, alerts, audit
+		return nil, err
+	}
+	return db.touched, nil
+}
+
+func (db *memoryDB) ensureIndexes(context.Context) error {
+	return nil
+}
+
+func (db *memoryDB) ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// notify не блокирует запись: если подписчик не успевает, в его очереди
+// уже есть необработанное изменение той же коллекции или более раннее
+func (db *memoryDB) notify(coll string) {
+	db.watchMu.Lock()
+	defer db.watchMu.Unlock()
+	for w := range db.watchers {
+		if !w.collections[coll] {
+			continue
+		}
+		select {
+		case w.changes <- coll:
+		default:
+		}
+	}
+}
+
+// watch сообщает только имя изменённой коллекции, без ID документа
+func (db *memoryDB) watch(ctx context.Context, collections []string, opened func(), fn func(Change)) error {
+	w := &memoryWatcher{collections: map[string]bool{}, changes: make(chan string, 16)}
+	for _, coll := range collections {
+		w.collections[coll] = true
+	}
+
+	db.watchMu.Lock()
+	if db.watchers == nil {
+		db.watchers = map[*memoryWatcher]struct{}{}
+	}
+	db.watchers[w] = struct{}{}
+	db.watchMu.Unlock()
+
+	defer func() {
+		db.watchMu.Lock()
+		delete(db.watchers, w)
+		db.watchMu.Unlock()
+	}()
+	if opened != nil {
+		opened()
+	}
+
+	for {
+		select {
+		case coll := <-w.changes:
+			fn(Change{Collection: coll})
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// applyFields устанавливает поля документа по их именам в BSON, повторяя
+// семантику $set
+func applyFields[T any](doc T, fields map[string]interface{}) (T, error) {
+	var updated T
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return updated, err
+	}
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return updated, err
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	raw, err = bson.Marshal(m)
+	if err != nil {
+		return updated, err
+	}
+	err = bson.Unmarshal(raw, &updated)
+	return updated, err
+}
+
+func cloneUser(u models.User) models.User {
+	u.AccessRooms = cloneStrings(u.AccessRooms)
+	u.Photos = cloneStrings(u.Photos)
+	if u.DeactivatedAt != nil {
+		at := *u.DeactivatedAt
+		u.DeactivatedAt = &at
+	}
+	return u
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryAccessLogRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryAccessLogRepository) InsertMany(ctx context.Context, entries []models.AccessLog) ([]int, error) {
+	unlock, err := r.db.lock(ctx, AccessLogCollection)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var inserted []int
+	for i, e := range entries {
+		if r.exists(e) {
+			continue
+		}
+		if e.ID.IsZero() {
+			e.ID = primitive.NewObjectID()
+		}
+		r.db.access = append(r.db.access, e)
+		inserted = append(inserted, i)
+	}
+	return inserted, nil
+}
+
+// exists повторяет уникальный индекс access_log_unique
+func (r *memoryAccessLogRepository) exists(e models.AccessLog) bool {
+	for _, other := range r.db.access {
+		if other.ControllerID == e.ControllerID && other.KeyID == e.KeyID &&
+			other.RoomID == e.RoomID && other.AccessTime.Equal(e.AccessTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryAccessLogRepository) Each(ctx context.Context, filter AccessLogFilter, fn func(models.AccessLog) error) error {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	var entries []models.AccessLog
+	for _, e := range r.db.access {
+		if (filter.UserID.IsZero() || e.UserID == filter.UserID) &&
+			(filter.RoomID == "" || e.RoomID == filter.RoomID) &&
+			(filter.ControllerID == "" || e.ControllerID == filter.ControllerID) &&
+			(filter.Status == "" || e.Status == filter.Status) &&
+			(filter.From.IsZero() || !e.AccessTime.Before(filter.From)) &&
+			(filter.To.IsZero() || e.AccessTime.Before(filter.To)) {
+			entries = append(entries, e)
+		}
+	}
+	unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].AccessTime.Before(entries[j].AccessTime) })
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryAlertRepository struct {
+	db *memoryDB
+}
+
+func cloneAlert(a models.Alert) models.Alert {
+	a.EventIDs = append([]uint64(nil), a.EventIDs...)
+	if a.AcknowledgedAt != nil {
+		at := *a.AcknowledgedAt
+		a.AcknowledgedAt = &at
+	}
+	if a.ResolvedAt != nil {
+		at := *a.ResolvedAt
+		a.ResolvedAt = &at
+	}
+	return a
+}
+
+func (r *memoryAlertRepository) Insert(ctx context.Context, alert *models.Alert) error {
+	unlock, err := r.db.lock(ctx, AlertsCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if alert.ID.IsZero() {
+		alert.ID = primitive.NewObjectID()
+	}
+	r.db.alerts = append(r.db.alerts, cloneAlert(*alert))
+	return nil
+}
+
+func (r *memoryAlertRepository) List(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var alerts []models.Alert
+	for i := len(r.db.alerts) - 1; i >= 0; i-- {
+		a := r.db.alerts[i]
+		if (filter.Status == "" || a.Status == filter.Status) && (filter.Severity == "" || a.Severity == filter.Severity) {
+			alerts = append(alerts, cloneAlert(a))
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	if filter.Limit > 0 && len(alerts) > filter.Limit {
+		alerts = alerts[:filter.Limit]
+	}
+	return alerts, nil
+}
+
+func (r *memoryAlertRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Alert, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return models.Alert{}, err
+	}
+	defer unlock()
+
+	for _, a := range r.db.alerts {
+		if a.ID == id {
+			return cloneAlert(a), nil
+		}
+	}
+	return models.Alert{}, ErrNotFound
+}
+
+func (r *memoryAlertRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.AlertStatus, status models.AlertStatus, by, note string, at time.Time) (models.Alert, error) {
+	unlock, err := r.db.lock(ctx, AlertsCollection)
+	if err != nil {
+		return models.Alert{}, err
+	}
+	defer unlock()
+
+	for i, a := range r.db.alerts {
+		if a.ID != id {
+			continue
+		}
+		if !contains(from, a.Status) {
+			return models.Alert{}, ErrConflict
+		}
+		updated, err := applyFields(a, alertStatusFields(status, by, note, at))
+		if err != nil {
+			return models.Alert{}, err
+		}
+		r.db.alerts[i] = updated
+		return cloneAlert(updated), nil
+	}
+	return models.Alert{}, ErrNotFound
+}
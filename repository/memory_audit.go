@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryAuditRepository struct {
+	db *memoryDB
+}
+
+func cloneAuditEntry(e models.AuditEntry) models.AuditEntry {
+	if e.Changes != nil {
+		changes := make([]models.AuditChange, len(e.Changes))
+		for i, ch := range e.Changes {
+			ch.Before = append(json.RawMessage(nil), ch.Before...)
+			ch.After = append(json.RawMessage(nil), ch.After...)
+			changes[i] = ch
+		}
+		e.Changes = changes
+	}
+	return e
+}
+
+func (r *memoryAuditRepository) Insert(ctx context.Context, entry *models.AuditEntry) error {
+	unlock, err := r.db.lock(ctx, AuditCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	for _, e := range r.db.audit {
+		if e.Seq == entry.Seq {
+			return &DuplicateKeyError{Field: "seq"}
+		}
+	}
+	r.db.audit = append(r.db.audit, cloneAuditEntry(*entry))
+	return nil
+}
+
+func (r *memoryAuditRepository) Last(ctx context.Context) (models.AuditEntry, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	defer unlock()
+
+	last := -1
+	for i, e := range r.db.audit {
+		if last < 0 || e.Seq > r.db.audit[last].Seq {
+			last = i
+		}
+	}
+	if last < 0 {
+		return models.AuditEntry{}, ErrNotFound
+	}
+	return cloneAuditEntry(r.db.audit[last]), nil
+}
+
+// sorted возвращает копии записей по возрастанию Seq. Вызывается под блокировкой
+func (r *memoryAuditRepository) sorted() []models.AuditEntry {
+	entries := make([]models.AuditEntry, len(r.db.audit))
+	for i, e := range r.db.audit {
+		entries[i] = cloneAuditEntry(e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	all := r.sorted()
+	var entries []models.AuditEntry
+	for i := len(all) - 1; i >= 0; i-- {
+		e := all[i]
+		if (filter.Entity == "" || e.Entity == filter.Entity) &&
+			(filter.EntityID == "" || e.EntityID == filter.EntityID) &&
+			(filter.Actor == "" || e.Actor == filter.Actor) &&
+			(filter.Action == "" || e.Action == filter.Action) {
+			entries = append(entries, e)
+		}
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func (r *memoryAuditRepository) Scan(ctx context.Context, fn func(models.AuditEntry) error) error {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	entries := r.sorted()
+	unlock()
+
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
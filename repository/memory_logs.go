@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryLogRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryLogRepository) Insert(ctx context.Context, entry *models.Log) error {
+	unlock, err := r.db.lock(ctx, LogsCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := cloneLog(*entry)
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+	r.db.logs = append(r.db.logs, e)
+	return nil
+}
+
+func (r *memoryLogRepository) InsertMany(ctx context.Context, entries []models.Log) (int64, error) {
+	unlock, err := r.db.lock(ctx, LogsCollection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	existing := make(map[primitive.ObjectID]bool, len(r.db.logs))
+	for _, l := range r.db.logs {
+		existing[l.ID] = true
+	}
+	var n int64
+	for _, e := range entries {
+		if existing[e.ID] {
+			continue
+		}
+		existing[e.ID] = true
+		r.db.logs = append(r.db.logs, cloneLog(e))
+		n++
+	}
+	return n, nil
+}
+
+func (r *memoryLogRepository) List(ctx context.Context, filter LogFilter) ([]models.Log, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var logs []models.Log
+	for _, l := range r.db.logs {
+		if matchLog(l, filter) {
+			logs = append(logs, cloneLog(l))
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool { return logBefore(logs[i], logs[j].Timestamp, logs[j].ID) })
+	if filter.Limit > 0 && len(logs) > filter.Limit {
+		logs = logs[:filter.Limit]
+	}
+	return logs, nil
+}
+
+func (r *memoryLogRepository) Each(ctx context.Context, filter LogFilter, fn func(models.Log) error) error {
+	logs, err := r.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, l := range logs {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logBefore сравнивает позиции записей в порядке выдачи List
+func logBefore(l models.Log, t time.Time, id primitive.ObjectID) bool {
+	if !l.Timestamp.Equal(t) {
+		return l.Timestamp.Before(t)
+	}
+	return bytes.Compare(l.ID[:], id[:]) < 0
+}
+
+func (r *memoryLogRepository) DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	unlock, err := r.db.lock(ctx, LogsCollection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	kept := r.db.logs[:0:0]
+	for _, l := range r.db.logs {
+		if !contains(ids, l.ID) {
+			kept = append(kept, l)
+		}
+	}
+	n := int64(len(r.db.logs) - len(kept))
+	r.db.logs = kept
+	return n, nil
+}
+
+func matchLog(l models.Log, f LogFilter) bool {
+	return (f.EventType == "" || l.EventType == f.EventType) &&
+		!contains(f.ExcludeTypes, l.EventType) &&
+		(!f.Original || l.RestoredAt == nil) &&
+		(f.RestoredBefore.IsZero() || l.RestoredAt != nil && l.RestoredAt.Before(f.RestoredBefore)) &&
+		(f.AfterTime.IsZero() || logBefore(models.Log{ID: f.AfterID, Timestamp: f.AfterTime}, l.Timestamp, l.ID)) &&
+		(f.UserID.IsZero() || l.UserID == f.UserID) &&
+		(f.Actor == "" || l.Actor == f.Actor) &&
+		(f.Room == "" || l.Room == f.Room) &&
+		(f.ControllerID == "" || l.ControllerID == f.ControllerID) &&
+		(f.Reason == "" || l.Reason == f.Reason) &&
+		(f.From.IsZero() || !l.Timestamp.Before(f.From)) &&
+		(f.To.IsZero() || l.Timestamp.Before(f.To))
+}
+
+func cloneLog(l models.Log) models.Log {
+	if l.Params != nil {
+		params := make(map[string]string, len(l.Params))
+		for k, v := range l.Params {
+			params[k] = v
+		}
+		l.Params = params
+	}
+	if l.RestoredAt != nil {
+		at := *l.RestoredAt
+		l.RestoredAt = &at
+	}
+	return l
+}
+
+func (r *memoryLogRepository) FindWithUnknownUser(ctx context.Context, userIDs []primitive.ObjectID) ([]models.Log, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var logs []models.Log
+	for _, l := range r.db.logs {
+		// Пустой user_id не сохраняется в документе, как и в MongoDB
+		if !l.UserID.IsZero() && !contains(userIDs, l.UserID) {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
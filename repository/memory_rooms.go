@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRoomRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryRoomRepository) index(id primitive.ObjectID) int {
+	for i, room := range r.db.rooms {
+		if room.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateMany, как упорядоченная вставка в MongoDB, останавливается на
+// первой ошибке, сохраняя уже вставленные комнаты
+func (r *memoryRoomRepository) CreateMany(ctx context.Context, rooms []models.Room) error {
+	unlock, err := r.db.lock(ctx, RoomsCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, room := range rooms {
+		if room.ID.IsZero() {
+			room.ID = primitive.NewObjectID()
+		}
+		for _, other := range r.db.rooms {
+			if other.ID == room.ID {
+				return &DuplicateKeyError{Field: "_id"}
+			}
+			if room.RoomNumber != "" && other.RoomNumber == room.RoomNumber {
+				return &DuplicateKeyError{Field: "room_number"}
+			}
+		}
+		r.db.rooms = append(r.db.rooms, room)
+	}
+	return nil
+}
+
+func (r *memoryRoomRepository) List(ctx context.Context) ([]models.Room, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return append([]models.Room(nil), r.db.rooms...), nil
+}
+
+func (r *memoryRoomRepository) ListByAccessController(ctx context.Context, controllerID string) ([]models.Room, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var rooms []models.Room
+	for _, room := range r.db.rooms {
+		if room.AccessControllerID == controllerID {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (r *memoryRoomRepository) findOne(ctx context.Context, match func(models.Room) bool) (models.Room, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return models.Room{}, err
+	}
+	defer unlock()
+
+	for _, room := range r.db.rooms {
+		if match(room) {
+			return room, nil
+		}
+	}
+	return models.Room{}, ErrNotFound
+}
+
+func (r *memoryRoomRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Room, error) {
+	return r.findOne(ctx, func(room models.Room) bool { return room.ID == id })
+}
+
+func (r *memoryRoomRepository) FindByNumber(ctx context.Context, roomNumber string) (models.Room, error) {
+	return r.findOne(ctx, func(room models.Room) bool { return room.RoomNumber == roomNumber })
+}
+
+func (r *memoryRoomRepository) SetAccessController(ctx context.Context, id primitive.ObjectID, controllerID string) error {
+	unlock, err := r.db.lock(ctx, RoomsCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.db.rooms[i].AccessControllerID = controllerID
+	return nil
+}
+
+func (r *memoryRoomRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	unlock, err := r.db.lock(ctx, RoomsCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.db.rooms = append(r.db.rooms[:i], r.db.rooms[i+1:]...)
+	return nil
+}
+
+func (r *memoryRoomRepository) Numbers(ctx context.Context) ([]string, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	seen := map[string]bool{}
+	numbers := []string{}
+	for _, room := range r.db.rooms {
+		if !seen[room.RoomNumber] {
+			seen[room.RoomNumber] = true
+			numbers = append(numbers, room.RoomNumber)
+		}
+	}
+	return numbers, nil
+}
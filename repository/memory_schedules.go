@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryScheduleRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryScheduleRepository) find(ctx context.Context, match func(models.Schedule) bool) ([]models.Schedule, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var schedules []models.Schedule
+	for _, s := range r.db.schedules {
+		if match(s) {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
+
+// updateWhere применяет fn ко всем подходящим расписаниям и возвращает их количество
+func (r *memoryScheduleRepository) updateWhere(ctx context.Context, match func(models.Schedule) bool, fn func(*models.Schedule)) (int64, error) {
+	unlock, err := r.db.lock(ctx, SchedulesCollection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var n int64
+	for i := range r.db.schedules {
+		if match(r.db.schedules[i]) {
+			fn(&r.db.schedules[i])
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryScheduleRepository) deleteWhere(ctx context.Context, match func(models.Schedule) bool) (int64, error) {
+	unlock, err := r.db.lock(ctx, SchedulesCollection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	kept := make([]models.Schedule, 0, len(r.db.schedules))
+	for _, s := range r.db.schedules {
+		if !match(s) {
+			kept = append(kept, s)
+		}
+	}
+	n := int64(len(r.db.schedules) - len(kept))
+	r.db.schedules = kept
+	return n, nil
+}
+
+func (r *memoryScheduleRepository) count(ctx context.Context, match func(models.Schedule) bool) (int64, error) {
+	schedules, err := r.find(ctx, match)
+	return int64(len(schedules)), err
+}
+
+func (r *memoryScheduleRepository) Create(ctx context.Context, schedule *models.Schedule) error {
+	unlock, err := r.db.lock(ctx, SchedulesCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s := *schedule
+	if s.ID.IsZero() {
+		s.ID = primitive.NewObjectID()
+	}
+	for _, other := range r.db.schedules {
+		if other.ID == s.ID {
+			return &DuplicateKeyError{Field: "_id"}
+		}
+	}
+	r.db.schedules = append(r.db.schedules, s)
+	return nil
+}
+
+func (r *memoryScheduleRepository) List(ctx context.Context) ([]models.Schedule, error) {
+	return r.find(ctx, func(models.Schedule) bool { return true })
+}
+
+func (r *memoryScheduleRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Schedule, error) {
+	schedules, err := r.find(ctx, func(s models.Schedule) bool { return s.ID == id })
+	if err != nil {
+		return models.Schedule{}, err
+	}
+	if len(schedules) == 0 {
+		return models.Schedule{}, ErrNotFound
+	}
+	return schedules[0], nil
+}
+
+func (r *memoryScheduleRepository) FindForAccess(ctx context.Context, userID primitive.ObjectID, roomNumber, day string) ([]models.Schedule, error) {
+	return r.find(ctx, func(s models.Schedule) bool {
+		return s.UserID == userID && s.RoomNumber == roomNumber && s.Day == day
+	})
+}
+
+func (r *memoryScheduleRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	unlock, err := r.db.lock(ctx, SchedulesCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for i, s := range r.db.schedules {
+		if s.ID != id {
+			continue
+		}
+		updated, err := applyFields(s, fields)
+		if err != nil {
+			return err
+		}
+		r.db.schedules[i] = updated
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (r *memoryScheduleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	n, err := r.deleteWhere(ctx, func(s models.Schedule) bool { return s.ID == id })
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryScheduleRepository) CountByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.count(ctx, func(s models.Schedule) bool { return s.UserID == userID })
+}
+
+func (r *memoryScheduleRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.deleteWhere(ctx, func(s models.Schedule) bool { return s.UserID == userID })
+}
+
+func (r *memoryScheduleRepository) ReassignUser(ctx context.Context, userID primitive.ObjectID, target models.User) (int64, error) {
+	return r.updateWhere(ctx, func(s models.Schedule) bool { return s.UserID == userID }, func(s *models.Schedule) {
+		s.UserID = target.ID
+		s.FirstName = target.FirstName
+		s.SecondName = target.SecondName
+	})
+}
+
+func (r *memoryScheduleRepository) CountByRoom(ctx context.Context, roomNumber string) (int64, error) {
+	return r.count(ctx, func(s models.Schedule) bool { return s.RoomNumber == roomNumber })
+}
+
+func (r *memoryScheduleRepository) DeleteByRoom(ctx context.Context, roomNumber string) (int64, error) {
+	return r.deleteWhere(ctx, func(s models.Schedule) bool { return s.RoomNumber == roomNumber })
+}
+
+func (r *memoryScheduleRepository) ReassignRoom(ctx context.Context, roomNumber, target string) (int64, error) {
+	return r.updateWhere(ctx, func(s models.Schedule) bool { return s.RoomNumber == roomNumber }, func(s *models.Schedule) {
+		s.RoomNumber = target
+	})
+}
+
+func (r *memoryScheduleRepository) FindDangling(ctx context.Context, userIDs []primitive.ObjectID, roomNumbers []string) ([]models.Schedule, error) {
+	return r.find(ctx, func(s models.Schedule) bool {
+		return !contains(userIDs, s.UserID) || !contains(roomNumbers, s.RoomNumber)
+	})
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"time"
+)
+
+func (r *memoryLogRepository) Usage(ctx context.Context, query UsageQuery) (UsageStats, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	defer unlock()
+
+	u := newUsageCounter(query)
+	for _, l := range r.db.logs {
+		if contains(query.EventTypes, l.EventType) {
+			u.add(l.Room, l.Timestamp)
+		}
+	}
+	return u.stats(), nil
+}
+
+func (r *memoryAccessLogRepository) Usage(ctx context.Context, query UsageQuery) (UsageStats, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	defer unlock()
+
+	u := newUsageCounter(query)
+	for _, a := range r.db.access {
+		if a.Status == models.AccessStatusGranted {
+			u.add(a.RoomID, a.AccessTime)
+		}
+	}
+	return u.stats(), nil
+}
+
+// usageCounter повторяет в памяти группировки usageAggregate
+type usageCounter struct {
+	query  UsageQuery
+	counts map[UsageCount]int64
+	peaks  map[UsagePeak]int64
+}
+
+func newUsageCounter(query UsageQuery) *usageCounter {
+	return &usageCounter{query: query, counts: map[UsageCount]int64{}, peaks: map[UsagePeak]int64{}}
+}
+
+func (u *usageCounter) add(room string, t time.Time) {
+	if t.Before(u.query.From) || !t.Before(u.query.To) {
+		return
+	}
+	local := t.In(u.query.Location)
+	u.counts[UsageCount{Room: room, Start: UsageStart(t, u.query.Unit, u.query.Location)}]++
+	u.peaks[UsagePeak{Room: room, Weekday: local.Weekday(), Hour: local.Hour()}]++
+}
+
+func (u *usageCounter) stats() UsageStats {
+	var stats UsageStats
+	for c, n := range u.counts {
+		c.Entries = n
+		stats.Counts = append(stats.Counts, c)
+	}
+	for p, n := range u.peaks {
+		p.Entries = n
+		stats.Peaks = append(stats.Peaks, p)
+	}
+	return stats
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUserRepository struct {
+	db *memoryDB
+}
+
+func (f UserFilter) matches(u models.User) bool {
+	switch {
+	case f.All:
+		return true
+	case f.Status == "":
+		return u.EffectiveStatus() != models.StatusDeactivated
+	default:
+		return u.EffectiveStatus() == f.Status
+	}
+}
+
+// publicUser возвращает копию пользователя без учётных данных
+func publicUser(u models.User) models.User {
+	u = cloneUser(u)
+	u.Password = ""
+	return u
+}
+
+func (r *memoryUserRepository) index(id primitive.ObjectID) int {
+	for i, u := range r.db.users {
+		if u.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkUnique повторяет уникальные индексы коллекции users. Пользователь
+// с индексом skip (при обновлении — он сам) не учитывается
+func (r *memoryUserRepository) checkUnique(u models.User, skip int) error {
+	for i, other := range r.db.users {
+		if i == skip {
+			continue
+		}
+		switch {
+		case other.ID == u.ID:
+			return &DuplicateKeyError{Field: "_id"}
+		case u.Email != "" && other.Email == u.Email:
+			return &DuplicateKeyError{Field: "email"}
+		case u.Phone != "" && other.Phone == u.Phone:
+			return &DuplicateKeyError{Field: "phone"}
+		case u.KeyID != "" && other.KeyID == u.KeyID:
+			return &DuplicateKeyError{Field: "key_id"}
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) insert(u models.User) error {
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	if err := r.checkUnique(u, -1); err != nil {
+		return err
+	}
+	r.db.users = append(r.db.users, cloneUser(u))
+	return nil
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	unlock, err := r.db.lock(ctx, UsersCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return r.insert(*user)
+}
+
+func (r *memoryUserRepository) CreateMany(ctx context.Context, users []models.User) (map[int]error, error) {
+	unlock, err := r.db.lock(ctx, UsersCollection)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var failed map[int]error
+	for i, u := range users {
+		if err := r.insert(u); err != nil {
+			if failed == nil {
+				failed = map[int]error{}
+			}
+			failed[i] = err
+		}
+	}
+	return failed, nil
+}
+
+func (r *memoryUserRepository) findOne(ctx context.Context, match func(models.User) bool) (models.User, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer unlock()
+
+	for _, u := range r.db.users {
+		if match(u) {
+			return cloneUser(u), nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) find(ctx context.Context, match func(models.User) bool) ([]models.User, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var users []models.User
+	for _, u := range r.db.users {
+		if match(u) {
+			users = append(users, publicUser(u))
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return r.findOne(ctx, func(u models.User) bool { return u.ID == id })
+}
+
+func (r *memoryUserRepository) FindByIdentifier(ctx context.Context, identifiers []string) (models.User, error) {
+	return r.findOne(ctx, func(u models.User) bool {
+		return contains(identifiers, u.Email) || contains(identifiers, u.Phone)
+	})
+}
+
+func (r *memoryUserRepository) FindByName(ctx context.Context, firstName, secondName string) (models.User, error) {
+	user, err := r.findOne(ctx, func(u models.User) bool {
+		return u.FirstName == firstName && u.SecondName == secondName
+	})
+	user.Password = ""
+	return user, err
+}
+
+func (r *memoryUserRepository) FindByKeyID(ctx context.Context, keyID string) (models.User, error) {
+	user, err := r.findOne(ctx, func(u models.User) bool { return keyID != "" && u.KeyID == keyID })
+	user.Password = ""
+	return user, err
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	return r.find(ctx, filter.matches)
+}
+
+func (r *memoryUserRepository) Each(ctx context.Context, filter UserFilter, fn func(models.User) error) error {
+	users, err := r.find(ctx, filter.matches)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		if users[i].SecondName != users[j].SecondName {
+			return users[i].SecondName < users[j].SecondName
+		}
+		return users[i].FirstName < users[j].FirstName
+	})
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) FindByContacts(ctx context.Context, emails, phones []string) ([]models.User, error) {
+	if len(emails) == 0 && len(phones) == 0 {
+		return nil, nil
+	}
+	return r.find(ctx, func(u models.User) bool {
+		return contains(emails, u.Email) || contains(phones, u.Phone)
+	})
+}
+
+func (r *memoryUserRepository) FindByRoomGrant(ctx context.Context, roomNumber string) ([]models.User, error) {
+	return r.find(ctx, func(u models.User) bool {
+		return contains(models.SplitAccessRooms(u.AccessRooms), roomNumber)
+	})
+}
+
+func (r *memoryUserRepository) ListDeactivatedBefore(ctx context.Context, cutoff time.Time) ([]models.User, error) {
+	return r.find(ctx, func(u models.User) bool {
+		return u.Status == models.StatusDeactivated && u.DeactivatedAt != nil && u.DeactivatedAt.Before(cutoff)
+	})
+}
+
+// modify применяет fn к пользователю под блокировкой и проверяет
+// уникальность результата
+func (r *memoryUserRepository) modify(ctx context.Context, id primitive.ObjectID, fn func(models.User) (models.User, error)) (models.User, error) {
+	unlock, err := r.db.lock(ctx, UsersCollection)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return models.User{}, ErrNotFound
+	}
+	updated, err := fn(cloneUser(r.db.users[i]))
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := r.checkUnique(updated, i); err != nil {
+		return models.User{}, err
+	}
+	r.db.users[i] = updated
+	return publicUser(updated), nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	_, err := r.modify(ctx, id, func(u models.User) (models.User, error) {
+		return applyFields(u, fields)
+	})
+	return err
+}
+
+func (r *memoryUserRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.UserStatus, status models.UserStatus, at time.Time) (models.User, error) {
+	return r.modify(ctx, id, func(u models.User) (models.User, error) {
+		allowed := false
+		for _, s := range from {
+			if u.EffectiveStatus() == s {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return u, ErrConflict
+		}
+		u.Status = status
+		u.DeactivatedAt = nil
+		if status == models.StatusDeactivated {
+			u.DeactivatedAt = &at
+		}
+		return u, nil
+	})
+}
+
+func (r *memoryUserRepository) AddPhoto(ctx context.Context, id primitive.ObjectID, photo string) error {
+	_, err := r.modify(ctx, id, func(u models.User) (models.User, error) {
+		u.Photos = append(u.Photos, photo)
+		return u, nil
+	})
+	return err
+}
+
+func (r *memoryUserRepository) RemovePhoto(ctx context.Context, id primitive.ObjectID, photo string) error {
+	_, err := r.modify(ctx, id, func(u models.User) (models.User, error) {
+		photos := u.Photos[:0]
+		for _, p := range u.Photos {
+			if p != photo {
+				photos = append(photos, p)
+			}
+		}
+		u.Photos = photos
+		return u, nil
+	})
+	return err
+}
+
+func (r *memoryUserRepository) SetAccessRooms(ctx context.Context, id primitive.ObjectID, rooms []string) error {
+	_, err := r.modify(ctx, id, func(u models.User) (models.User, error) {
+		u.AccessRooms = cloneStrings(rooms)
+		return u, nil
+	})
+	return err
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	unlock, err := r.db.lock(ctx, UsersCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.db.users = append(r.db.users[:i], r.db.users[i+1:]...)
+	return nil
+}
+
+func (r *memoryUserRepository) IDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ids := make([]primitive.ObjectID, 0, len(r.db.users))
+	for _, u := range r.db.users {
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
+}
+
+func contains[T comparable](items []T, item T) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryWebhookRepository struct {
+	db *memoryDB
+}
+
+func cloneWebhook(w models.Webhook) models.Webhook {
+	w.EventTypes = cloneStrings(w.EventTypes)
+	return w
+}
+
+func (r *memoryWebhookRepository) index(id primitive.ObjectID) int {
+	for i, w := range r.db.webhooks {
+		if w.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryWebhookRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	unlock, err := r.db.lock(ctx, WebhooksCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if webhook.ID.IsZero() {
+		webhook.ID = primitive.NewObjectID()
+	}
+	if r.index(webhook.ID) >= 0 {
+		return &DuplicateKeyError{Field: "_id"}
+	}
+	r.db.webhooks = append(r.db.webhooks, cloneWebhook(*webhook))
+	return nil
+}
+
+func (r *memoryWebhookRepository) find(ctx context.Context, match func(models.Webhook) bool) ([]models.Webhook, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var webhooks []models.Webhook
+	for _, w := range r.db.webhooks {
+		if match(w) {
+			webhooks = append(webhooks, cloneWebhook(w))
+		}
+	}
+	sort.SliceStable(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt) })
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	return r.find(ctx, func(models.Webhook) bool { return true })
+}
+
+func (r *memoryWebhookRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Webhook, error) {
+	webhooks, err := r.find(ctx, func(w models.Webhook) bool { return w.ID == id })
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return models.Webhook{}, ErrNotFound
+	}
+	return webhooks[0], nil
+}
+
+func (r *memoryWebhookRepository) FindByEventType(ctx context.Context, eventType string) ([]models.Webhook, error) {
+	return r.find(ctx, func(w models.Webhook) bool {
+		return w.Active && (contains(w.EventTypes, eventType) || contains(w.EventTypes, models.WebhookAllEvents))
+	})
+}
+
+func (r *memoryWebhookRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	unlock, err := r.db.lock(ctx, WebhooksCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	updated, err := applyFields(r.db.webhooks[i], fields)
+	if err != nil {
+		return err
+	}
+	r.db.webhooks[i] = updated
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	unlock, err := r.db.lock(ctx, WebhooksCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.db.webhooks = append(r.db.webhooks[:i], r.db.webhooks[i+1:]...)
+	return nil
+}
+
+type memoryDeliveryRepository struct {
+	db *memoryDB
+}
+
+func cloneDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	d.Attempts = append([]models.WebhookAttempt(nil), d.Attempts...)
+	if d.DeliveredAt != nil {
+		at := *d.DeliveredAt
+		d.DeliveredAt = &at
+	}
+	if d.RequeuedAt != nil {
+		at := *d.RequeuedAt
+		d.RequeuedAt = &at
+	}
+	return d
+}
+
+func (r *memoryDeliveryRepository) index(id primitive.ObjectID) int {
+	for i, d := range r.db.deliveries {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryDeliveryRepository) InsertMany(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	unlock, err := r.db.lock(ctx, DeliveriesCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, d := range deliveries {
+		if d.ID.IsZero() {
+			d.ID = primitive.NewObjectID()
+		}
+		r.db.deliveries = append(r.db.deliveries, cloneDelivery(d))
+	}
+	return nil
+}
+
+func (r *memoryDeliveryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	defer unlock()
+
+	if i := r.index(id); i >= 0 {
+		return cloneDelivery(r.db.deliveries[i]), nil
+	}
+	return models.WebhookDelivery{}, ErrNotFound
+}
+
+func (r *memoryDeliveryRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time) (models.WebhookDelivery, error) {
+	unlock, err := r.db.lock(ctx, DeliveriesCollection)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	defer unlock()
+
+	due := -1
+	for i, d := range r.db.deliveries {
+		if d.Status != models.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		if due < 0 || d.NextAttemptAt.Before(r.db.deliveries[due].NextAttemptAt) {
+			due = i
+		}
+	}
+	if due < 0 {
+		return models.WebhookDelivery{}, ErrNotFound
+	}
+	r.db.deliveries[due].NextAttemptAt = leaseUntil
+	return cloneDelivery(r.db.deliveries[due]), nil
+}
+
+func (r *memoryDeliveryRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status models.DeliveryStatus, next time.Time) error {
+	unlock, err := r.db.lock(ctx, DeliveriesCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	d := &r.db.deliveries[i]
+	d.Attempts = append(d.Attempts, attempt)
+	d.Status = status
+	d.NextAttemptAt = next
+	if status == models.DeliveryDelivered {
+		at := attempt.At
+		d.DeliveredAt = &at
+	}
+	return nil
+}
+
+func (r *memoryDeliveryRepository) Requeue(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	unlock, err := r.db.lock(ctx, DeliveriesCollection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if r.db.deliveries[i].Status != models.DeliveryDead {
+		return ErrConflict
+	}
+	r.db.deliveries[i].Status = models.DeliveryPending
+	r.db.deliveries[i].NextAttemptAt = at
+	r.db.deliveries[i].RequeuedAt = &at
+	return nil
+}
+
+func (r *memoryDeliveryRepository) ListByWebhook(ctx context.Context, webhookID primitive.ObjectID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	unlock, err := r.db.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var deliveries []models.WebhookDelivery
+	for i := len(r.db.deliveries) - 1; i >= 0; i-- {
+		d := r.db.deliveries[i]
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, cloneDelivery(d))
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) DeleteByWebhook(ctx context.Context, webhookID primitive.ObjectID) (int64, error) {
+	unlock, err := r.db.lock(ctx, DeliveriesCollection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	kept := r.db.deliveries[:0]
+	var deleted int64
+	for _, d := range r.db.deliveries {
+		if d.WebhookID == webhookID {
+			deleted++
+			continue
+		}
+		kept = append(kept, d)
+	}
+	r.db.deliveries = kept
+	return deleted, nil
+}
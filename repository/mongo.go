@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	UsersCollection      = "users"
+	RoomsCollection      = "rooms"
+	SchedulesCollection  = "schedule"
+	LogsCollection       = "logs"
+	AccessLogCollection  = "access_logs"
+	WebhooksCollection   = "webhooks"
+	DeliveriesCollection = "webhook_deliveries"
+	AlertsCollection     = "alerts"
+	AuditCollection      = "audit"
+)
+
+type mongoBackend struct {
+	client     *mongo.Client
+	db         *mongo.Database
+	users      *mongoUserRepository
+	rooms      *mongoRoomRepository
+	logs       *mongoLogRepository
+	access     *mongoAccessLogRepository
+	deliveries *mongoDeliveryRepository
+	alerts     *mongoAlertRepository
+	audit      *mongoAuditRepository
+}
+
+// NewStore создаёт хранилище на базе database клиента MongoDB
+func NewStore(client *mongo.Client, database string) *Store {
+	db := client.Database(database)
+	b := &mongoBackend{
+		client:     client,
+		db:         db,
+		users:      &mongoUserRepository{coll: db.Collection(UsersCollection)},
+		rooms:      &mongoRoomRepository{coll: db.Collection(RoomsCollection)},
+		logs:       &mongoLogRepository{coll: db.Collection(LogsCollection)},
+		access:     &mongoAccessLogRepository{coll: db.Collection(AccessLogCollection)},
+		deliveries: &mongoDeliveryRepository{coll: db.Collection(DeliveriesCollection)},
+		alerts:     &mongoAlertRepository{coll: db.Collection(AlertsCollection)},
+		audit:      &mongoAuditRepository{coll: db.Collection(AuditCollection)},
+	}
+	return &Store{
+		Users:      b.users,
+		Rooms:      b.rooms,
+		Schedules:  &mongoScheduleRepository{coll: db.Collection(SchedulesCollection)},
+		Logs:       b.logs,
+		Access:     b.access,
+		Webhooks:   &mongoWebhookRepository{coll: db.Collection(WebhooksCollection)},
+		Deliveries: b.deliveries,
+		Alerts:     b.alerts,
+		Audit:      b.audit,
+		backend:    b,
+	}
+}
+
+func (b *mongoBackend) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Вложенная транзакция выполняется в уже открытой
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	session, err := b.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// Ошибка одного индекса (например, из-за уже существующих дубликатов)
+// не мешает созданию остальных
+func (b *mongoBackend) ensureIndexes(ctx context.Context) error {
+	return errors.Join(b.users.EnsureIndexes(ctx), b.rooms.EnsureIndexes(ctx), b.logs.EnsureIndexes(ctx), b.access.EnsureIndexes(ctx),
+		b.deliveries.EnsureIndexes(ctx), b.alerts.EnsureIndexes(ctx), b.audit.EnsureIndexes(ctx))
+}
+
+func (b *mongoBackend) ping(ctx context.Context) error {
+	return b.client.Ping(ctx, readpref.Primary())
+}
+
+// watch читает поток изменений (change stream) базы. Требует набора
+// реплик: на одиночном сервере MongoDB возвращает ошибку
+func (b *mongoBackend) watch(ctx context.Context, collections []string, opened func(), fn func(Change)) error {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": collections}}}}}
+	stream, err := b.db.Watch(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	if opened != nil {
+		opened()
+	}
+
+	for stream.Next(ctx) {
+		var change struct {
+			OperationType string `bson:"operationType"`
+			NS            struct {
+				Coll string `bson:"coll"`
+			} `bson:"ns"`
+			DocumentKey struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"documentKey"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		// drop и rename приходят без documentKey, ID останется пустым
+		fn(Change{Collection: change.NS.Coll, ID: change.DocumentKey.ID, Deleted: change.OperationType == "delete"})
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return stream.Err()
+}
+
+func createIndexes(ctx context.Context, coll *mongo.Collection, models ...mongo.IndexModel) error {
+	var errs []error
+	for _, model := range models {
+		name, err := coll.Indexes().CreateOne(ctx, model)
+		if err != nil {
+			log.Printf("Ошибка при создании индекса %s.%s: %v", coll.Name(), *model.Options.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Индекс %s.%s готов", coll.Name(), name)
+	}
+	return errors.Join(errs...)
+}
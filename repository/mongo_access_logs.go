@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoAccessLogRepository struct {
+	coll *mongo.Collection
+}
+
+// Один проход не может быть загружен дважды, например при повторной
+// отправке после обрыва связи
+func (r *mongoAccessLogRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll,
+		mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "controller_id", Value: 1},
+				{Key: "key_id", Value: 1},
+				{Key: "room_id", Value: 1},
+				{Key: "access_time", Value: 1},
+			},
+			Options: options.Index().SetName("access_log_unique").SetUnique(true),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "access_time", Value: 1}},
+			Options: options.Index().SetName("access_log_time"),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "room_id", Value: 1}, {Key: "access_time", Value: 1}},
+			Options: options.Index().SetName("access_log_room_time"),
+		},
+	)
+}
+
+func (r *mongoAccessLogRepository) InsertMany(ctx context.Context, entries []models.AccessLog) ([]int, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	docs := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		docs = append(docs, e)
+	}
+
+	_, err := r.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	duplicates := map[int]bool{}
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return nil, err
+		}
+		for _, we := range bulkErr.WriteErrors {
+			if we.Code != duplicateKeyCode {
+				return nil, err
+			}
+			duplicates[we.Index] = true
+		}
+	}
+
+	inserted := make([]int, 0, len(entries)-len(duplicates))
+	for i := range entries {
+		if !duplicates[i] {
+			inserted = append(inserted, i)
+		}
+	}
+	return inserted, nil
+}
+
+func (r *mongoAccessLogRepository) Each(ctx context.Context, filter AccessLogFilter, fn func(models.AccessLog) error) error {
+	query := bson.M{}
+	for field, value := range map[string]string{
+		"room_id":       filter.RoomID,
+		"controller_id": filter.ControllerID,
+		"status":        filter.Status,
+	} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	if !filter.UserID.IsZero() {
+		query["user_id"] = filter.UserID
+	}
+	period := bson.M{}
+	if !filter.From.IsZero() {
+		period["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		period["$lt"] = filter.To
+	}
+	if len(period) > 0 {
+		query["access_time"] = period
+	}
+
+	cursor, err := r.coll.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "access_time", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry models.AccessLog
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoAlertRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoAlertRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll, mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("alert_status_created"),
+	})
+}
+
+func (r *mongoAlertRepository) Insert(ctx context.Context, alert *models.Alert) error {
+	if alert.ID.IsZero() {
+		alert.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, alert)
+	return err
+}
+
+func (r *mongoAlertRepository) List(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Severity != "" {
+		query["severity"] = filter.Severity
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []models.Alert
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func (r *mongoAlertRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Alert, error) {
+	var alert models.Alert
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&alert)
+	return alert, translateError(err)
+}
+
+func (r *mongoAlertRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.AlertStatus, status models.AlertStatus, by, note string, at time.Time) (models.Alert, error) {
+	set := alertStatusFields(status, by, note, at)
+	var alert models.Alert
+	err := r.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&alert)
+	if err == mongo.ErrNoDocuments {
+		// Различаем отсутствующее оповещение и недопустимый переход
+		if _, findErr := r.FindByID(ctx, id); findErr != nil {
+			return alert, findErr
+		}
+		return alert, ErrConflict
+	}
+	return alert, err
+}
+
+// alertStatusFields — поля, которые меняются при переходе в status
+func alertStatusFields(status models.AlertStatus, by, note string, at time.Time) bson.M {
+	set := bson.M{"status": status}
+	switch status {
+	case models.AlertAcknowledged:
+		set["acknowledged_by"] = by
+		set["acknowledged_at"] = at
+	case models.AlertResolved:
+		set["resolved_by"] = by
+		set["resolved_at"] = at
+	}
+	if note != "" {
+		set["note"] = note
+	}
+	return set
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoAuditRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoAuditRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "seq", Value: 1}},
+			Options: options.Index().SetName("audit_seq_unique").SetUnique(true),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "seq", Value: -1}},
+			Options: options.Index().SetName("audit_entity"),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "actor", Value: 1}, {Key: "seq", Value: -1}},
+			Options: options.Index().SetName("audit_actor"),
+		},
+	)
+}
+
+func (r *mongoAuditRepository) Insert(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := r.coll.InsertOne(ctx, entry)
+	return translateError(err)
+}
+
+func (r *mongoAuditRepository) Last(ctx context.Context) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	err := r.coll.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&entry)
+	return entry, translateError(err)
+}
+
+func (r *mongoAuditRepository) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	query := bson.M{}
+	for field, value := range map[string]string{
+		"entity":    filter.Entity,
+		"entity_id": filter.EntityID,
+		"actor":     filter.Actor,
+		"action":    filter.Action,
+	} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.AuditEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *mongoAuditRepository) Scan(ctx context.Context, fn func(models.AuditEntry) error) error {
+	cursor, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
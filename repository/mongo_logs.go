@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLogRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoLogRepository) EnsureIndexes(ctx context.Context) error {
+	index := func(name, field string) mongo.IndexModel {
+		keys := bson.D{{Key: "timestamp", Value: -1}}
+		if field != "" {
+			keys = append(bson.D{{Key: field, Value: 1}}, keys...)
+		}
+		return mongo.IndexModel{Keys: keys, Options: options.Index().SetName(name)}
+	}
+	return createIndexes(ctx, r.coll,
+		index("log_time", ""),
+		index("log_type_time", "event_type"),
+		index("log_user_time", "user_id"),
+		index("log_room_time", "room"),
+		index("log_controller_time", "controller_id"),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "restored_at", Value: 1}},
+			Options: options.Index().SetName("log_restored").SetSparse(true),
+		},
+	)
+}
+
+func (r *mongoLogRepository) Insert(ctx context.Context, entry *models.Log) error {
+	_, err := r.coll.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *mongoLogRepository) InsertMany(ctx context.Context, entries []models.Log) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	docs := make([]interface{}, len(entries))
+	for i := range entries {
+		docs[i] = entries[i]
+	}
+	_, err := r.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, we := range bulkErr.WriteErrors {
+			if we.Code != duplicateKeyCode {
+				return 0, err
+			}
+		}
+		return int64(len(entries) - len(bulkErr.WriteErrors)), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(entries)), nil
+}
+
+func (r *mongoLogRepository) List(ctx context.Context, filter LogFilter) ([]models.Log, error) {
+	return r.find(ctx, logQuery(filter), logListOptions(filter))
+}
+
+func (r *mongoLogRepository) Each(ctx context.Context, filter LogFilter, fn func(models.Log) error) error {
+	cursor, err := r.coll.Find(ctx, logQuery(filter), logListOptions(filter))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry models.Log
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func logListOptions(filter LogFilter) *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	return opts
+}
+
+func (r *mongoLogRepository) DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := r.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func logQuery(filter LogFilter) bson.M {
+	query := bson.M{}
+	for field, value := range map[string]string{
+		"event_type":    filter.EventType,
+		"actor":         filter.Actor,
+		"room":          filter.Room,
+		"controller_id": filter.ControllerID,
+		"reason":        filter.Reason,
+	} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	if !filter.UserID.IsZero() {
+		query["user_id"] = filter.UserID
+	}
+	if len(filter.ExcludeTypes) > 0 {
+		if filter.EventType == "" {
+			query["event_type"] = bson.M{"$nin": filter.ExcludeTypes}
+		} else if contains(filter.ExcludeTypes, filter.EventType) {
+			query["event_type"] = bson.M{"$in": bson.A{}}
+		}
+	}
+	switch {
+	case !filter.RestoredBefore.IsZero():
+		query["restored_at"] = bson.M{"$lt": filter.RestoredBefore}
+	case filter.Original:
+		query["restored_at"] = bson.M{"$exists": false}
+	}
+	if !filter.AfterTime.IsZero() {
+		query["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{"$gt": filter.AfterTime}},
+			bson.M{"timestamp": filter.AfterTime, "_id": bson.M{"$gt": filter.AfterID}},
+		}
+	}
+	period := bson.M{}
+	if !filter.From.IsZero() {
+		period["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		period["$lt"] = filter.To
+	}
+	if len(period) > 0 {
+		query["timestamp"] = period
+	}
+	return query
+}
+
+// FindWithUnknownUser возвращает записи, ссылающиеся на пользователя не из userIDs
+func (r *mongoLogRepository) FindWithUnknownUser(ctx context.Context, userIDs []primitive.ObjectID) ([]models.Log, error) {
+	if userIDs == nil {
+		userIDs = []primitive.ObjectID{}
+	}
+	return r.find(ctx, bson.M{"user_id": bson.M{"$exists": true, "$nin": userIDs}},
+		options.Find().SetProjection(bson.M{"user_id": 1}))
+}
+
+func (r *mongoLogRepository) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Log, error) {
+	cursor, err := r.coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var logs []models.Log
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
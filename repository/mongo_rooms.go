@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRoomRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoRoomRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll, uniqueStringIndex("room_number", "room_number_unique"))
+}
+
+func (r *mongoRoomRepository) CreateMany(ctx context.Context, rooms []models.Room) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(rooms))
+	for _, room := range rooms {
+		docs = append(docs, room)
+	}
+	_, err := r.coll.InsertMany(ctx, docs)
+	return translateError(err)
+}
+
+func (r *mongoRoomRepository) List(ctx context.Context) ([]models.Room, error) {
+	cursor, err := r.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var rooms []models.Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *mongoRoomRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Room, error) {
+	var room models.Room
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&room)
+	return room, translateError(err)
+}
+
+func (r *mongoRoomRepository) FindByNumber(ctx context.Context, roomNumber string) (models.Room, error) {
+	var room models.Room
+	err := r.coll.FindOne(ctx, bson.M{"room_number": roomNumber}).Decode(&room)
+	return room, translateError(err)
+}
+
+func (r *mongoRoomRepository) ListByAccessController(ctx context.Context, controllerID string) ([]models.Room, error) {
+	cursor, err := r.coll.Find(ctx, bson.M{"access_controller_id": controllerID})
+	if err != nil {
+		return nil, err
+	}
+	var rooms []models.Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// SetAccessController привязывает контроллер доступа к комнате
+func (r *mongoRoomRepository) SetAccessController(ctx context.Context, id primitive.ObjectID, controllerID string) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"access_controller_id": controllerID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRoomRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Numbers возвращает номера всех комнат
+func (r *mongoRoomRepository) Numbers(ctx context.Context) ([]string, error) {
+	values, err := r.coll.Distinct(ctx, "room_number", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			numbers = append(numbers, s)
+		}
+	}
+	return numbers, nil
+}
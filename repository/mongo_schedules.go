@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoScheduleRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoScheduleRepository) find(ctx context.Context, filter interface{}) ([]models.Schedule, error) {
+	cursor, err := r.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var schedules []models.Schedule
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *mongoScheduleRepository) Create(ctx context.Context, schedule *models.Schedule) error {
+	_, err := r.coll.InsertOne(ctx, schedule)
+	return err
+}
+
+func (r *mongoScheduleRepository) List(ctx context.Context) ([]models.Schedule, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoScheduleRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Schedule, error) {
+	var schedule models.Schedule
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&schedule)
+	return schedule, translateError(err)
+}
+
+// FindForAccess возвращает расписания пользователя в комнате на день недели
+func (r *mongoScheduleRepository) FindForAccess(ctx context.Context, userID primitive.ObjectID, roomNumber, day string) ([]models.Schedule, error) {
+	return r.find(ctx, bson.M{
+		"user_id":     userID,
+		"room_number": roomNumber,
+		"day":         day,
+	})
+}
+
+// Update устанавливает переданные поля (имена полей — как в BSON)
+func (r *mongoScheduleRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoScheduleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoScheduleRepository) CountByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.coll.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *mongoScheduleRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.coll.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// ReassignUser переносит расписания пользователя на target
+func (r *mongoScheduleRepository) ReassignUser(ctx context.Context, userID primitive.ObjectID, target models.User) (int64, error) {
+	result, err := r.coll.UpdateMany(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{
+		"user_id":     target.ID,
+		"first_name":  target.FirstName,
+		"second_name": target.SecondName,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoScheduleRepository) CountByRoom(ctx context.Context, roomNumber string) (int64, error) {
+	return r.coll.CountDocuments(ctx, bson.M{"room_number": roomNumber})
+}
+
+func (r *mongoScheduleRepository) DeleteByRoom(ctx context.Context, roomNumber string) (int64, error) {
+	result, err := r.coll.DeleteMany(ctx, bson.M{"room_number": roomNumber})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *mongoScheduleRepository) ReassignRoom(ctx context.Context, roomNumber, target string) (int64, error) {
+	result, err := r.coll.UpdateMany(ctx, bson.M{"room_number": roomNumber},
+		bson.M{"$set": bson.M{"room_number": target}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// FindDangling возвращает расписания, ссылающиеся на пользователя не из
+// userIDs или на комнату не из roomNumbers
+func (r *mongoScheduleRepository) FindDangling(ctx context.Context, userIDs []primitive.ObjectID, roomNumbers []string) ([]models.Schedule, error) {
+	// $nin не принимает null, поэтому пустые списки заменяем пустыми массивами
+	if userIDs == nil {
+		userIDs = []primitive.ObjectID{}
+	}
+	if roomNumbers == nil {
+		roomNumbers = []string{}
+	}
+	return r.find(ctx, bson.M{"$or": []bson.M{
+		{"user_id": bson.M{"$nin": userIDs}},
+		{"room_number": bson.M{"$nin": roomNumbers}},
+	}})
+}
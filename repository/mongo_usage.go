@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *mongoLogRepository) Usage(ctx context.Context, query UsageQuery) (UsageStats, error) {
+	match := bson.M{
+		"event_type": bson.M{"$in": query.EventTypes},
+		"timestamp":  bson.M{"$gte": query.From, "$lt": query.To},
+	}
+	return usageAggregate(ctx, r.coll, match, "$room", "$timestamp", query)
+}
+
+func (r *mongoAccessLogRepository) Usage(ctx context.Context, query UsageQuery) (UsageStats, error) {
+	match := bson.M{
+		"status":      models.AccessStatusGranted,
+		"access_time": bson.M{"$gte": query.From, "$lt": query.To},
+	}
+	return usageAggregate(ctx, r.coll, match, "$room_id", "$access_time", query)
+}
+
+// usageAggregate считает подходящие под match записи одним запросом:
+// по комнате и интервалу ($dateTrunc, MongoDB 5.0+) и по комнате, дню
+// недели и часу. Границы интервалов считаются в query.Location
+func usageAggregate(ctx context.Context, coll *mongo.Collection, match bson.M, room, timeField string, query UsageQuery) (UsageStats, error) {
+	tz := query.Location.String()
+	trunc := bson.M{"date": timeField, "unit": query.Unit, "timezone": tz}
+	if query.Unit == UsageWeek {
+		trunc["startOfWeek"] = "monday"
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":     bson.M{"room": room, "start": bson.M{"$dateTrunc": trunc}},
+					"entries": bson.M{"$sum": 1},
+				}},
+			},
+			"peaks": bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{
+						"room":    room,
+						"weekday": bson.M{"$dayOfWeek": bson.M{"date": timeField, "timezone": tz}},
+						"hour":    bson.M{"$hour": bson.M{"date": timeField, "timezone": tz}},
+					},
+					"entries": bson.M{"$sum": 1},
+				}},
+			},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Counts []struct {
+			ID struct {
+				Room  string    `bson:"room"`
+				Start time.Time `bson:"start"`
+			} `bson:"_id"`
+			Entries int64 `bson:"entries"`
+		} `bson:"counts"`
+		Peaks []struct {
+			ID struct {
+				Room    string `bson:"room"`
+				Weekday int    `bson:"weekday"`
+				Hour    int    `bson:"hour"`
+			} `bson:"_id"`
+			Entries int64 `bson:"entries"`
+		} `bson:"peaks"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return UsageStats{}, err
+	}
+
+	var stats UsageStats
+	if len(result) == 0 {
+		return stats, nil
+	}
+	for _, c := range result[0].Counts {
+		stats.Counts = append(stats.Counts, UsageCount{Room: c.ID.Room, Start: c.ID.Start.In(query.Location), Entries: c.Entries})
+	}
+	for _, p := range result[0].Peaks {
+		// $dayOfWeek: 1 — воскресенье
+		stats.Peaks = append(stats.Peaks, UsagePeak{
+			Room: p.ID.Room, Weekday: time.Weekday(p.ID.Weekday - 1), Hour: p.ID.Hour, Entries: p.Entries,
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Проекция, исключающая учётные данные из документов пользователей
+var userPublicProjection = bson.M{"password": 0}
+
+// UserFilter отбирает пользователей по статусу. Нулевое значение
+// возвращает всех, кроме деактивированных
+type UserFilter struct {
+	All    bool
+	Status models.UserStatus
+}
+
+func (f UserFilter) query() bson.M {
+	switch {
+	case f.All:
+		return bson.M{}
+	case f.Status == "":
+		return bson.M{"status": bson.M{"$ne": models.StatusDeactivated}}
+	default:
+		return bson.M{"status": statusIn(f.Status)}
+	}
+}
+
+// statusIn строит условие по статусам. У старых документов поле status
+// отсутствует, они считаются активными
+func statusIn(statuses ...models.UserStatus) bson.M {
+	values := []interface{}{}
+	for _, s := range statuses {
+		values = append(values, s)
+		if s == models.StatusActive {
+			values = append(values, nil)
+		}
+	}
+	return bson.M{"$in": values}
+}
+
+type mongoUserRepository struct {
+	coll *mongo.Collection
+}
+
+// uniqueStringIndex создаёт уникальный индекс, не учитывающий документы
+// без поля или с пустой строкой: email хранится без omitempty, поэтому
+// обычный sparse-индекс запретил бы второго пользователя без почты
+func uniqueStringIndex(field, name string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: field, Value: 1}},
+		Options: options.Index().
+			SetName(name).
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$type": "string", "$gt": ""}}),
+	}
+}
+
+func (r *mongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll,
+		uniqueStringIndex("email", "email_unique"),
+		uniqueStringIndex("phone", "phone_unique"),
+		uniqueStringIndex("key_id", "key_id_unique"),
+	)
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.coll.InsertOne(ctx, user)
+	return translateError(err)
+}
+
+// CreateMany вставляет пользователей без остановки на первой ошибке.
+// Ошибки отдельных документов возвращаются по их индексу в users
+func (r *mongoUserRepository) CreateMany(ctx context.Context, users []models.User) (map[int]error, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	docs := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		docs = append(docs, u)
+	}
+
+	_, err := r.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+
+	failed := map[int]error{}
+	for _, we := range bulkErr.WriteErrors {
+		if we.Code == duplicateKeyCode {
+			failed[we.Index] = &DuplicateKeyError{Field: duplicateKeyFieldFromMessage(we.Message)}
+		} else {
+			failed[we.Index] = errors.New(we.Message)
+		}
+	}
+	return failed, nil
+}
+
+// FindByID возвращает пользователя вместе с хэшем пароля
+func (r *mongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	var user models.User
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, translateError(err)
+}
+
+// FindByIdentifier ищет пользователя по email или телефону для входа
+func (r *mongoUserRepository) FindByIdentifier(ctx context.Context, identifiers []string) (models.User, error) {
+	var user models.User
+	err := r.coll.FindOne(ctx, bson.M{"$or": []bson.M{
+		{"email": bson.M{"$in": identifiers}},
+		{"phone": bson.M{"$in": identifiers}},
+	}}).Decode(&user)
+	return user, translateError(err)
+}
+
+func (r *mongoUserRepository) FindByName(ctx context.Context, firstName, secondName string) (models.User, error) {
+	var user models.User
+	opts := options.FindOne().SetProjection(userPublicProjection)
+	err := r.coll.FindOne(ctx, bson.M{"first_name": firstName, "second_name": secondName}, opts).Decode(&user)
+	return user, translateError(err)
+}
+
+func (r *mongoUserRepository) FindByKeyID(ctx context.Context, keyID string) (models.User, error) {
+	var user models.User
+	opts := options.FindOne().SetProjection(userPublicProjection)
+	err := r.coll.FindOne(ctx, bson.M{"key_id": keyID}, opts).Decode(&user)
+	return user, translateError(err)
+}
+
+func (r *mongoUserRepository) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.User, error) {
+	opts = append(opts, options.Find().SetProjection(userPublicProjection))
+	cursor, err := r.coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// List возвращает пользователей без учётных данных
+func (r *mongoUserRepository) List(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	return r.find(ctx, filter.query())
+}
+
+// Each обходит пользователей без учётных данных курсором, не загружая
+// их все в память. Порядок — по фамилии и имени
+func (r *mongoUserRepository) Each(ctx context.Context, filter UserFilter, fn func(models.User) error) error {
+	opts := options.Find().
+		SetProjection(userPublicProjection).
+		SetSort(bson.D{{Key: "second_name", Value: 1}, {Key: "first_name", Value: 1}})
+	cursor, err := r.coll.Find(ctx, filter.query(), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// FindByContacts возвращает пользователей, у которых занят один из email или телефонов
+func (r *mongoUserRepository) FindByContacts(ctx context.Context, emails, phones []string) ([]models.User, error) {
+	if len(emails) == 0 && len(phones) == 0 {
+		return nil, nil
+	}
+	if emails == nil {
+		emails = []string{}
+	}
+	if phones == nil {
+		phones = []string{}
+	}
+	return r.find(ctx, bson.M{"$or": []bson.M{
+		{"email": bson.M{"$in": emails}},
+		{"phone": bson.M{"$in": phones}},
+	}})
+}
+
+// FindByRoomGrant возвращает пользователей с правом доступа в комнату.
+// Номер может входить в строку вида "101,102", поэтому кандидаты отбираются
+// по подстроке, а точное совпадение проверяется уже в коде
+func (r *mongoUserRepository) FindByRoomGrant(ctx context.Context, roomNumber string) ([]models.User, error) {
+	candidates, err := r.find(ctx, bson.M{"access_rooms": bson.M{
+		"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(roomNumber)},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	var grantees []models.User
+	for _, u := range candidates {
+		for _, room := range models.SplitAccessRooms(u.AccessRooms) {
+			if room == roomNumber {
+				grantees = append(grantees, u)
+				break
+			}
+		}
+	}
+	return grantees, nil
+}
+
+func (r *mongoUserRepository) ListDeactivatedBefore(ctx context.Context, cutoff time.Time) ([]models.User, error) {
+	return r.find(ctx, bson.M{
+		"status":         models.StatusDeactivated,
+		"deactivated_at": bson.M{"$lt": cutoff},
+	})
+}
+
+// Update устанавливает переданные поля (имена полей — как в BSON)
+func (r *mongoUserRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return translateError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ChangeStatus переводит пользователя в статус status, если текущий статус
+// входит в from. Возвращает ErrNotFound или ErrConflict
+func (r *mongoUserRepository) ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.UserStatus, status models.UserStatus, at time.Time) (models.User, error) {
+	update := bson.M{"$set": bson.M{"status": status}}
+	if status == models.StatusDeactivated {
+		update["$set"].(bson.M)["deactivated_at"] = at
+	} else {
+		update["$unset"] = bson.M{"deactivated_at": ""}
+	}
+
+	var user models.User
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(userPublicProjection)
+	err := r.coll.FindOneAndUpdate(ctx, bson.M{"_id": id, "status": statusIn(from...)}, update, opts).Decode(&user)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return user, translateError(err)
+	}
+
+	count, err := r.coll.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return user, err
+	}
+	if count > 0 {
+		return user, ErrConflict
+	}
+	return user, ErrNotFound
+}
+
+func (r *mongoUserRepository) AddPhoto(ctx context.Context, id primitive.ObjectID, photo string) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$push": bson.M{"photos": photo}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) RemovePhoto(ctx context.Context, id primitive.ObjectID, photo string) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$pull": bson.M{"photos": photo}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetAccessRooms(ctx context.Context, id primitive.ObjectID, rooms []string) error {
+	return r.Update(ctx, id, map[string]interface{}{"access_rooms": rooms})
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IDs возвращает идентификаторы всех пользователей
+func (r *mongoUserRepository) IDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	values, err := r.coll.Distinct(ctx, "_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
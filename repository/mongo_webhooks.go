@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoWebhookRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoWebhookRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	if webhook.ID.IsZero() {
+		webhook.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, webhook)
+	return translateError(err)
+}
+
+func (r *mongoWebhookRepository) find(ctx context.Context, filter bson.M) ([]models.Webhook, error) {
+	cursor, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var webhooks []models.Webhook
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *mongoWebhookRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoWebhookRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	return webhook, translateError(err)
+}
+
+func (r *mongoWebhookRepository) FindByEventType(ctx context.Context, eventType string) ([]models.Webhook, error) {
+	return r.find(ctx, bson.M{
+		"active":      true,
+		"event_types": bson.M{"$in": []string{eventType, models.WebhookAllEvents}},
+	})
+}
+
+func (r *mongoWebhookRepository) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoWebhookRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type mongoDeliveryRepository struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoDeliveryRepository) EnsureIndexes(ctx context.Context) error {
+	return createIndexes(ctx, r.coll,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("delivery_queue"),
+		},
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("delivery_history"),
+		},
+	)
+}
+
+func (r *mongoDeliveryRepository) InsertMany(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.ID.IsZero() {
+			d.ID = primitive.NewObjectID()
+		}
+		docs = append(docs, d)
+	}
+	_, err := r.coll.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *mongoDeliveryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	return d, translateError(err)
+}
+
+func (r *mongoDeliveryRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := r.coll.FindOneAndUpdate(ctx,
+		bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": leaseUntil}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&d)
+	return d, translateError(err)
+}
+
+func (r *mongoDeliveryRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status models.DeliveryStatus, next time.Time) error {
+	set := bson.M{"status": status, "next_attempt_at": next}
+	if status == models.DeliveryDelivered {
+		set["delivered_at"] = attempt.At
+	}
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": set, "$push": bson.M{"attempts": attempt}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoDeliveryRepository) Requeue(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	result, err := r.coll.UpdateOne(ctx, bson.M{"_id": id, "status": models.DeliveryDead},
+		bson.M{"$set": bson.M{"status": models.DeliveryPending, "next_attempt_at": at, "requeued_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, id); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoDeliveryRepository) ListByWebhook(ctx context.Context, webhookID primitive.ObjectID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	filter := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := r.coll.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []models.WebhookDelivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *mongoDeliveryRepository) DeleteByWebhook(ctx context.Context, webhookID primitive.ObjectID) (int64, error) {
+	result, err := r.coll.DeleteMany(ctx, bson.M{"webhook_id": webhookID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"access-control-system/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepository — хранилище пользователей. Методы, возвращающие списки,
+// не заполняют хэш пароля
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	// CreateMany вставляет пользователей без остановки на первой ошибке.
+	// Ошибки отдельных документов возвращаются по их индексу в users
+	CreateMany(ctx context.Context, users []models.User) (map[int]error, error)
+	// FindByID возвращает пользователя вместе с хэшем пароля
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	// FindByIdentifier ищет пользователя по email или телефону для входа
+	FindByIdentifier(ctx context.Context, identifiers []string) (models.User, error)
+	FindByName(ctx context.Context, firstName, secondName string) (models.User, error)
+	// FindByKeyID ищет пользователя по идентификатору ключа (карты)
+	FindByKeyID(ctx context.Context, keyID string) (models.User, error)
+	List(ctx context.Context, filter UserFilter) ([]models.User, error)
+	// Each обходит пользователей по фамилии и имени, не загружая их все в память
+	Each(ctx context.Context, filter UserFilter, fn func(models.User) error) error
+	// FindByContacts возвращает пользователей, у которых занят один из email или телефонов
+	FindByContacts(ctx context.Context, emails, phones []string) ([]models.User, error)
+	// FindByRoomGrant возвращает пользователей с правом доступа в комнату
+	FindByRoomGrant(ctx context.Context, roomNumber string) ([]models.User, error)
+	ListDeactivatedBefore(ctx context.Context, cutoff time.Time) ([]models.User, error)
+	// Update устанавливает переданные поля (имена полей — как в BSON)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	// ChangeStatus переводит пользователя в статус status, если текущий статус
+	// входит в from. Возвращает ErrNotFound или ErrConflict
+	ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.UserStatus, status models.UserStatus, at time.Time) (models.User, error)
+	AddPhoto(ctx context.Context, id primitive.ObjectID, photo string) error
+	RemovePhoto(ctx context.Context, id primitive.ObjectID, photo string) error
+	SetAccessRooms(ctx context.Context, id primitive.ObjectID, rooms []string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	IDs(ctx context.Context) ([]primitive.ObjectID, error)
+}
+
+type RoomRepository interface {
+	CreateMany(ctx context.Context, rooms []models.Room) error
+	List(ctx context.Context) ([]models.Room, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Room, error)
+	FindByNumber(ctx context.Context, roomNumber string) (models.Room, error)
+	// ListByAccessController возвращает комнаты, обслуживаемые контроллером
+	ListByAccessController(ctx context.Context, controllerID string) ([]models.Room, error)
+	SetAccessController(ctx context.Context, id primitive.ObjectID, controllerID string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Numbers(ctx context.Context) ([]string, error)
+}
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *models.Schedule) error
+	List(ctx context.Context) ([]models.Schedule, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Schedule, error)
+	// FindForAccess возвращает расписания пользователя в комнате на день недели
+	FindForAccess(ctx context.Context, userID primitive.ObjectID, roomNumber, day string) ([]models.Schedule, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	CountByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	// ReassignUser переносит расписания пользователя на target
+	ReassignUser(ctx context.Context, userID primitive.ObjectID, target models.User) (int64, error)
+	CountByRoom(ctx context.Context, roomNumber string) (int64, error)
+	DeleteByRoom(ctx context.Context, roomNumber string) (int64, error)
+	ReassignRoom(ctx context.Context, roomNumber, target string) (int64, error)
+	// FindDangling возвращает расписания, ссылающиеся на пользователя не из
+	// userIDs или на комнату не из roomNumbers
+	FindDangling(ctx context.Context, userIDs []primitive.ObjectID, roomNumbers []string) ([]models.Schedule, error)
+}
+
+// LogFilter — условия выборки журнала. Пустые поля не ограничивают выборку,
+// From включается, To нет
+type LogFilter struct {
+	EventType    string
+	UserID       primitive.ObjectID
+	Actor        string
+	Room         string
+	ControllerID string
+	Reason       string
+	From, To     time.Time
+	// ExcludeTypes — типы событий, не попадающие в выборку
+	ExcludeTypes []string
+	// Original оставляет только записи, не восстановленные из архива
+	Original bool
+	// RestoredBefore оставляет только записи, восстановленные раньше этого времени
+	RestoredBefore time.Time
+	// AfterTime и AfterID — позиция последней прочитанной записи: выборка
+	// начинается строго после неё
+	AfterTime time.Time
+	AfterID   primitive.ObjectID
+	// Limit — максимум записей, 0 — без ограничения
+	Limit int
+}
+
+type LogRepository interface {
+	Insert(ctx context.Context, entry *models.Log) error
+	// InsertMany добавляет записи с заданными ID. Записи, ID которых уже
+	// есть в журнале, пропускаются. Возвращает число добавленных
+	InsertMany(ctx context.Context, entries []models.Log) (int64, error)
+	// List возвращает записи по возрастанию времени, при равном времени — по ID
+	List(ctx context.Context, filter LogFilter) ([]models.Log, error)
+	// Each обходит записи в порядке List курсором, не загружая их все в память
+	Each(ctx context.Context, filter LogFilter, fn func(models.Log) error) error
+	DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	// FindWithUnknownUser возвращает записи, ссылающиеся на пользователя не из userIDs
+	FindWithUnknownUser(ctx context.Context, userIDs []primitive.ObjectID) ([]models.Log, error)
+	// Usage считает записи типов query.EventTypes по комнатам
+	Usage(ctx context.Context, query UsageQuery) (UsageStats, error)
+}
+
+// AuditFilter — условия выборки журнала аудита. Пустые поля не ограничивают выборку
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	Action   string
+	Limit    int
+}
+
+// AuditRepository — журнал аудита. Записи только добавляются
+type AuditRepository interface {
+	// Insert добавляет запись. Если запись с таким Seq уже есть,
+	// возвращает *DuplicateKeyError
+	Insert(ctx context.Context, entry *models.AuditEntry) error
+	// Last возвращает запись с наибольшим Seq или ErrNotFound
+	Last(ctx context.Context) (models.AuditEntry, error)
+	// List возвращает записи, новые первыми
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+	// Scan передаёт fn все записи по возрастанию Seq
+	Scan(ctx context.Context, fn func(models.AuditEntry) error) error
+}
+
+// AccessLogFilter — условия выборки проходов. Пустые поля не ограничивают
+// выборку, From включается, To нет
+type AccessLogFilter struct {
+	UserID       primitive.ObjectID
+	RoomID       string
+	ControllerID string
+	Status       string
+	From, To     time.Time
+}
+
+// AccessLogRepository — проходы, загруженные контроллерами дверей
+type AccessLogRepository interface {
+	// InsertMany сохраняет записи и возвращает индексы новых в entries.
+	// Повторно загруженные записи (тот же контроллер, ключ, комната и время)
+	// пропускаются
+	InsertMany(ctx context.Context, entries []models.AccessLog) ([]int, error)
+	// Each обходит проходы по возрастанию времени курсором
+	Each(ctx context.Context, filter AccessLogFilter, fn func(models.AccessLog) error) error
+	// Usage считает разрешённые контроллерами проходы по комнатам
+	Usage(ctx context.Context, query UsageQuery) (UsageStats, error)
+}
+
+// Интервалы статистики использования комнат
+const (
+	UsageHour  = "hour"
+	UsageDay   = "day"
+	UsageWeek  = "week"
+	UsageMonth = "month"
+)
+
+// UsageQuery — период и разбиение подсчёта проходов по комнатам
+type UsageQuery struct {
+	From, To time.Time
+	// Unit — интервал: hour, day, week (с понедельника) или month
+	Unit string
+	// Location — часовой пояс границ интервалов и часов пик
+	Location *time.Location
+	// EventTypes — типы событий журнала, которые считаются проходами
+	EventTypes []string
+}
+
+// UsageCount — проходы в комнату за интервал, начинающийся в Start
+type UsageCount struct {
+	Room    string
+	Start   time.Time
+	Entries int64
+}
+
+// UsagePeak — проходы в комнату за период по дню недели и часу
+type UsagePeak struct {
+	Room    string
+	Weekday time.Weekday
+	Hour    int
+	Entries int64
+}
+
+// UsageStats — результат Usage
+type UsageStats struct {
+	Counts []UsageCount
+	Peaks  []UsagePeak
+}
+
+// UsageStart возвращает начало интервала unit, в который попадает t
+func UsageStart(t time.Time, unit string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch unit {
+	case UsageHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case UsageWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case UsageMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// NextUsageStart возвращает начало интервала, следующего за start
+func NextUsageStart(start time.Time, unit string) time.Time {
+	switch unit {
+	case UsageHour:
+		return start.Add(time.Hour)
+	case UsageWeek:
+		return start.AddDate(0, 0, 7)
+	case UsageMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// WebhookRepository — подписки внешних систем на события
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *models.Webhook) error
+	List(ctx context.Context) ([]models.Webhook, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Webhook, error)
+	// FindByEventType возвращает активные подписки на тип события или на все события
+	FindByEventType(ctx context.Context, eventType string) ([]models.Webhook, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// WebhookDeliveryRepository — очередь и история доставок
+type WebhookDeliveryRepository interface {
+	InsertMany(ctx context.Context, deliveries []models.WebhookDelivery) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error)
+	// ClaimDue выбирает ожидающую доставку, время попытки которой наступило,
+	// и откладывает следующую попытку до leaseUntil, чтобы её не взял другой
+	// экземпляр сервиса. Если таких нет, возвращает ErrNotFound
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time) (models.WebhookDelivery, error)
+	// RecordAttempt добавляет попытку и устанавливает статус и время следующей попытки
+	RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status models.DeliveryStatus, next time.Time) error
+	// Requeue возвращает в очередь доставку со статусом dead. Иначе ErrConflict
+	Requeue(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// ListByWebhook возвращает доставки подписки, новые первыми. Пустой
+	// status — все доставки
+	ListByWebhook(ctx context.Context, webhookID primitive.ObjectID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	DeleteByWebhook(ctx context.Context, webhookID primitive.ObjectID) (int64, error)
+}
+
+// AlertFilter — отбор оповещений. Пустые поля не ограничивают выборку
+type AlertFilter struct {
+	Status   models.AlertStatus
+	Severity models.AlertSeverity
+	Limit    int
+}
+
+// AlertRepository — срабатывания правил оповещений
+type AlertRepository interface {
+	Insert(ctx context.Context, alert *models.Alert) error
+	// List возвращает оповещения, новые первыми
+	List(ctx context.Context, filter AlertFilter) ([]models.Alert, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Alert, error)
+	// ChangeStatus переводит оповещение в status, если текущий статус входит
+	// в from, и записывает, кто и когда это сделал. Возвращает ErrNotFound
+	// или ErrConflict
+	ChangeStatus(ctx context.Context, id primitive.ObjectID, from []models.AlertStatus, status models.AlertStatus, by, note string, at time.Time) (models.Alert, error)
+}
+
+// backend — операции, затрагивающие все коллекции хранилища
+type backend interface {
+	withTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	ensureIndexes(ctx context.Context) error
+	ping(ctx context.Context) error
+	watch(ctx context.Context, collections []string, opened func(), fn func(Change)) error
+}
+
+// Change — изменение в одной из отслеживаемых коллекций. ID пуст, если
+// хранилище не сообщает, какой документ изменён: тогда подписчик
+// перечитывает коллекцию целиком
+type Change struct {
+	Collection string
+	ID         primitive.ObjectID
+	// Deleted — документ ID удалён
+	Deleted bool
+}
+
+// Store объединяет репозитории, работающие с одним хранилищем
+type Store struct {
+	Users     UserRepository
+	Rooms     RoomRepository
+	Schedules ScheduleRepository
+	Logs      LogRepository
+	Access    AccessLogRepository
+	Webhooks  WebhookRepository
+	// Deliveries — доставки событий подписчикам Webhooks
+	Deliveries WebhookDeliveryRepository
+	Alerts     AlertRepository
+	Audit      AuditRepository
+
+	backend backend
+}
+
+// WithTransaction выполняет fn атомарно. Методы репозиториев, вызванные
+// с переданным в fn контекстом, участвуют в транзакции
+func (s *Store) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.backend.withTransaction(ctx, fn)
+}
+
+// EnsureIndexes создаёт индексы при старте сервера
+func (s *Store) EnsureIndexes(ctx context.Context) error {
+	return s.backend.ensureIndexes(ctx)
+}
+
+// Ping проверяет доступность хранилища
+func (s *Store) Ping(ctx context.Context) error {
+	return s.backend.ping(ctx)
+}
+
+// Watch вызывает fn после каждого изменения одной из коллекций collections
+// и возвращает ошибку, когда поток изменений прерван или ctx отменён.
+// opened, если задан, вызывается один раз, когда поток открыт: изменения
+// после этого момента будут доставлены
+func (s *Store) Watch(ctx context.Context, opened func(), fn func(Change), collections ...string) error {
+	return s.backend.watch(ctx, collections, opened, fn)
+}
@@ -0,0 +1,218 @@
+package retention
+
+import (
+	"access-control-system/models"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Имя файла архива: logs-<время первой записи>-<время последней>-<случайный суффикс>.jsonl.gz
+const (
+	archivePrefix     = "logs-"
+	archiveSuffix     = ".jsonl.gz"
+	archiveTimeLayout = "20060102T150405.000Z"
+)
+
+// ErrNoArchive — каталог архивов не задан
+var ErrNoArchive = errors.New("каталог архивов журнала не задан")
+
+// Archive — файл архива. From и To — время первой и последней записи в нём
+type Archive struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Size int64     `json:"size"`
+}
+
+// writeArchive сохраняет записи, упорядоченные по времени, в новый файл
+// архива. Файл появляется под своим именем только полностью записанным
+func writeArchive(dir string, entries []models.Log) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := archivePrefix + entries[0].Timestamp.UTC().Format(archiveTimeLayout) + "-" +
+		entries[len(entries)-1].Timestamp.UTC().Format(archiveTimeLayout) + "-" + hex.EncodeToString(suffix) + archiveSuffix
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+archivePrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	buf := bufio.NewWriter(gz)
+	enc := json.NewEncoder(buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return "", err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func parseArchiveName(name string) (from, to time.Time, ok bool) {
+	if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+		return from, to, false
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix), "-")
+	if len(parts) != 3 {
+		return from, to, false
+	}
+	from, err1 := time.Parse(archiveTimeLayout, parts[0])
+	to, err2 := time.Parse(archiveTimeLayout, parts[1])
+	return from, to, err1 == nil && err2 == nil
+}
+
+// Archives возвращает файлы архива, упорядоченные по времени первой записи
+func (p *Pruner) Archives() ([]Archive, error) {
+	if p.cfg.ArchiveDir == "" {
+		return nil, ErrNoArchive
+	}
+	dirEntries, err := os.ReadDir(p.cfg.ArchiveDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []Archive
+	for _, de := range dirEntries {
+		from, to, ok := parseArchiveName(de.Name())
+		if !ok || de.IsDir() {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, Archive{Name: de.Name(), From: from, To: to, Size: info.Size()})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].From.Before(archives[j].From) })
+	return archives, nil
+}
+
+// RestoreResult — итог восстановления. Skipped — записи, уже
+// находящиеся в журнале
+type RestoreResult struct {
+	Files    int   `json:"files"`
+	Restored int64 `json:"restored"`
+	Skipped  int64 `json:"skipped"`
+}
+
+// Restore возвращает в журнал записи из архива со временем в [from, to)
+// и, если eventType не пуст, только этого типа. Архивы читаются потоково,
+// записи вставляются пакетами. Восстановленные записи хранятся
+// cfg.RestoreHold, затем удаляются без повторного архивирования
+func (p *Pruner) Restore(ctx context.Context, from, to time.Time, eventType string) (RestoreResult, error) {
+	var res RestoreResult
+	archives, err := p.Archives()
+	if err != nil {
+		return res, err
+	}
+	restoredAt := p.clock.Now()
+
+	for _, a := range archives {
+		if !a.From.Before(to) || a.To.Before(from) {
+			continue
+		}
+		res.Files++
+		err := p.restoreFile(ctx, filepath.Join(p.cfg.ArchiveDir, a.Name), func(batch []models.Log) error {
+			for i := range batch {
+				batch[i].RestoredAt = &restoredAt
+			}
+			n, err := p.insertMany(ctx, batch)
+			res.Restored += n
+			res.Skipped += int64(len(batch)) - n
+			return err
+		}, func(entry models.Log) bool {
+			return !entry.Timestamp.Before(from) && entry.Timestamp.Before(to) &&
+				(eventType == "" || entry.EventType == eventType)
+		})
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", a.Name, err)
+		}
+	}
+	return res, nil
+}
+
+// restoreFile читает архив и передаёт в insert пакеты подходящих записей
+func (p *Pruner) restoreFile(ctx context.Context, path string, insert func([]models.Log) error, match func(models.Log) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	batch := make([]models.Log, 0, p.cfg.BatchSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var entry models.Log
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !match(entry) {
+			continue
+		}
+		if batch = append(batch, entry); len(batch) == p.cfg.BatchSize {
+			if err := insert(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		return insert(batch)
+	}
+	return nil
+}
+
+func (p *Pruner) insertMany(parent context.Context, entries []models.Log) (int64, error) {
+	ctx, cancel := context.WithTimeout(parent, p.cfg.Timeout)
+	defer cancel()
+	return p.logs.InsertMany(ctx, entries)
+}
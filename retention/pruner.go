@@ -0,0 +1,167 @@
+// Package retention удаляет из журнала событий записи старше срока
+// хранения их типа. Перед удалением записи сохраняются в сжатые файлы
+// JSONL, из которых их можно вернуть в журнал для расследования.
+// Индексы TTL не используются: MongoDB удаляет по ним без архивирования
+package retention
+
+import (
+	"access-control-system/clock"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var pruned = metrics.Default.NewCounter("logs_pruned_total",
+	"Записи журнала, удалённые по сроку хранения: archived — с архивированием, dropped — без архива, restored — восстановленные ранее", "mode")
+
+// Config — сроки хранения и архивирование
+type Config struct {
+	// Default — срок хранения типов без собственного срока, 0 — бессрочно
+	Default time.Duration
+	// ByType — сроки хранения отдельных типов, 0 — бессрочно
+	ByType map[string]time.Duration
+	// ArchiveDir — каталог архивов. Пустой — записи удаляются без архива
+	ArchiveDir string
+	Interval   time.Duration
+	// BatchSize — записей в одном файле архива и одной операции удаления
+	BatchSize int
+	// RestoreHold — сколько хранятся записи, восстановленные из архива
+	RestoreHold time.Duration
+	// Timeout ограничивает каждую операцию с базой
+	Timeout time.Duration
+}
+
+// Pruner периодически архивирует и удаляет истёкшие записи журнала
+type Pruner struct {
+	logs  repository.LogRepository
+	clock clock.Clock
+	cfg   Config
+}
+
+func NewPruner(logs repository.LogRepository, clk clock.Clock, cfg Config) *Pruner {
+	return &Pruner{logs: logs, clock: clk, cfg: cfg}
+}
+
+// Result — итог одного прохода очистки
+type Result struct {
+	Archived int64    `json:"archived"`
+	Deleted  int64    `json:"deleted"`
+	Files    []string `json:"files,omitempty"`
+}
+
+// Run выполняет очистку с интервалом cfg.Interval до отмены ctx
+func (p *Pruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := p.Prune(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка очистки журнала событий: %v", err)
+		}
+		if res.Deleted > 0 {
+			log.Printf("Очистка журнала событий: удалено %d записей, в архив %d (%d файлов)",
+				res.Deleted, res.Archived, len(res.Files))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune архивирует и удаляет записи старше срока хранения их типа,
+// а также восстановленные записи старше cfg.RestoreHold
+func (p *Pruner) Prune(ctx context.Context) (Result, error) {
+	var res Result
+	now := p.clock.Now()
+
+	types := make([]string, 0, len(p.cfg.ByType))
+	for eventType := range p.cfg.ByType {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+
+	for _, eventType := range types {
+		if ttl := p.cfg.ByType[eventType]; ttl > 0 {
+			filter := repository.LogFilter{EventType: eventType, To: now.Add(-ttl), Original: true}
+			if err := p.prune(ctx, filter, true, &res); err != nil {
+				return res, err
+			}
+		}
+	}
+	if p.cfg.Default > 0 {
+		filter := repository.LogFilter{ExcludeTypes: types, To: now.Add(-p.cfg.Default), Original: true}
+		if err := p.prune(ctx, filter, true, &res); err != nil {
+			return res, err
+		}
+	}
+	// Восстановленные записи уже есть в архиве
+	filter := repository.LogFilter{RestoredBefore: now.Add(-p.cfg.RestoreHold)}
+	return res, p.prune(ctx, filter, false, &res)
+}
+
+// prune удаляет записи filter пакетами по cfg.BatchSize, сохраняя каждый
+// пакет в архив до удаления, если archive и задан каталог архивов
+func (p *Pruner) prune(ctx context.Context, filter repository.LogFilter, archive bool, res *Result) error {
+	archive = archive && p.cfg.ArchiveDir != ""
+	mode := "restored"
+	switch {
+	case archive:
+		mode = "archived"
+	case filter.RestoredBefore.IsZero():
+		mode = "dropped"
+	}
+
+	filter.Limit = p.cfg.BatchSize
+	for {
+		batch, err := p.list(ctx, filter)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		if archive {
+			name, err := writeArchive(p.cfg.ArchiveDir, batch)
+			if err != nil {
+				return err
+			}
+			res.Archived += int64(len(batch))
+			res.Files = append(res.Files, name)
+		}
+
+		ids := make([]primitive.ObjectID, len(batch))
+		for i, entry := range batch {
+			ids[i] = entry.ID
+		}
+		n, err := p.deleteByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		res.Deleted += n
+		pruned.With(mode).Add(float64(n))
+
+		// n == 0: удаление не продвигается, остаток обработает следующий проход
+		if len(batch) < p.cfg.BatchSize || n == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *Pruner) list(parent context.Context, filter repository.LogFilter) ([]models.Log, error) {
+	ctx, cancel := context.WithTimeout(parent, p.cfg.Timeout)
+	defer cancel()
+	return p.logs.List(ctx, filter)
+}
+
+func (p *Pruner) deleteByIDs(parent context.Context, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(parent, p.cfg.Timeout)
+	defer cancel()
+	return p.logs.DeleteByIDs(ctx, ids)
+}
@@ -0,0 +1,190 @@
+package retention
+
+import (
+	"access-control-system/clock"
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 9, 14, 12, 0, 0, 0, time.UTC)
+
+// newPruner — очистка журнала в памяти: проходы хранятся сутки, отказы
+// входа администратора бессрочно, остальные типы — срок def
+func newPruner(t *testing.T, def time.Duration) (*Pruner, repository.LogRepository, *clock.Manual) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	clk := clock.NewManual(now)
+	p := NewPruner(store.Logs, clk, Config{
+		Default: def,
+		ByType: map[string]time.Duration{
+			eventlog.TypeAccessGranted:    24 * time.Hour,
+			eventlog.TypeAdminLoginDenied: 0,
+		},
+		ArchiveDir:  t.TempDir(),
+		BatchSize:   2,
+		RestoreHold: time.Hour,
+		Timeout:     time.Second,
+	})
+	return p, store.Logs, clk
+}
+
+func insertLogs(t *testing.T, logs repository.LogRepository, entries ...models.Log) {
+	t.Helper()
+	for i := range entries {
+		if err := logs.Insert(context.Background(), &entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// countByType — число записей журнала каждого типа
+func countByType(t *testing.T, logs repository.LogRepository) map[string]int {
+	t.Helper()
+	all, err := logs.List(context.Background(), repository.LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, entry := range all {
+		counts[entry.EventType]++
+	}
+	return counts
+}
+
+func TestPruneByType(t *testing.T) {
+	p, logs, _ := newPruner(t, 0)
+	old := now.Add(-48 * time.Hour)
+	insertLogs(t, logs,
+		models.Log{EventType: eventlog.TypeAccessGranted, Timestamp: old},
+		models.Log{EventType: eventlog.TypeAccessGranted, Timestamp: old.Add(time.Minute)},
+		models.Log{EventType: eventlog.TypeAccessGranted, Timestamp: old.Add(2 * time.Minute)},
+		models.Log{EventType: eventlog.TypeAccessGranted, Timestamp: now.Add(-time.Hour)},
+		models.Log{EventType: eventlog.TypeAdminLoginDenied, Timestamp: old},
+		// Тип без собственного срока при Default == 0 хранится бессрочно
+		models.Log{EventType: eventlog.TypeOutsideSchedule, Timestamp: old},
+	)
+
+	res, err := p.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Archived != 3 || res.Deleted != 3 || len(res.Files) != 2 {
+		t.Fatalf("Prune = %+v, ожидалось 3 записи в 2 файлах", res)
+	}
+	for _, name := range res.Files {
+		if _, err := os.Stat(filepath.Join(p.cfg.ArchiveDir, name)); err != nil {
+			t.Errorf("файл архива: %v", err)
+		}
+	}
+	got := countByType(t, logs)
+	if got[eventlog.TypeAccessGranted] != 1 || got[eventlog.TypeAdminLoginDenied] != 1 || got[eventlog.TypeOutsideSchedule] != 1 {
+		t.Fatalf("осталось записей: %v", got)
+	}
+
+	archives, err := p.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 2 || !archives[0].From.Equal(old) || !archives[1].To.Equal(old.Add(2*time.Minute)) {
+		t.Fatalf("архивы = %+v", archives)
+	}
+}
+
+// Срок Default не распространяется на типы со своим сроком, даже бессрочным
+func TestPruneDefault(t *testing.T) {
+	p, logs, _ := newPruner(t, 7*24*time.Hour)
+	old := now.Add(-30 * 24 * time.Hour)
+	insertLogs(t, logs,
+		models.Log{EventType: eventlog.TypeOutsideSchedule, Timestamp: old},
+		models.Log{EventType: eventlog.TypeOutsideSchedule, Timestamp: now.Add(-time.Hour)},
+		models.Log{EventType: eventlog.TypeAdminLoginDenied, Timestamp: old},
+	)
+
+	res, err := p.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Archived != 1 || res.Deleted != 1 {
+		t.Fatalf("Prune = %+v, ожидалась 1 запись", res)
+	}
+	if got := countByType(t, logs); got[eventlog.TypeOutsideSchedule] != 1 || got[eventlog.TypeAdminLoginDenied] != 1 {
+		t.Fatalf("осталось записей: %v", got)
+	}
+}
+
+// Восстановленные записи хранятся RestoreHold и удаляются без повторного архивирования
+func TestRestoreThenPrune(t *testing.T) {
+	p, logs, clk := newPruner(t, 0)
+	ctx := context.Background()
+	old := now.Add(-48 * time.Hour)
+	insertLogs(t, logs,
+		models.Log{EventType: eventlog.TypeAccessGranted, Room: "101", Timestamp: old},
+		models.Log{EventType: eventlog.TypeAccessGranted, Room: "102", Timestamp: old.Add(time.Minute)},
+		models.Log{EventType: eventlog.TypeAccessGranted, Room: "103", Timestamp: old.Add(time.Hour)},
+	)
+	if _, err := p.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Период [from, to) не включает последнюю запись
+	clk.Advance(time.Minute)
+	restoredAt := clk.Now()
+	res, err := p.Restore(ctx, old, old.Add(time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Restored != 2 || res.Skipped != 0 {
+		t.Fatalf("Restore = %+v, ожидалось 2 записи", res)
+	}
+	// Повторное восстановление не дублирует записи
+	if res, err := p.Restore(ctx, old, old.Add(time.Hour), ""); err != nil || res.Restored != 0 || res.Skipped != 2 {
+		t.Fatalf("повторный Restore = %+v, %v", res, err)
+	}
+	restored, err := logs.List(ctx, repository.LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("в журнале %d записей, ожидалось 2", len(restored))
+	}
+	for _, entry := range restored {
+		if entry.RestoredAt == nil || !entry.RestoredAt.Equal(restoredAt) || entry.Room == "103" {
+			t.Errorf("восстановлена запись %+v", entry)
+		}
+	}
+
+	// До истечения RestoreHold восстановленные записи не трогаются,
+	// хотя срок хранения их типа давно истёк
+	if res, err := p.Prune(ctx); err != nil || res.Deleted != 0 {
+		t.Fatalf("Prune до RestoreHold = %+v, %v", res, err)
+	}
+
+	clk.Advance(time.Hour + time.Second)
+	res2, err := p.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2.Deleted != 2 || res2.Archived != 0 || len(res2.Files) != 0 {
+		t.Fatalf("Prune после RestoreHold = %+v, ожидалось удаление 2 записей без архива", res2)
+	}
+	if archives, err := p.Archives(); err != nil || len(archives) != 2 {
+		t.Fatalf("архивы = %+v, %v, ожидалось 2 файла", archives, err)
+	}
+}
+
+func TestNoArchiveDir(t *testing.T) {
+	p := NewPruner(repository.NewMemoryStore().Logs, clock.NewManual(now), Config{BatchSize: 1, Timeout: time.Second})
+	if _, err := p.Archives(); !errors.Is(err, ErrNoArchive) {
+		t.Fatalf("Archives = %v, ожидалась ErrNoArchive", err)
+	}
+	if _, err := p.Restore(context.Background(), now.Add(-time.Hour), now, ""); !errors.Is(err, ErrNoArchive) {
+		t.Fatalf("Restore = %v, ожидалась ErrNoArchive", err)
+	}
+}
@@ -2,11 +2,13 @@ package routes
 
 import (
 	"access-control-system/controllers"
+	"access-control-system/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(router *gin.Engine) {
+func RegisterRoutes(router *gin.Engine, h *controllers.Handler) {
 
-	router.GET("/access/:user_id/room/:room_number", controllers.CheckAccess)
+	router.GET("/access/:user_id/room/:room_number", h.CheckAccess)
+	router.POST("/access/simulate", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.SimulateAccess)
 }
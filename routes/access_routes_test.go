@@ -0,0 +1,134 @@
+package routes_test
+
+import (
+	"access-control-system/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckAccess(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	rooms := []models.Room{
+		{ID: primitive.NewObjectID(), RoomNumber: "101", Timezone: "UTC"},
+		{ID: primitive.NewObjectID(), RoomNumber: "102", Timezone: "UTC"},
+	}
+	if err := env.store.Rooms.CreateMany(ctx, rooms); err != nil {
+		t.Fatal(err)
+	}
+	teacher := env.createUser(t, models.User{
+		FirstName: "Иван", SecondName: "Петров", Email: "ivan@example.com",
+		Role: models.RoleTeacher, AccessRooms: []string{"101,102"},
+	}, "secret-password")
+	outsider := env.createUser(t, models.User{
+		FirstName: "Анна", SecondName: "Смирнова", Email: "anna@example.com",
+		Role: models.RoleTeacher, AccessRooms: []string{"102"},
+	}, "secret-password")
+	// Часы окружения — понедельник 10:00 UTC
+	err := env.store.Schedules.Create(ctx, &models.Schedule{
+		ID: primitive.NewObjectID(), UserID: teacher.ID, FirstName: teacher.FirstName, SecondName: teacher.SecondName,
+		Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "101", Subject: "Физика",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		user primitive.ObjectID
+		room string
+		at   time.Time
+		code int
+	}{
+		{"в окне занятия", teacher.ID, "101", time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC), http.StatusOK},
+		{"нет доступа к комнате", outsider.ID, "101", time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC), http.StatusForbidden},
+		{"нет расписания в комнате", teacher.ID, "102", time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC), http.StatusForbidden},
+		{"нет расписания в этот день", teacher.ID, "101", time.Date(2026, 9, 15, 10, 0, 0, 0, time.UTC), http.StatusForbidden},
+		{"до начала занятия", teacher.ID, "101", time.Date(2026, 9, 14, 8, 59, 0, 0, time.UTC), http.StatusForbidden},
+		{"после конца занятия", teacher.ID, "101", time.Date(2026, 9, 14, 11, 0, 1, 0, time.UTC), http.StatusForbidden},
+		{"неизвестный пользователь", primitive.NewObjectID(), "101", time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env.clock.Set(tt.at)
+			w := env.request(t, http.MethodGet, "/access/"+tt.user.Hex()+"/room/"+tt.room, "", nil)
+			if w.Code != tt.code {
+				t.Fatalf("GET /access = %d, ожидался %d: %s", w.Code, tt.code, w.Body)
+			}
+		})
+	}
+
+	if w := env.request(t, http.MethodGet, "/access/not-an-id/room/101", "", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("некорректный user_id = %d, ожидался 400", w.Code)
+	}
+}
+
+func TestSimulateAccess(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.store.Rooms.CreateMany(ctx, []models.Room{{ID: primitive.NewObjectID(), RoomNumber: "101", Timezone: "Asia/Tokyo"}}); err != nil {
+		t.Fatal(err)
+	}
+	teacher := env.createUser(t, models.User{
+		FirstName: "Иван", Email: "ivan@example.com", Role: models.RoleTeacher, AccessRooms: []string{"101"},
+	}, "secret-password")
+	err := env.store.Schedules.Create(ctx, &models.Schedule{
+		ID: primitive.NewObjectID(), UserID: teacher.ID, Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "101",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Текущее время не влияет на симуляцию
+	env.clock.Set(time.Date(2026, 9, 16, 3, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name      string
+		timestamp string
+		granted   bool
+		reason    string
+		local     string
+	}{
+		{"в окне по поясу комнаты", "2026-09-14T00:30:00Z", true, "access_granted", "2026-09-14T09:30:00+09:00"},
+		{"после окна", "2026-09-14T02:00:00Z", false, "unauthorized_time_access", "2026-09-14T11:00:00+09:00"},
+		{"понедельник в Токио, воскресенье по UTC", "2026-09-13T23:30:00Z", false, "unauthorized_time_access", "2026-09-14T08:30:00+09:00"},
+		{"другой день", "2026-09-15T00:30:00Z", false, "unauthorized_schedule_access", "2026-09-15T09:30:00+09:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(t, http.MethodPost, "/access/simulate", map[string]string{
+				"user_id": teacher.ID.Hex(), "room_number": "101", "timestamp": tt.timestamp,
+			})
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST /access/simulate = %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Decision struct {
+					Granted   bool   `json:"granted"`
+					Reason    string `json:"reason"`
+					Timezone  string `json:"timezone"`
+					LocalTime string `json:"local_time"`
+				} `json:"decision"`
+			}
+			decode(t, w, &resp)
+			d := resp.Decision
+			if d.Granted != tt.granted || d.Reason != tt.reason || d.LocalTime != tt.local || d.Timezone != "Asia/Tokyo" {
+				t.Fatalf("решение %+v, ожидалось granted=%t reason=%s local_time=%s", d, tt.granted, tt.reason, tt.local)
+			}
+		})
+	}
+
+	if w := env.request(t, http.MethodPost, "/access/simulate", "", map[string]string{
+		"user_id": teacher.ID.Hex(), "room_number": "101", "timestamp": "2026-09-14T00:30:00Z",
+	}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("симуляция без токена = %d, ожидался 401", w.Code)
+	}
+	if w := env.do(t, http.MethodPost, "/access/simulate", map[string]string{"user_id": teacher.ID.Hex()}); w.Code != http.StatusBadRequest {
+		t.Fatalf("симуляция без комнаты и времени = %d, ожидался 400", w.Code)
+	}
+}
@@ -7,10 +7,30 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAdminRoutes(router *gin.Engine) {
+func RegisterAdminRoutes(router *gin.Engine, h *controllers.Handler) {
 	adminGroup := router.Group("/admin")
 	adminGroup.Use(middleware.JWTAuthMiddleware(), middleware.AdminOnly())
 	{
 		adminGroup.GET("/dashboard", controllers.AdminDashboard)
+		adminGroup.GET("/consistency", h.CheckConsistency)
+
+		adminGroup.POST("/webhooks", h.CreateWebhook)
+		adminGroup.GET("/webhooks", h.GetWebhooks)
+		adminGroup.GET("/webhooks/:id", h.GetWebhook)
+		adminGroup.PUT("/webhooks/:id", h.UpdateWebhook)
+		adminGroup.DELETE("/webhooks/:id", h.DeleteWebhook)
+		adminGroup.POST("/webhooks/:id/test", h.TestWebhook)
+		adminGroup.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+		adminGroup.POST("/webhooks/:id/deliveries/:delivery_id/retry", h.RetryWebhookDelivery)
+
+		adminGroup.GET("/alerts", h.GetAlerts)
+		adminGroup.POST("/alerts/:id/ack", h.AcknowledgeAlert)
+		adminGroup.POST("/alerts/:id/resolve", h.ResolveAlert)
+
+		adminGroup.GET("/audit", h.GetAudit)
+		adminGroup.GET("/audit/verify", h.VerifyAudit)
+
+		adminGroup.GET("/logs/archives", h.GetLogArchives)
+		adminGroup.POST("/logs/restore", h.RestoreLogs)
 	}
 }
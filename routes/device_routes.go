@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceRoutes — маршруты контроллеров дверей, авторизация по X-Device-Key
+func DeviceRoutes(router *gin.Engine, h *controllers.Handler) {
+	router.GET("/bundles/public-key", h.GetBundlePublicKey)
+
+	device := router.Group("/controllers/:controller_id", middleware.DeviceAuth())
+	device.GET("/bundle", h.GetOfflineBundle)
+	device.POST("/access-logs", h.UploadAccessLogs)
+}
@@ -0,0 +1,195 @@
+package routes_test
+
+import (
+	"access-control-system/bundle"
+	"access-control-system/config"
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	deviceID  = "door-1"
+	deviceKey = "door-1-secret-key"
+)
+
+// newDeviceEnv — окружение с контроллером door-1, обслуживающим комнату 101,
+// и преподавателем с занятием по понедельникам 09:00-11:00 в ней
+func newDeviceEnv(t *testing.T) (*testEnv, models.User) {
+	t.Helper()
+	env := newTestEnv(t)
+	config.Current.Devices = map[string]config.DeviceConfig{deviceID: {Key: deviceKey}}
+	ctx := context.Background()
+
+	err := env.store.Rooms.CreateMany(ctx, []models.Room{
+		{ID: primitive.NewObjectID(), RoomNumber: "101", Timezone: "UTC", AccessControllerID: deviceID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teacher := env.createUser(t, models.User{
+		FirstName: "Иван", SecondName: "Петров", Email: "ivan@example.com",
+		Role: models.RoleTeacher, AccessRooms: []string{"101"},
+	}, "secret-password")
+	err = env.store.Schedules.Create(ctx, &models.Schedule{
+		ID: primitive.NewObjectID(), UserID: teacher.ID, FirstName: teacher.FirstName, SecondName: teacher.SecondName,
+		Day: "Monday", StartTime: "09:00", EndTime: "11:00", RoomNumber: "101", Subject: "Физика",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, teacher
+}
+
+func (e *testEnv) deviceRequest(t *testing.T, method, path, key string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, nil)
+	if body != nil {
+		req = httptest.NewRequest(method, path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("X-Device-Key", key)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeviceAuth(t *testing.T) {
+	env, _ := newDeviceEnv(t)
+
+	tests := []struct {
+		name, path, key string
+	}{
+		{"без ключа", "/controllers/" + deviceID + "/bundle", ""},
+		{"неверный ключ", "/controllers/" + deviceID + "/bundle", "wrong-key-wrong-key"},
+		{"ключ другого контроллера", "/controllers/door-2/bundle", deviceKey},
+	}
+	for _, tt := range tests {
+		if w := env.deviceRequest(t, http.MethodGet, tt.path, tt.key, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: GET %s = %d, ожидался 401", tt.name, tt.path, w.Code)
+		}
+	}
+	if w := env.deviceRequest(t, http.MethodPost, "/controllers/"+deviceID+"/access-logs", "wrong-key-wrong-key", []models.AccessLog{}); w.Code != http.StatusUnauthorized {
+		t.Errorf("загрузка с неверным ключом = %d, ожидался 401", w.Code)
+	}
+}
+
+func TestOfflineBundleSigned(t *testing.T) {
+	env, teacher := newDeviceEnv(t)
+
+	w := env.deviceRequest(t, http.MethodGet, "/controllers/"+deviceID+"/bundle?days=7", deviceKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET bundle = %d: %s", w.Code, w.Body)
+	}
+	var signed bundle.Signed
+	decode(t, w, &signed)
+	policy, err := bundle.Verify(signed, env.signer.PublicKey())
+	if err != nil {
+		t.Fatalf("пакет не прошёл проверку: %v", err)
+	}
+	if policy.ControllerID != deviceID || len(policy.Rooms) != 1 || len(policy.Rooms[0].Keys) == 0 ||
+		policy.Rooms[0].Keys[0].KeyID != teacher.KeyID {
+		t.Fatalf("содержимое пакета = %+v", policy)
+	}
+
+	// Подпись не переносится на изменённое содержимое
+	policy.ValidUntil += 365 * 24 * 3600
+	raw, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed.Payload = base64.RawURLEncoding.EncodeToString(raw)
+	if _, err := bundle.Verify(signed, env.signer.PublicKey()); err == nil {
+		t.Fatal("изменённый пакет прошёл проверку")
+	}
+}
+
+func TestUploadAccessLogs(t *testing.T) {
+	env, teacher := newDeviceEnv(t)
+	// Понедельник неделей раньше часов окружения
+	monday := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+	entries := []models.AccessLog{
+		// Совпадает с решением сервера
+		{KeyID: teacher.KeyID, RoomID: "101", AccessTime: monday.Add(10 * time.Hour), Status: models.AccessStatusGranted},
+		// Отказ вне занятия, как и у сервера
+		{KeyID: teacher.KeyID, RoomID: "101", AccessTime: monday.Add(12 * time.Hour), Status: models.AccessStatusDenied},
+		// Контроллер пропустил вне занятия
+		{KeyID: teacher.KeyID, RoomID: "101", AccessTime: monday.Add(13 * time.Hour), Status: models.AccessStatusGranted},
+		// Комната другого контроллера
+		{KeyID: teacher.KeyID, RoomID: "102", AccessTime: monday.Add(10 * time.Hour), Status: models.AccessStatusGranted},
+	}
+	path := "/controllers/" + deviceID + "/access-logs"
+
+	type result struct {
+		Stored     int `json:"stored"`
+		Duplicates int `json:"duplicates"`
+		Mismatches int `json:"mismatches"`
+		Rejected   []struct {
+			Index int `json:"index"`
+		} `json:"rejected"`
+	}
+	w := env.deviceRequest(t, http.MethodPost, path, deviceKey, entries)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s = %d: %s", path, w.Code, w.Body)
+	}
+	var first result
+	decode(t, w, &first)
+	if first.Stored != 3 || first.Duplicates != 0 || first.Mismatches != 1 || len(first.Rejected) != 1 || first.Rejected[0].Index != 3 {
+		t.Fatalf("первая загрузка = %+v", first)
+	}
+
+	// Повторная загрузка тех же записей ничего не добавляет
+	w = env.deviceRequest(t, http.MethodPost, path, deviceKey, entries[:3])
+	var second result
+	decode(t, w, &second)
+	if w.Code != http.StatusOK || second.Stored != 0 || second.Duplicates != 3 || second.Mismatches != 0 {
+		t.Fatalf("повторная загрузка = %d %+v", w.Code, second)
+	}
+
+	ctx := context.Background()
+	var stored []models.AccessLog
+	err := env.store.Access.Each(ctx, repository.AccessLogFilter{}, func(a models.AccessLog) error {
+		stored = append(stored, a)
+		return nil
+	})
+	if err != nil || len(stored) != 3 {
+		t.Fatalf("сохранено проходов: %d, %v", len(stored), err)
+	}
+
+	// События журнала датируются временем прохода, а не загрузки
+	if err := env.events.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	logs, err := env.store.Logs.List(ctx, repository.LogFilter{ControllerID: deviceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{
+		eventlog.TypeOutsideSchedule: monday.Add(12 * time.Hour),
+		eventlog.TypeOfflineMismatch: monday.Add(13 * time.Hour),
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("событий журнала: %d, ожидалось %d: %+v", len(logs), len(want), logs)
+	}
+	for _, entry := range logs {
+		if at, ok := want[entry.EventType]; !ok || !entry.Timestamp.Equal(at) {
+			t.Errorf("событие %s от %v, ожидалось %v", entry.EventType, entry.Timestamp, at)
+		}
+	}
+}
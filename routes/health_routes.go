@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthPaths — служебные пути, не требующие авторизации и не попадающие в журнал запросов
+var HealthPaths = []string{"/healthz", "/readyz", "/version", "/metrics"}
+
+func HealthRoutes(router *gin.Engine, h *controllers.HealthHandler) {
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+	router.GET("/version", h.Version)
+}
+
+// MetricsRoutes отдаёт метрики в текстовом формате Prometheus
+func MetricsRoutes(router *gin.Engine, registry *metrics.Registry) {
+	router.GET("/metrics", gin.WrapH(registry))
+}
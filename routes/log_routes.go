@@ -2,11 +2,14 @@ package routes
 
 import (
 	"access-control-system/controllers"
+	"access-control-system/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func LogRoutes(router *gin.Engine) {
-	router.POST("/logs", controllers.CreateLog)
-	router.GET("/logs", controllers.GetLogs)
+func LogRoutes(router *gin.Engine, h *controllers.Handler) {
+	// Писать в журнал могут только контроллеры дверей и внутренние сервисы
+	router.POST("/logs", middleware.TrustedCaller(), h.CreateLog)
+	router.GET("/logs", h.GetLogs)
+	router.GET("/logs/event-types", h.GetLogEventTypes)
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterPublicRoutes(router *gin.Engine) {
-	router.POST("/login", controllers.Login)
+func RegisterPublicRoutes(router *gin.Engine, h *controllers.Handler) {
+	router.POST("/login", h.Login)
+	router.POST("/login/change-password", h.ChangePassword)
 }
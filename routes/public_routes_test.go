@@ -0,0 +1,47 @@
+package routes_test
+
+import (
+	"access-control-system/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginRequiresTemporaryPasswordChange(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, models.User{
+		FirstName: "Новый", Email: "new@example.com", Role: models.RoleAdmin,
+		AccessRooms: []string{"*"}, MustChangePassword: true,
+	}, "temporary")
+
+	login := gin.H{"identifier": "new@example.com", "password": "temporary"}
+	if w := env.request(t, http.MethodPost, "/login", "", login); w.Code != http.StatusForbidden {
+		t.Fatalf("вход с временным паролем = %d, ожидался 403: %s", w.Code, w.Body)
+	}
+
+	change := gin.H{"identifier": "new@example.com", "password": "temporary", "new_password": "temporary"}
+	if w := env.request(t, http.MethodPost, "/login/change-password", "", change); w.Code != http.StatusBadRequest {
+		t.Fatalf("смена на тот же пароль = %d, ожидался 400", w.Code)
+	}
+	change["new_password"] = "permanent"
+	w := env.request(t, http.MethodPost, "/login/change-password", "", change)
+	if w.Code != http.StatusOK {
+		t.Fatalf("смена пароля = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &resp)
+	if resp.Token == "" {
+		t.Fatal("после смены пароля не выдан токен")
+	}
+
+	if w := env.request(t, http.MethodPost, "/login", "", login); w.Code != http.StatusUnauthorized {
+		t.Fatalf("вход со старым паролем = %d, ожидался 401", w.Code)
+	}
+	login["password"] = "permanent"
+	if w := env.request(t, http.MethodPost, "/login", "", login); w.Code != http.StatusOK {
+		t.Fatalf("вход с новым паролем = %d: %s", w.Code, w.Body)
+	}
+}
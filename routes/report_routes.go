@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportRoutes — выгрузки и отчёты для администраторов
+func ReportRoutes(router *gin.Engine, h *controllers.Handler) {
+	reports := router.Group("/reports", middleware.JWTAuthMiddleware(), middleware.AdminOnly())
+	reports.GET("/logs", h.ExportLogs)
+	reports.GET("/access", h.ExportAccessLogs)
+	reports.GET("/access.pdf", h.AccessReportPDF)
+	reports.GET("/attendance", h.GetAttendanceReport)
+	reports.GET("/utilization", h.GetUtilizationReport)
+}
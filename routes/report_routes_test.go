@@ -0,0 +1,63 @@
+package routes_test
+
+import (
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/pdf"
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Отчёт PDF считает и решения сервера из журнала, и загруженные проходы
+func TestAccessReportCountsBothSources(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	at := time.Date(2026, 9, 10, 9, 0, 0, 0, time.UTC)
+	user := primitive.NewObjectID()
+
+	logs := []models.Log{
+		{EventType: eventlog.TypeAccessGranted, UserID: user, Room: "101"},
+		{EventType: eventlog.TypeOutsideSchedule, UserID: user, Room: "101"},
+		{EventType: eventlog.TypeStaleOpen, UserID: user, Room: "101"},
+		// Отказ из загрузки контроллера уже есть среди проходов
+		{EventType: eventlog.TypeNoSchedule, UserID: user, Room: "101", ControllerID: "door-1",
+			Params: map[string]string{"offline_time": at.Format(time.RFC3339)}},
+		{EventType: eventlog.TypeAdminLoginDenied},
+	}
+	for i := range logs {
+		logs[i].Timestamp = at
+		if err := env.store.Logs.Insert(ctx, &logs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := env.store.Access.InsertMany(ctx, []models.AccessLog{
+		{AccessTime: at, Status: models.AccessStatusGranted, RoomID: "101", KeyID: "k1", UserID: user, ControllerID: "door-1"},
+		{AccessTime: at.Add(time.Minute), Status: models.AccessStatusDenied, RoomID: "101", KeyID: "k1", UserID: user, ControllerID: "door-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := env.do(t, http.MethodGet, "/reports/access.pdf?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /reports/access.pdf = %d: %s", w.Code, w.Body)
+	}
+	for label, want := range map[string]string{
+		"Проходов всего":          "5",
+		"Разрешено":               "3",
+		"Отказано":                "2",
+		"Решений сервера":         "3",
+		"Загружено контроллерами": "2",
+	} {
+		re := regexp.MustCompile(`\(` + regexp.QuoteMeta(pdf.Latin(label)) + ` +(\d+)\)`)
+		m := re.FindSubmatch(w.Body.Bytes())
+		if m == nil || string(m[1]) != want {
+			t.Errorf("%s = %s, ожидалось %s", label, m, want)
+		}
+	}
+}
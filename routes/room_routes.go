@@ -2,12 +2,14 @@ package routes
 
 import (
 	"access-control-system/controllers"
+	"access-control-system/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RoomRoutes(r *gin.Engine) {
-	r.POST("/rooms", controllers.CreateRooms)
+func RoomRoutes(r *gin.Engine, h *controllers.Handler) {
+	r.POST("/rooms", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.CreateRooms)
 
-	r.GET("/rooms", controllers.GetRooms)
+	r.GET("/rooms", h.GetRooms)
+	r.DELETE("/rooms/:id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.DeleteRoom)
 }
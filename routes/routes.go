@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register подключает все маршруты API к router
+func Register(router *gin.Engine, h *controllers.Handler) {
+	// Автор изменений для журнала аудита, если токен передан. Токены
+	// неактивных пользователей отклоняются на любом маршруте
+	router.Use(middleware.Identify(), h.RequireActiveUser)
+
+	RegisterPublicRoutes(router, h)
+	UserRoutes(router, h)
+	RegisterRoutes(router, h)
+	RoomRoutes(router, h)
+	ScheduleRoutes(router, h)
+	RegisterAdminRoutes(router, h)
+	LogRoutes(router, h)
+	SearchRoutes(router, h)
+	DeviceRoutes(router, h)
+	StreamRoutes(router, h)
+	ReportRoutes(router, h)
+}
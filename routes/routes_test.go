@@ -0,0 +1,157 @@
+package routes_test
+
+import (
+	"access-control-system/bundle"
+	"access-control-system/clock"
+	"access-control-system/config"
+	"access-control-system/controllers"
+	"access-control-system/eventlog"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/routes"
+	"access-control-system/search"
+	"access-control-system/stream"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	adminEmail    = "admin@example.com"
+	adminPassword = "admin-password"
+)
+
+// testEnv — API поверх хранилища в памяти с ручными часами и токеном
+// администратора
+type testEnv struct {
+	router *gin.Engine
+	store  *repository.Store
+	clock  *clock.Manual
+	events *eventlog.Writer
+	signer *bundle.Signer
+	admin  models.User
+	token  string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.Current = testConfig(t)
+
+	store := repository.NewMemoryStore()
+	env := &testEnv{
+		router: gin.New(),
+		store:  store,
+		clock:  clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC)),
+		events: eventlog.NewWriter(store.Logs, time.Second),
+	}
+	var err error
+	if env.signer, err = bundle.NewSigner(""); err != nil {
+		t.Fatal(err)
+	}
+	h := controllers.NewHandler(controllers.Deps{
+		Store:  store,
+		Clock:  env.clock,
+		Events: env.events,
+		Signer: env.signer,
+		Stream: stream.NewBroker(0, 0),
+		Search: search.NewService(store, time.Second),
+	})
+	routes.Register(env.router, h)
+
+	env.admin = env.createUser(t, models.User{
+		FirstName: "Админ", SecondName: "Системы", Email: adminEmail,
+		Role: models.RoleAdmin, AccessRooms: []string{"*"},
+	}, adminPassword)
+
+	w := env.request(t, http.MethodPost, "/login", "", gin.H{"identifier": adminEmail, "password": adminPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /login = %d: %s", w.Code, w.Body)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &login)
+	env.token = login.Token
+	return env
+}
+
+// testConfig — конфигурация по умолчанию с вычисленными часовыми поясами
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Mongo.URI, cfg.Mongo.Database = "mongodb://localhost:27017", "test"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// createUser сохраняет пользователя с паролем password напрямую в хранилище
+func (e *testEnv) createUser(t *testing.T, user models.User, password string) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = primitive.NewObjectID()
+	user.KeyID = user.ID.Hex()
+	user.Password = string(hash)
+	if user.Status == "" {
+		user.Status = models.StatusActive
+	}
+	if err := e.store.Users.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// do выполняет запрос от имени администратора
+func (e *testEnv) do(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return e.request(t, method, path, e.token, body)
+}
+
+// request выполняет запрос с токеном token (пустой — без авторизации).
+// body, не являющийся []byte, кодируется в JSON
+func (e *testEnv) request(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("ответ не JSON: %v: %s", err, w.Body)
+	}
+}
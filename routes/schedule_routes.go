@@ -2,13 +2,14 @@ package routes
 
 import (
 	"access-control-system/controllers"
+	"access-control-system/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ScheduleRoutes(r *gin.Engine) {
-	r.POST("/schedule", controllers.CreateSchedule)
-	r.GET("/schedule", controllers.GetSchedules)
-	r.PUT("/schedule/:id", controllers.UpdateSchedule)
-	r.DELETE("/schedule/:id", controllers.DeleteSchedule)
+func ScheduleRoutes(r *gin.Engine, h *controllers.Handler) {
+	r.POST("/schedule", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.CreateSchedule)
+	r.GET("/schedule", h.GetSchedules)
+	r.PUT("/schedule/:id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.UpdateSchedule)
+	r.DELETE("/schedule/:id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.DeleteSchedule)
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchRoutes(router *gin.Engine, h *controllers.Handler) {
+	router.GET("/search", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.Search)
+}
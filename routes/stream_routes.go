@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"access-control-system/controllers"
+	"access-control-system/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamRoutes — потоки событий в реальном времени. Токен можно передать
+// в ?token=, так как EventSource и WebSocket не отправляют заголовки
+func StreamRoutes(router *gin.Engine, h *controllers.Handler) {
+	events := router.Group("/events", middleware.JWTAuthOrQuery(), h.RequireActiveUser, middleware.AdminOnly())
+	events.GET("/stream", h.StreamEvents)
+	events.GET("/ws", h.StreamEventsWS)
+}
@@ -2,13 +2,23 @@ package routes
 
 import (
 	"access-control-system/controllers"
+	"access-control-system/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(router *gin.Engine) {
-	router.POST("/users", controllers.CreateUser)
-	router.GET("/users", controllers.GetUsers)
-	router.PUT("/users/:id", controllers.UpdateUser)
-	router.DELETE("/users/:id", controllers.DeleteUser)
+func UserRoutes(router *gin.Engine, h *controllers.Handler) {
+	router.POST("/users", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.CreateUser)
+	router.POST("/users/import", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.ImportUsers)
+	router.GET("/users/export", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.ExportUsers)
+	router.GET("/users", h.GetUsers)
+	router.PUT("/users/:id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.UpdateUser)
+	router.DELETE("/users/:id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.DeleteUser)
+	router.POST("/users/:id/suspend", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.SuspendUser)
+	router.POST("/users/:id/restore", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.RestoreUser)
+	router.DELETE("/users/:id/permanent", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.PurgeUser)
+	router.GET("/users/:id/photos", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.GetUserPhotos)
+	router.POST("/users/:id/photos", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.UploadUserPhoto)
+	router.DELETE("/users/:id/photos/:photo_id", middleware.JWTAuthMiddleware(), middleware.AdminOnly(), h.RefreshPolicy, h.DeleteUserPhoto)
+	router.GET("/photos/:user_id/:photo_id", h.DownloadPhoto)
 }
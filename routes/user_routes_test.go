@@ -0,0 +1,226 @@
+package routes_test
+
+import (
+	"access-control-system/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ни один ответ с пользователями не должен содержать пароль или его хэш
+func TestUserResponsesHavePasswordStripped(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, models.User{
+		FirstName: "Иван", SecondName: "Петров", Email: "ivan@example.com",
+		Role: models.RoleTeacher, AccessRooms: []string{"101"},
+	}, "secret-password")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+	}{
+		{"login", http.MethodPost, "/login", "", gin.H{"identifier": adminEmail, "password": adminPassword}},
+		{"create", http.MethodPost, "/users", env.token, gin.H{
+			"first_name": "Анна", "second_name": "Смирнова", "email": "anna@example.com", "password": "anna-password",
+		}},
+		{"list", http.MethodGet, "/users", env.token, nil},
+		{"update", http.MethodPut, "/users/" + user.ID.Hex(), env.token, gin.H{"city": "Алматы"}},
+		{"export json", http.MethodGet, "/users/export?format=json", env.token, nil},
+		{"export csv", http.MethodGet, "/users/export?format=csv", env.token, nil},
+		{"search", http.MethodGet, "/search?q=Иван", env.token, nil},
+		{"import dry run", http.MethodPost, "/users/import?dry_run=true&format=json", env.token, []models.UserRequest{
+			{FirstName: "Пётр", SecondName: "Сидоров", Email: "petr@example.com", Password: "petr-password"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.request(t, tt.method, tt.path, tt.token, tt.body)
+			if w.Code >= http.StatusBadRequest {
+				t.Fatalf("%s %s = %d: %s", tt.method, tt.path, w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if strings.Contains(body, "$2a$") {
+				t.Fatalf("ответ содержит bcrypt-хэш: %s", body)
+			}
+			if strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+				header, _, _ := strings.Cut(body, "\n")
+				if strings.Contains(header, "password") {
+					t.Fatalf("CSV содержит колонку пароля: %s", header)
+				}
+				return
+			}
+			var doc interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("ответ не JSON: %v", err)
+			}
+			if path, ok := findKey(doc, "password", "$"); ok {
+				t.Fatalf("ответ содержит ключ %s: %s", path, body)
+			}
+		})
+	}
+}
+
+// findKey ищет ключ key на любой глубине JSON-документа и возвращает путь к нему
+func findKey(doc interface{}, key, path string) (string, bool) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == key {
+				return path + "." + k, true
+			}
+			if p, ok := findKey(child, key, path+"."+k); ok {
+				return p, true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if p, ok := findKey(child, key, path+"[]"); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+func TestSuspendRequiresAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, models.User{FirstName: "Иван", Email: "ivan@example.com", Role: models.RoleTeacher}, "secret-password")
+
+	for _, action := range []string{"suspend", "restore"} {
+		w := env.request(t, http.MethodPost, "/users/"+user.ID.Hex()+"/"+action, "", nil)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("POST /users/:id/%s без токена = %d, ожидался 401", action, w.Code)
+		}
+	}
+}
+
+func TestSuspendedAdminTokenRejected(t *testing.T) {
+	env := newTestEnv(t)
+	other := env.createUser(t, models.User{
+		FirstName: "Второй", Email: "second@example.com", Role: models.RoleAdmin, AccessRooms: []string{"*"},
+	}, "second-password")
+
+	w := env.request(t, http.MethodPost, "/login", "", gin.H{"identifier": "second@example.com", "password": "second-password"})
+	var login struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &login)
+
+	if w := env.do(t, http.MethodPost, "/users/"+other.ID.Hex()+"/suspend", nil); w.Code != http.StatusOK {
+		t.Fatalf("POST /users/:id/suspend = %d: %s", w.Code, w.Body)
+	}
+	if w := env.request(t, http.MethodGet, "/users/export", login.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("токен заблокированного администратора принят: %d", w.Code)
+	}
+	if w := env.do(t, http.MethodGet, "/users/export", nil); w.Code != http.StatusOK {
+		t.Fatalf("токен активного администратора отклонён: %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestPermanentDeletesRequireAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	id := primitive.NewObjectID().Hex()
+
+	for _, path := range []string{"/users/" + id + "/permanent", "/rooms/" + id} {
+		if w := env.request(t, http.MethodDelete, path, "", nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("DELETE %s без токена = %d, ожидался 401", path, w.Code)
+		}
+	}
+}
+
+func TestMutationsRequireAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	id := primitive.NewObjectID().Hex()
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/users"},
+		{http.MethodPut, "/users/" + id},
+		{http.MethodDelete, "/users/" + id},
+		{http.MethodGet, "/users/" + id + "/photos"},
+		{http.MethodPost, "/users/" + id + "/photos"},
+		{http.MethodDelete, "/users/" + id + "/photos/" + id},
+		{http.MethodPost, "/rooms"},
+		{http.MethodPost, "/schedule"},
+		{http.MethodPut, "/schedule/" + id},
+		{http.MethodDelete, "/schedule/" + id},
+	}
+	for _, r := range routes {
+		if w := env.request(t, r.method, r.path, "", gin.H{}); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s без токена = %d, ожидался 401", r.method, r.path, w.Code)
+		}
+	}
+}
+
+func TestUserCRUD(t *testing.T) {
+	env := newTestEnv(t)
+
+	w := env.do(t, http.MethodPost, "/users", gin.H{
+		"first_name": "Анна", "second_name": "Смирнова", "email": "Anna@Example.com",
+		"phone": "+7 701 123 45 67", "password": "anna-password", "access_rooms": []string{"101"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /users = %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		User models.UserResponse `json:"user"`
+	}
+	decode(t, w, &created)
+	id := created.User.ID.Hex()
+	if created.User.Role != models.RoleTeacher || created.User.Email != "anna@example.com" {
+		t.Fatalf("пользователь создан с ролью %q и email %q", created.User.Role, created.User.Email)
+	}
+
+	if w := env.do(t, http.MethodPost, "/users", gin.H{
+		"first_name": "Дубль", "email": "anna@example.com", "password": "other-password",
+	}); w.Code != http.StatusConflict {
+		t.Fatalf("повторный email = %d, ожидался 409: %s", w.Code, w.Body)
+	}
+	if w := env.do(t, http.MethodPost, "/users", gin.H{"first_name": "Без пароля"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("пользователь без пароля = %d, ожидался 400", w.Code)
+	}
+
+	if w := env.do(t, http.MethodPut, "/users/"+id, gin.H{"city": "Астана"}); w.Code != http.StatusOK {
+		t.Fatalf("PUT /users/:id = %d: %s", w.Code, w.Body)
+	}
+	user := findUser(t, env, id, "")
+	if user == nil || user.City != "Астана" {
+		t.Fatalf("изменение не сохранено: %+v", user)
+	}
+
+	if w := env.do(t, http.MethodDelete, "/users/"+id, nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /users/:id = %d: %s", w.Code, w.Body)
+	}
+	if findUser(t, env, id, "") != nil {
+		t.Fatal("деактивированный пользователь возвращается по умолчанию")
+	}
+	if user := findUser(t, env, id, "deactivated"); user == nil || user.Status != models.StatusDeactivated {
+		t.Fatalf("пользователь не деактивирован: %+v", user)
+	}
+}
+
+// findUser возвращает пользователя id из GET /users?status=status или nil
+func findUser(t *testing.T, env *testEnv, id, status string) *models.UserResponse {
+	t.Helper()
+	w := env.do(t, http.MethodGet, "/users?status="+status, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /users = %d: %s", w.Code, w.Body)
+	}
+	// Пустой список возвращается объектом с сообщением
+	if w.Body.Bytes()[0] != '[' {
+		return nil
+	}
+	var users []models.UserResponse
+	decode(t, w, &users)
+	for i := range users {
+		if users[i].ID.Hex() == id {
+			return &users[i]
+		}
+	}
+	return nil
+}
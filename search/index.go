@@ -0,0 +1,313 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	TypeUser     = "user"
+	TypeRoom     = "room"
+	TypeSchedule = "schedule"
+)
+
+// Field — поле документа, по которому выполняется поиск
+type Field struct {
+	Name   string
+	Value  string
+	Weight float64
+	// Phone включает сравнение только по цифрам (для номеров телефонов)
+	Phone bool
+}
+
+// Document — единица индексации (пользователь, комната или расписание)
+type Document struct {
+	Type   string
+	ID     string
+	Title  string
+	Fields []Field
+	Data   interface{}
+}
+
+// Result — найденный документ с оценкой релевантности и подсветкой совпадений
+type Result struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+	Data       interface{}       `json:"data"`
+}
+
+type token struct {
+	text       string
+	folded     string
+	start, end int
+}
+
+type indexedField struct {
+	Field
+	tokens []token
+	digits string
+}
+
+type indexedDoc struct {
+	Document
+	fields []indexedField
+}
+
+type docKey struct {
+	typ, id string
+}
+
+// Index — индекс в памяти с поддержкой транслитерации кириллица/латиница.
+// Безопасен для одновременного использования
+type Index struct {
+	mu   sync.RWMutex
+	docs map[docKey]indexedDoc
+}
+
+func NewIndex() *Index {
+	return &Index{docs: map[docKey]indexedDoc{}}
+}
+
+// Add добавляет документ или заменяет документ с теми же Type и ID
+func (ix *Index) Add(doc Document) {
+	indexed := indexDocument(doc)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs[docKey{doc.Type, doc.ID}] = indexed
+}
+
+// Remove удаляет документ, если он есть в индексе
+func (ix *Index) Remove(typ, id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.docs, docKey{typ, id})
+}
+
+// Replace заменяет все документы типа typ на docs
+func (ix *Index) Replace(typ string, docs []Document) {
+	indexed := make([]indexedDoc, len(docs))
+	for i, doc := range docs {
+		indexed[i] = indexDocument(doc)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key := range ix.docs {
+		if key.typ == typ {
+			delete(ix.docs, key)
+		}
+	}
+	for _, doc := range indexed {
+		ix.docs[docKey{doc.Type, doc.ID}] = doc
+	}
+}
+
+func indexDocument(doc Document) indexedDoc {
+	indexed := indexedDoc{Document: doc}
+	for _, f := range doc.Fields {
+		if strings.TrimSpace(f.Value) == "" {
+			continue
+		}
+		if f.Weight == 0 {
+			f.Weight = 1
+		}
+		field := indexedField{Field: f, tokens: tokenize(f.Value)}
+		if f.Phone {
+			field.digits = digitsOnly(f.Value)
+		}
+		indexed.fields = append(indexed.fields, field)
+	}
+	return indexed
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search возвращает документы типов types (nil — любых), в которых
+// найдены все слова запроса, отсортированные по убыванию релевантности
+func (ix *Index) Search(query string, limit int, types map[string]bool) []Result {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []Result
+	ix.mu.RLock()
+	for _, doc := range ix.docs {
+		if types != nil && !types[doc.Type] {
+			continue
+		}
+		if res, ok := doc.match(terms); ok {
+			results = append(results, res)
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		if results[i].Title != results[j].Title {
+			return results[i].Title < results[j].Title
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+type queryTerm struct {
+	folded string
+	digits string
+}
+
+func queryTerms(query string) []queryTerm {
+	var terms []queryTerm
+	for _, tok := range tokenize(query) {
+		term := queryTerm{folded: tok.folded}
+		if d := digitsOnly(tok.text); len(d) >= 3 {
+			term.digits = d
+		}
+		if term.folded == "" && term.digits == "" {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+func (doc indexedDoc) match(terms []queryTerm) (Result, bool) {
+	res := Result{
+		Type:       doc.Type,
+		ID:         doc.ID,
+		Title:      doc.Title,
+		Highlights: map[string]string{},
+		Data:       doc.Data,
+	}
+	marked := make(map[int]map[int]bool)
+
+	for _, term := range terms {
+		best := 0.0
+		for fi, field := range doc.fields {
+			if term.digits != "" && field.Phone && strings.Contains(field.digits, term.digits) {
+				score := 2.0
+				if field.digits == term.digits || strings.HasSuffix(field.digits, term.digits) {
+					score = 3.0
+				}
+				best = maxScore(best, score*field.Weight)
+				markAll(marked, fi, len(field.tokens))
+				continue
+			}
+			for ti, tok := range field.tokens {
+				score := tokenScore(tok.folded, term.folded)
+				if score == 0 {
+					continue
+				}
+				best = maxScore(best, score*field.Weight)
+				if marked[fi] == nil {
+					marked[fi] = map[int]bool{}
+				}
+				marked[fi][ti] = true
+			}
+		}
+		if best == 0 {
+			return Result{}, false
+		}
+		res.Score += best
+	}
+
+	for fi, toks := range marked {
+		field := doc.fields[fi]
+		res.Highlights[field.Name] = highlight(field.Value, field.tokens, toks)
+	}
+	return res, true
+}
+
+func tokenScore(word, term string) float64 {
+	switch {
+	case term == "":
+		return 0
+	case word == term:
+		return 3
+	case strings.HasPrefix(word, term):
+		return 2
+	case len(term) >= 3 && strings.Contains(word, term):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func maxScore(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func markAll(marked map[int]map[int]bool, fi, n int) {
+	if marked[fi] == nil {
+		marked[fi] = map[int]bool{}
+	}
+	for i := 0; i < n; i++ {
+		marked[fi][i] = true
+	}
+}
+
+// tokenize разбивает строку на слова, сохраняя их позиции в исходной строке
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		text := s[start:end]
+		tokens = append(tokens, token{text: text, folded: Fold(text), start: start, end: end})
+		start = -1
+	}
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(s))
+	return tokens
+}
+
+// highlight оборачивает совпавшие слова в теги <em>, экранируя остальной текст
+func highlight(value string, tokens []token, marked map[int]bool) string {
+	var b strings.Builder
+	pos := 0
+	for i, tok := range tokens {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(html.EscapeString(value[pos:tok.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(tok.text))
+		b.WriteString("</em>")
+		pos = tok.end
+	}
+	b.WriteString(html.EscapeString(value[pos:]))
+	return b.String()
+}
@@ -0,0 +1,251 @@
+package search
+
+import (
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Пауза перед повторной подпиской на поток изменений
+	watchRetryDelay = time.Minute
+	// Полная перезагрузка подхватывает изменения, пропущенные без подписки
+	reloadInterval = 10 * time.Minute
+)
+
+// Service держит индекс пользователей, комнат и расписаний в памяти и
+// обновляет его по потоку изменений, поэтому запрос поиска не читает
+// коллекции. Индекс загружается при первом запросе или в Run
+type Service struct {
+	store   *repository.Store
+	timeout time.Duration
+	index   *Index
+
+	loadMu sync.Mutex
+	loaded bool
+}
+
+// NewService создаёт пустой индекс. timeout ограничивает загрузку одного
+// документа или коллекции
+func NewService(store *repository.Store, timeout time.Duration) *Service {
+	return &Service{store: store, timeout: timeout, index: NewIndex()}
+}
+
+// Search ищет по индексу документы типов types (nil — любых)
+func (s *Service) Search(ctx context.Context, query string, limit int, types map[string]bool) ([]Result, error) {
+	if err := s.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	return s.index.Search(query, limit, types), nil
+}
+
+func (s *Service) ensureLoaded(ctx context.Context) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if s.loaded {
+		return nil
+	}
+	if err := s.reloadLocked(ctx); err != nil {
+		return err
+	}
+	s.loaded = true
+	return nil
+}
+
+// Refresh перечитывает все коллекции
+func (s *Service) Refresh(ctx context.Context) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if err := s.reloadLocked(ctx); err != nil {
+		return err
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *Service) reloadLocked(ctx context.Context) error {
+	for _, coll := range []string{repository.UsersCollection, repository.RoomsCollection, repository.SchedulesCollection} {
+		if err := s.reloadCollection(ctx, coll); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run загружает индекс и поддерживает его актуальным до отмены ctx: по
+// потоку изменений и полной перезагрузкой раз в reloadInterval
+func (s *Service) Run(ctx context.Context) {
+	go s.watch(ctx)
+
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка загрузки поискового индекса: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) watch(ctx context.Context) {
+	for {
+		err := s.store.Watch(ctx, nil, func(change repository.Change) {
+			s.loadMu.Lock()
+			defer s.loadMu.Unlock()
+			if err := s.apply(ctx, change); err != nil && ctx.Err() == nil {
+				log.Printf("Ошибка обновления поискового индекса (%s %s): %v", change.Collection, change.ID.Hex(), err)
+			}
+		}, repository.UsersCollection, repository.RoomsCollection, repository.SchedulesCollection)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Поток изменений недоступен, поисковый индекс обновляется раз в %s: %v", reloadInterval, err)
+
+		select {
+		case <-time.After(watchRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply обновляет документ из change или перечитывает коллекцию целиком,
+// если хранилище не сообщило ID. Вызывается под loadMu
+func (s *Service) apply(ctx context.Context, change repository.Change) error {
+	typ, ok := collectionTypes[change.Collection]
+	if !ok {
+		return nil
+	}
+	if change.ID.IsZero() {
+		return s.reloadCollection(ctx, change.Collection)
+	}
+	if change.Deleted {
+		s.index.Remove(typ, change.ID.Hex())
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	doc, err := s.find(ctx, change.Collection, change.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.index.Remove(typ, change.ID.Hex())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.index.Add(doc)
+	return nil
+}
+
+var collectionTypes = map[string]string{
+	repository.UsersCollection:     TypeUser,
+	repository.RoomsCollection:     TypeRoom,
+	repository.SchedulesCollection: TypeSchedule,
+}
+
+func (s *Service) find(ctx context.Context, coll string, id primitive.ObjectID) (Document, error) {
+	switch coll {
+	case repository.UsersCollection:
+		user, err := s.store.Users.FindByID(ctx, id)
+		return userDocument(user), err
+	case repository.RoomsCollection:
+		room, err := s.store.Rooms.FindByID(ctx, id)
+		return roomDocument(room), err
+	default:
+		schedule, err := s.store.Schedules.FindByID(ctx, id)
+		return scheduleDocument(schedule), err
+	}
+}
+
+func (s *Service) reloadCollection(ctx context.Context, coll string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var docs []Document
+	switch coll {
+	case repository.UsersCollection:
+		err := s.store.Users.Each(ctx, repository.UserFilter{All: true}, func(u models.User) error {
+			docs = append(docs, userDocument(u))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("пользователи: %w", err)
+		}
+	case repository.RoomsCollection:
+		rooms, err := s.store.Rooms.List(ctx)
+		if err != nil {
+			return fmt.Errorf("комнаты: %w", err)
+		}
+		for _, room := range rooms {
+			docs = append(docs, roomDocument(room))
+		}
+	case repository.SchedulesCollection:
+		schedules, err := s.store.Schedules.List(ctx)
+		if err != nil {
+			return fmt.Errorf("расписания: %w", err)
+		}
+		for _, schedule := range schedules {
+			docs = append(docs, scheduleDocument(schedule))
+		}
+	}
+	s.index.Replace(collectionTypes[coll], docs)
+	return nil
+}
+
+func userDocument(user models.User) Document {
+	return Document{
+		Type:  TypeUser,
+		ID:    user.ID.Hex(),
+		Title: strings.TrimSpace(user.FirstName + " " + user.SecondName),
+		Fields: []Field{
+			{Name: "first_name", Value: user.FirstName, Weight: 3},
+			{Name: "second_name", Value: user.SecondName, Weight: 3},
+			{Name: "email", Value: user.Email, Weight: 2},
+			{Name: "phone", Value: user.Phone, Weight: 2, Phone: true},
+		},
+		Data: models.NewUserResponse(user),
+	}
+}
+
+func roomDocument(room models.Room) Document {
+	return Document{
+		Type:  TypeRoom,
+		ID:    room.ID.Hex(),
+		Title: "Комната " + room.RoomNumber,
+		Fields: []Field{
+			{Name: "room_number", Value: room.RoomNumber, Weight: 3},
+		},
+		Data: room,
+	}
+}
+
+func scheduleDocument(schedule models.Schedule) Document {
+	return Document{
+		Type: TypeSchedule,
+		ID:   schedule.ID.Hex(),
+		Title: fmt.Sprintf("%s — %s %s (%s %s-%s, комната %s)",
+			schedule.Subject, schedule.FirstName, schedule.SecondName,
+			schedule.Day, schedule.StartTime, schedule.EndTime, schedule.RoomNumber),
+		Fields: []Field{
+			{Name: "subject", Value: schedule.Subject, Weight: 2},
+			{Name: "first_name", Value: schedule.FirstName, Weight: 1},
+			{Name: "second_name", Value: schedule.SecondName, Weight: 1},
+			{Name: "room_number", Value: schedule.RoomNumber, Weight: 1},
+		},
+		Data: schedule,
+	}
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"access-control-system/models"
+	"access-control-system/repository"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIndexReplaceAndRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(Document{Type: TypeRoom, ID: "1", Fields: []Field{{Name: "room_number", Value: "101"}}})
+	ix.Add(Document{Type: TypeRoom, ID: "1", Fields: []Field{{Name: "room_number", Value: "202"}}})
+	ix.Add(Document{Type: TypeUser, ID: "2", Fields: []Field{{Name: "first_name", Value: "Иван"}}})
+
+	if got := len(ix.Search("101", 0, nil)); got != 0 {
+		t.Fatalf("заменённый документ найден: %d", got)
+	}
+	if got := len(ix.Search("202", 0, nil)); got != 1 {
+		t.Fatalf("Search(202) = %d документов, ожидался 1", got)
+	}
+	if got := len(ix.Search("ivan", 0, map[string]bool{TypeRoom: true})); got != 0 {
+		t.Fatalf("фильтр по типу не применён: %d", got)
+	}
+
+	ix.Replace(TypeRoom, []Document{{Type: TypeRoom, ID: "3", Fields: []Field{{Name: "room_number", Value: "303"}}}})
+	if ix.Len() != 2 || len(ix.Search("202", 0, nil)) != 0 {
+		t.Fatalf("Replace не заменил комнаты: %d документов", ix.Len())
+	}
+	ix.Remove(TypeUser, "2")
+	if got := len(ix.Search("ivan", 0, nil)); got != 0 {
+		t.Fatalf("удалённый документ найден: %d", got)
+	}
+}
+
+func TestServiceFollowsChanges(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := models.Room{ID: primitive.NewObjectID(), RoomNumber: "101"}
+	if err := store.Rooms.CreateMany(ctx, []models.Room{room}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(store, time.Second)
+	go svc.Run(ctx)
+	waitFor(t, func() bool { return found(t, svc, "101") == 1 })
+
+	if err := store.Rooms.CreateMany(ctx, []models.Room{{ID: primitive.NewObjectID(), RoomNumber: "102"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return found(t, svc, "102") == 1 })
+
+	if err := store.Rooms.Delete(ctx, room.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return found(t, svc, "101") == 0 })
+}
+
+func found(t *testing.T, svc *Service, query string) int {
+	t.Helper()
+	results, err := svc.Search(context.Background(), query, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(results)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("индекс не обновился")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
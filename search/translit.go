@@ -0,0 +1,70 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Таблица транслитерации кириллицы (русский и казахский алфавиты) в латиницу
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ә': "a", 'ғ': "g", 'қ': "k", 'ң': "n", 'ө': "o", 'ұ': "u", 'ү': "u",
+	'һ': "h", 'і': "i",
+}
+
+// Распространённые варианты латинского написания имён сводятся к одной форме,
+// чтобы "Aleksey", "Alexey" и "Алексей" совпадали между собой
+var latinFolds = strings.NewReplacer(
+	"shch", "sh",
+	"kh", "h",
+	"x", "ks",
+	"yu", "iu",
+	"ju", "iu",
+	"ya", "ia",
+	"ja", "ia",
+	"ye", "e",
+	"j", "i",
+	"y", "i",
+	"w", "v",
+	"q", "k",
+	"c", "k",
+)
+
+// Transliterate переводит строку в нижний регистр и заменяет кириллицу латиницей
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Fold приводит слово к форме для сравнения: транслитерация, удаление
+// небуквенных символов и сведение вариантов написания
+func Fold(s string) string {
+	var b strings.Builder
+	for _, r := range Transliterate(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return latinFolds.Replace(b.String())
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSStore хранит объекты в GridFS. При повторном сохранении
+// под тем же именем предыдущие версии удаляются
+type GridFSStore struct {
+	bucket *gridfs.Bucket
+}
+
+type gridFSFile struct {
+	ID       interface{} `bson:"_id"`
+	Length   int64       `bson:"length"`
+	Metadata struct {
+		ContentType string `bson:"content_type"`
+	} `bson:"metadata"`
+}
+
+func NewGridFSStore(db *mongo.Database, bucketName string) (*GridFSStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFSStore{bucket: bucket}, nil
+}
+
+func (s *GridFSStore) files(ctx context.Context, name string) ([]gridFSFile, error) {
+	opts := options.GridFSFind().SetSort(bson.M{"uploadDate": 1})
+	cursor, err := s.bucket.FindContext(ctx, bson.M{"filename": name}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var files []gridFSFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s *GridFSStore) Save(ctx context.Context, name, contentType string, data io.Reader) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	previous, err := s.files(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	if _, err := s.bucket.UploadFromStream(name, data, opts); err != nil {
+		return err
+	}
+
+	for _, f := range previous {
+		if err := s.bucket.DeleteContext(ctx, f.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GridFSStore) Open(ctx context.Context, name string) (io.ReadCloser, ObjectInfo, error) {
+	if !validName(name) {
+		return nil, ObjectInfo{}, errInvalidName
+	}
+	files, err := s.files(ctx, name)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if len(files) == 0 {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+
+	latest := files[len(files)-1]
+	stream, err := s.bucket.OpenDownloadStream(latest.ID)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	info := ObjectInfo{Name: name, Size: latest.Length, ContentType: latest.Metadata.ContentType}
+	if info.ContentType == "" {
+		info.ContentType = "application/octet-stream"
+	}
+	return stream, info, nil
+}
+
+func (s *GridFSStore) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	files, err := s.files(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return ErrNotFound
+	}
+	for _, f := range files {
+		if err := s.bucket.DeleteContext(ctx, f.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return err
+		}
+	}
+	return nil
+}
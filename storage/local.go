@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore хранит объекты в каталоге локальной файловой системы.
+// Тип содержимого сохраняется рядом с объектом в файле <name>.meta
+type LocalStore struct {
+	Dir string
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+func (s *LocalStore) path(name string) (string, error) {
+	if !validName(name) {
+		return "", errInvalidName
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(name)), nil
+}
+
+func (s *LocalStore) Save(ctx context.Context, name, contentType string, data io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели
+	// никогда не видели частично записанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".meta", meta, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Open(ctx context.Context, name string) (io.ReadCloser, ObjectInfo, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	info := ObjectInfo{Name: name, Size: stat.Size(), ContentType: "application/octet-stream"}
+	if raw, err := os.ReadFile(path + ".meta"); err == nil {
+		var meta localMeta
+		if json.Unmarshal(raw, &meta) == nil && meta.ContentType != "" {
+			info.ContentType = meta.ContentType
+		}
+	}
+	return f, info, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	os.Remove(path + ".meta")
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotFound    = errors.New("объект не найден")
+	errInvalidName = errors.New("недопустимое имя объекта")
+)
+
+type ObjectInfo struct {
+	Name        string
+	ContentType string
+	Size        int64
+}
+
+// Store — хранилище двоичных объектов (фотографий пользователей).
+// Имена объектов — пути через "/", без ".." и начального "/"
+type Store interface {
+	Save(ctx context.Context, name, contentType string, data io.Reader) error
+	Open(ctx context.Context, name string) (io.ReadCloser, ObjectInfo, error)
+	Delete(ctx context.Context, name string) error
+}
+
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
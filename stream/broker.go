@@ -0,0 +1,222 @@
+// Package stream рассылает события журнала и решения о доступе подписчикам
+// в реальном времени. Публикация никогда не блокируется: если подписчик
+// не успевает читать, события для него отбрасываются и подсчитываются
+package stream
+
+import (
+	"access-control-system/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Виды событий
+const (
+	KindAccess = "access"
+	KindLog    = "log"
+	KindAlert  = "alert"
+)
+
+const (
+	defaultBuffer  = 256
+	defaultHistory = 512
+)
+
+var (
+	subscribers = metrics.Default.NewGauge("stream_subscribers",
+		"Подключённые подписчики потока событий")
+	published = metrics.Default.NewCounter("stream_events_published_total",
+		"События, опубликованные в поток, по виду", "kind")
+	dropped = metrics.Default.NewCounter("stream_events_dropped_total",
+		"События, не доставленные медленным подписчикам")
+)
+
+// Event — событие потока. ID возрастает в пределах работы процесса
+type Event struct {
+	ID       uint64 `json:"id"`
+	Kind     string `json:"kind"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+	UserID   string `json:"user_id,omitempty"`
+	Room     string `json:"room,omitempty"`
+	Building string `json:"building,omitempty"`
+	Floor    *int   `json:"floor,omitempty"`
+	// Timezone — часовой пояс комнаты, для оценки местного времени
+	Timezone string `json:"timezone,omitempty"`
+	Granted  *bool  `json:"granted,omitempty"`
+	// AlertID, Severity и Rule заполняются для оповещений
+	AlertID   string    `json:"alert_id,omitempty"`
+	Severity  string    `json:"severity,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Filter отбирает события. Пустой список не ограничивает выборку.
+// Types сравнивается и с типом, и с видом события: type=access — все
+// решения о доступе
+type Filter struct {
+	Types     []string
+	Rooms     []string
+	Buildings []string
+}
+
+func (f Filter) Match(e Event) bool {
+	return matchAny(f.Types, e.Type, e.Kind) &&
+		matchAny(f.Rooms, e.Room) &&
+		matchAny(f.Buildings, e.Building)
+}
+
+func matchAny(allowed []string, values ...string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		for _, v := range values {
+			if v != "" && strings.EqualFold(a, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Broker рассылает события подписчикам и хранит последние события для
+// переподключившихся клиентов. Publish и Close безопасны для nil-брокера
+type Broker struct {
+	buffer int
+	seq    atomic.Uint64
+
+	mu      sync.Mutex
+	closed  bool
+	subs    map[*Subscription]struct{}
+	history []Event
+	next    int
+}
+
+// NewBroker создаёт брокер. buffer — очередь одного подписчика,
+// history — сколько последних событий можно получить повторно
+func NewBroker(buffer, history int) *Broker {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	if history <= 0 {
+		history = defaultHistory
+	}
+	return &Broker{
+		buffer:  buffer,
+		subs:    map[*Subscription]struct{}{},
+		history: make([]Event, 0, history),
+	}
+}
+
+// Publish присваивает событию номер и рассылает его, не дожидаясь подписчиков
+func (b *Broker) Publish(e Event) Event {
+	if b == nil {
+		return e
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	b.mu.Lock()
+	e.ID = b.seq.Add(1)
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.next] = e
+		b.next = (b.next + 1) % len(b.history)
+	}
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+			dropped.With().Inc()
+		}
+	}
+	b.mu.Unlock()
+
+	published.With(e.Kind).Inc()
+	return e
+}
+
+// Subscribe подписывает на события, подходящие под filter. Если after > 0,
+// возвращает и сохранённые события с номером больше after. После Close
+// брокера подписка сразу закрыта
+func (b *Broker) Subscribe(filter Filter, after uint64) (*Subscription, []Event) {
+	s := &Subscription{b: b, filter: filter, ch: make(chan Event, b.buffer)}
+	s.C = s.ch
+	subscribers.With().Inc()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.once.Do(func() {
+			close(s.ch)
+			subscribers.With().Dec()
+		})
+		return s, nil
+	}
+
+	var backlog []Event
+	// Номер из будущего остался от предыдущего запуска сервиса
+	if after > 0 && after <= b.seq.Load() {
+		for i := range b.history {
+			e := b.history[(b.next+i)%len(b.history)]
+			if e.ID > after && filter.Match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	b.subs[s] = struct{}{}
+	return s, backlog
+}
+
+// Close закрывает все подписки, чтобы открытые потоки завершились
+// при остановке сервера
+func (b *Broker) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// Subscription — подписка на события. C закрывается после Close
+type Subscription struct {
+	C <-chan Event
+
+	b       *Broker
+	filter  Filter
+	ch      chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// TakeDropped возвращает число отброшенных с прошлого вызова событий
+func (s *Subscription) TakeDropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.b.mu.Lock()
+		delete(s.b.subs, s)
+		s.b.mu.Unlock()
+		close(s.ch)
+		subscribers.With().Dec()
+	})
+}
@@ -0,0 +1,422 @@
+// Package webhook доставляет события внешним системам. Доставки хранятся
+// в MongoDB и повторяются с экспоненциальной задержкой; после исчерпания
+// попыток доставка помечается dead и может быть повторена вручную
+package webhook
+
+import (
+	"access-control-system/buildinfo"
+	"access-control-system/clock"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/stream"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// TestEventType — тип события, отправляемого при проверке подписки
+	TestEventType = "webhook_test"
+
+	queueSize = 1024
+	// Одновременные запросы к подписчикам
+	concurrency = 4
+	// Ответ подписчика читается не больше этого объёма
+	maxResponseBody = 64 << 10
+	// Максимальная длина текста ошибки в истории доставки
+	maxErrorLength = 512
+)
+
+var (
+	deliveryResults = metrics.Default.NewCounter("webhook_deliveries_total",
+		"Попытки доставки webhook по результату", "result")
+	deliveryDuration = metrics.Default.NewHistogram("webhook_delivery_duration_seconds",
+		"Длительность запроса к подписчику webhook",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+	overflowEvents = metrics.Default.NewCounter("webhook_events_overflow_total",
+		"События, сохранённые в очередь доставки вызывающим из-за переполнения буфера webhook")
+)
+
+// Config — параметры доставки
+type Config struct {
+	// Timeout ограничивает один запрос к подписчику
+	Timeout time.Duration
+	// MaxAttempts — попыток до перевода доставки в dead
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// PollInterval — период проверки очереди. Новые доставки отправляются сразу
+	PollInterval time.Duration
+}
+
+// Payload — тело запроса к подписчику. DeliveryID одинаков во всех
+// попытках и позволяет получателю отбросить повторы
+type Payload struct {
+	DeliveryID string       `json:"delivery_id"`
+	WebhookID  string       `json:"webhook_id"`
+	Test       bool         `json:"test,omitempty"`
+	Event      stream.Event `json:"event"`
+}
+
+// Заголовки запроса к подписчику
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Sign возвращает подпись запроса: "sha256=" и HMAC-SHA256 от
+// "<timestamp>.<тело>" с ключом secret в hex. Получатель вычисляет её
+// так же и сравнивает с заголовком X-Webhook-Signature, а по
+// X-Webhook-Timestamp отбрасывает старые запросы
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher ставит события в очередь доставки и отправляет их
+type Dispatcher struct {
+	store  *repository.Store
+	clock  clock.Clock
+	cfg    Config
+	client *http.Client
+
+	events chan stream.Event
+	wake   chan struct{}
+}
+
+func NewDispatcher(store *repository.Store, clk clock.Clock, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		store: store,
+		clock: clk,
+		cfg:   cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// Перенаправление считается ошибкой: подписка должна указывать
+			// окончательный адрес
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		events: make(chan stream.Event, queueSize),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Notify передаёт событие на доставку подписчикам его типа. Обычно событие
+// только ставится в буфер; если он переполнен, доставки сохраняются в
+// хранилище сразу, в потоке вызывающего, чтобы всплеск отказов не терял
+// события. Безопасен для nil-диспетчера
+func (d *Dispatcher) Notify(e stream.Event) {
+	if d == nil {
+		return
+	}
+	select {
+	case d.events <- e:
+	default:
+		overflowEvents.With().Inc()
+		ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+		defer cancel()
+		d.enqueue(ctx, e)
+	}
+}
+
+// enqueue создаёт доставки события для всех подходящих подписок
+func (d *Dispatcher) enqueue(ctx context.Context, e stream.Event) {
+	webhooks, err := d.store.Webhooks.FindByEventType(ctx, e.Type)
+	if err != nil {
+		log.Printf("Ошибка поиска подписок webhook на %s: %v", e.Type, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	now := d.clock.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		delivery, err := newDelivery(w, e, now, false)
+		if err != nil {
+			log.Printf("Ошибка подготовки webhook %s: %v", w.ID.Hex(), err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := d.store.Deliveries.InsertMany(ctx, deliveries); err != nil {
+		log.Printf("Ошибка постановки webhook в очередь (%s): %v", e.Type, err)
+		return
+	}
+	d.Wake()
+}
+
+func newDelivery(w models.Webhook, e stream.Event, now time.Time, test bool) (models.WebhookDelivery, error) {
+	id := primitive.NewObjectID()
+	body, err := json.Marshal(Payload{DeliveryID: id.Hex(), WebhookID: w.ID.Hex(), Test: test, Event: e})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return models.WebhookDelivery{
+		ID:            id,
+		WebhookID:     w.ID,
+		EventType:     e.Type,
+		Payload:       string(body),
+		Status:        models.DeliveryPending,
+		Test:          test,
+		Attempts:      []models.WebhookAttempt{},
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// Wake запускает отправку, не дожидаясь периодической проверки очереди
+func (d *Dispatcher) Wake() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run ставит события в очередь и отправляет доставки до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.runEnqueue(ctx)
+	}()
+	defer wg.Wait()
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) runEnqueue(ctx context.Context) {
+	for {
+		select {
+		case e := <-d.events:
+			enqueueCtx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+			d.enqueue(enqueueCtx, e)
+			cancel()
+		case <-ctx.Done():
+			d.drain()
+			return
+		}
+	}
+}
+
+// drain сохраняет в очередь события, полученные до остановки
+func (d *Dispatcher) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+	for {
+		select {
+		case e := <-d.events:
+			d.enqueue(ctx, e)
+		default:
+			return
+		}
+	}
+}
+
+// deliverDue отправляет все доставки, время которых наступило
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for ctx.Err() == nil {
+		sem <- struct{}{}
+		now := d.clock.Now()
+		// Пока идёт попытка, доставка не достанется другому экземпляру
+		delivery, err := d.store.Deliveries.ClaimDue(ctx, now, now.Add(2*d.cfg.Timeout))
+		if err != nil {
+			<-sem
+			if !errors.Is(err, repository.ErrNotFound) && ctx.Err() == nil {
+				log.Printf("Ошибка чтения очереди webhook: %v", err)
+			}
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.attempt(delivery)
+		}()
+	}
+}
+
+// attempt выполняет одну попытку и планирует следующую
+func (d *Dispatcher) attempt(delivery models.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*d.cfg.Timeout)
+	defer cancel()
+
+	var result models.WebhookAttempt
+	w, err := d.store.Webhooks.FindByID(ctx, delivery.WebhookID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		result = models.WebhookAttempt{At: d.clock.Now(), Error: "подписка удалена"}
+	case err != nil:
+		log.Printf("Ошибка чтения подписки webhook %s: %v", delivery.WebhookID.Hex(), err)
+		return
+	case !w.Active:
+		result = models.WebhookAttempt{At: d.clock.Now(), Error: "подписка отключена"}
+	default:
+		result = d.send(ctx, w, delivery)
+	}
+
+	status, next := d.schedule(delivery, result, err == nil && w.Active)
+	deliveryResults.With(resultLabel(status)).Inc()
+	if status == models.DeliveryDead {
+		log.Printf("Webhook %s: доставка %s (%s) не выполнена за %d попыток: %s",
+			delivery.WebhookID.Hex(), delivery.ID.Hex(), delivery.EventType, delivery.AttemptsSinceRequeue()+1, result.Error)
+	}
+	if err := d.store.Deliveries.RecordAttempt(ctx, delivery.ID, result, status, next); err != nil {
+		log.Printf("Ошибка записи результата webhook %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// schedule возвращает статус доставки после попытки и время следующей
+func (d *Dispatcher) schedule(delivery models.WebhookDelivery, result models.WebhookAttempt, retryable bool) (models.DeliveryStatus, time.Time) {
+	if result.Error == "" {
+		return models.DeliveryDelivered, result.At
+	}
+	attempts := delivery.AttemptsSinceRequeue() + 1
+	if !retryable || attempts >= d.cfg.MaxAttempts {
+		return models.DeliveryDead, result.At
+	}
+	return models.DeliveryPending, result.At.Add(d.backoff(attempts))
+}
+
+// backoff — задержка после attempts неудачных попыток: удваивается до
+// RetryMaxBackoff, со случайной добавкой до 20%, чтобы повторы разных
+// доставок не приходили одновременно
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.RetryBackoff
+	for i := 1; i < attempts && wait < d.cfg.RetryMaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, d.cfg.RetryMaxBackoff)
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+func resultLabel(status models.DeliveryStatus) string {
+	switch status {
+	case models.DeliveryDelivered:
+		return "delivered"
+	case models.DeliveryDead:
+		return "dead"
+	default:
+		return "retry"
+	}
+}
+
+// send отправляет тело доставки подписчику. Успех — ответ 2xx
+func (d *Dispatcher) send(ctx context.Context, w models.Webhook, delivery models.WebhookDelivery) models.WebhookAttempt {
+	start := d.clock.Now()
+	result := models.WebhookAttempt{At: start}
+	started := time.Now()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = truncate(err.Error())
+		return result
+	}
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "access-control-system/"+buildinfo.Get().Version)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, timestamp, body))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+
+	resp, err := d.client.Do(req)
+	elapsed := time.Since(started)
+	result.DurationMS = elapsed.Milliseconds()
+	deliveryDuration.With().Observe(elapsed.Seconds())
+	if err != nil {
+		result.Error = truncate(err.Error())
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		result.Error = truncate(fmt.Sprintf("ответ %d: %s", resp.StatusCode, bytes.TrimSpace(snippet)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	return result
+}
+
+func truncate(s string) string {
+	if len(s) <= maxErrorLength {
+		return s
+	}
+	n := maxErrorLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Test отправляет подписке тестовое событие сразу, без повторов, и
+// сохраняет результат в истории доставок
+func (d *Dispatcher) Test(ctx context.Context, w models.Webhook) (models.WebhookDelivery, error) {
+	now := d.clock.Now()
+	delivery, err := newDelivery(w, stream.Event{
+		Kind:      stream.KindLog,
+		Type:      TestEventType,
+		Message:   "Проверка подписки " + w.Name,
+		Timestamp: now,
+	}, now, true)
+	if err != nil {
+		return delivery, err
+	}
+
+	result := d.send(ctx, w, delivery)
+	delivery.Attempts = append(delivery.Attempts, result)
+	delivery.Status = models.DeliveryDelivered
+	if result.Error != "" {
+		delivery.Status = models.DeliveryDead
+	} else {
+		delivery.DeliveredAt = &result.At
+	}
+	delivery.NextAttemptAt = result.At
+	deliveryResults.With(resultLabel(delivery.Status)).Inc()
+
+	return delivery, d.store.Deliveries.InsertMany(ctx, []models.WebhookDelivery{delivery})
+}
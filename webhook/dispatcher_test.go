@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"access-control-system/clock"
+	"access-control-system/metrics"
+	"access-control-system/models"
+	"access-control-system/repository"
+	"access-control-system/stream"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testSecret = "webhook-secret"
+
+// receiver — подписчик, отвечающий кодами из statuses по очереди (после
+// последнего — последним) и проверяющий подпись запросов
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	payloads []Payload
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rv.t.Error(err)
+		return
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		rv.t.Errorf("заголовок %s: %v", HeaderTimestamp, err)
+	}
+	if got, want := r.Header.Get(HeaderSignature), Sign(testSecret, timestamp, body); got != want {
+		rv.t.Errorf("подпись %q, ожидалась %q", got, want)
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		rv.t.Errorf("тело не JSON: %v", err)
+	}
+	if r.Header.Get(HeaderDelivery) != p.DeliveryID || r.Header.Get(HeaderEvent) != p.Event.Type {
+		rv.t.Errorf("заголовки доставки не совпадают с телом: %v", r.Header)
+	}
+
+	rv.mu.Lock()
+	rv.payloads = append(rv.payloads, p)
+	status := rv.statuses[min(len(rv.payloads), len(rv.statuses))-1]
+	rv.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (rv *receiver) calls() int {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return len(rv.payloads)
+}
+
+type testDispatcher struct {
+	*Dispatcher
+	store   *repository.Store
+	clock   *clock.Manual
+	webhook models.Webhook
+}
+
+func newTestDispatcher(t *testing.T, rv *receiver) *testDispatcher {
+	t.Helper()
+	srv := httptest.NewServer(rv)
+	t.Cleanup(srv.Close)
+
+	store := repository.NewMemoryStore()
+	clk := clock.NewManual(time.Date(2026, 9, 14, 10, 0, 0, 0, time.UTC))
+	w := models.Webhook{
+		ID: primitive.NewObjectID(), Name: "crm", URL: srv.URL, EventTypes: []string{"access_granted"},
+		Secret: testSecret, Active: true, CreatedAt: clk.Now(),
+	}
+	if err := store.Webhooks.Create(context.Background(), &w); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(store, clk, Config{
+		Timeout: time.Second, MaxAttempts: 3, RetryBackoff: time.Minute, RetryMaxBackoff: 10 * time.Minute, PollInterval: time.Minute,
+	})
+	return &testDispatcher{Dispatcher: d, store: store, clock: clk, webhook: w}
+}
+
+// enqueueEvent ставит событие в очередь и возвращает созданную доставку
+func (td *testDispatcher) enqueueEvent(t *testing.T) models.WebhookDelivery {
+	t.Helper()
+	ctx := context.Background()
+	td.enqueue(ctx, stream.Event{Kind: stream.KindAccess, Type: "access_granted", Timestamp: td.clock.Now()})
+	deliveries, err := td.store.Deliveries.ListByWebhook(ctx, td.webhook.ID, "", 0)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("доставок %d, ошибка %v", len(deliveries), err)
+	}
+	return deliveries[0]
+}
+
+func (td *testDispatcher) delivery(t *testing.T, id primitive.ObjectID) models.WebhookDelivery {
+	t.Helper()
+	delivery, err := td.store.Deliveries.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	rv := &receiver{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	td := newTestDispatcher(t, rv)
+	ctx := context.Background()
+	queued := td.enqueueEvent(t)
+
+	td.deliverDue(ctx)
+	delivery := td.delivery(t, queued.ID)
+	if rv.calls() != 1 || delivery.Status != models.DeliveryPending {
+		t.Fatalf("после ответа 503: %d запросов, статус %s", rv.calls(), delivery.Status)
+	}
+	if got := delivery.Attempts[0].StatusCode; got != http.StatusServiceUnavailable {
+		t.Fatalf("код попытки %d", got)
+	}
+	// Первый повтор — через RetryBackoff плюс до 20%
+	wait := delivery.NextAttemptAt.Sub(td.clock.Now())
+	if wait < time.Minute || wait > time.Minute+12*time.Second {
+		t.Fatalf("повтор через %s", wait)
+	}
+
+	td.deliverDue(ctx)
+	if rv.calls() != 1 {
+		t.Fatal("повтор отправлен до истечения задержки")
+	}
+
+	td.clock.Advance(wait)
+	td.deliverDue(ctx)
+	delivery = td.delivery(t, queued.ID)
+	if rv.calls() != 2 || delivery.Status != models.DeliveryDelivered {
+		t.Fatalf("после повтора: %d запросов, статус %s", rv.calls(), delivery.Status)
+	}
+	if rv.payloads[0].DeliveryID != rv.payloads[1].DeliveryID || rv.payloads[0].DeliveryID != queued.ID.Hex() {
+		t.Fatal("delivery_id меняется между попытками")
+	}
+}
+
+func TestDeliveryDeadAfterMaxAttempts(t *testing.T) {
+	rv := &receiver{t: t, statuses: []int{http.StatusInternalServerError}}
+	td := newTestDispatcher(t, rv)
+	ctx := context.Background()
+	queued := td.enqueueEvent(t)
+
+	var waits []time.Duration
+	for i := 0; i < td.cfg.MaxAttempts; i++ {
+		td.deliverDue(ctx)
+		delivery := td.delivery(t, queued.ID)
+		waits = append(waits, delivery.NextAttemptAt.Sub(td.clock.Now()))
+		td.clock.Set(delivery.NextAttemptAt)
+	}
+
+	delivery := td.delivery(t, queued.ID)
+	if delivery.Status != models.DeliveryDead || len(delivery.Attempts) != td.cfg.MaxAttempts {
+		t.Fatalf("статус %s после %d попыток", delivery.Status, len(delivery.Attempts))
+	}
+	// Задержка удваивается: вторая не меньше 2×RetryBackoff
+	if waits[1] < 2*time.Minute || waits[1] > 2*time.Minute+24*time.Second {
+		t.Fatalf("задержки %v", waits)
+	}
+
+	td.clock.Advance(time.Hour)
+	td.deliverDue(ctx)
+	if rv.calls() != td.cfg.MaxAttempts {
+		t.Fatalf("dead-доставка отправлена повторно: %d запросов", rv.calls())
+	}
+}
+
+func TestTestDelivery(t *testing.T) {
+	for _, tt := range []struct {
+		status int
+		want   models.DeliveryStatus
+	}{
+		{http.StatusNoContent, models.DeliveryDelivered},
+		{http.StatusBadGateway, models.DeliveryDead},
+	} {
+		rv := &receiver{t: t, statuses: []int{tt.status}}
+		td := newTestDispatcher(t, rv)
+
+		delivery, err := td.Test(context.Background(), td.webhook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivery.Status != tt.want || !delivery.Test || len(delivery.Attempts) != 1 {
+			t.Fatalf("ответ %d: статус %s, попыток %d", tt.status, delivery.Status, len(delivery.Attempts))
+		}
+		if rv.calls() != 1 || !rv.payloads[0].Test || rv.payloads[0].Event.Type != TestEventType {
+			t.Fatalf("тестовое событие не отправлено: %+v", rv.payloads)
+		}
+		// Тестовая доставка не повторяется
+		td.clock.Advance(time.Hour)
+		td.deliverDue(context.Background())
+		if rv.calls() != 1 {
+			t.Fatal("тестовая доставка повторена")
+		}
+	}
+}
+
+// События сверх буфера сразу сохраняются в очередь доставки
+func TestNotifyPersistsOverflow(t *testing.T) {
+	td := newTestDispatcher(t, &receiver{t: t, statuses: []int{http.StatusOK}})
+
+	for i := 0; i < queueSize+10; i++ {
+		td.Notify(stream.Event{Type: "access_granted"})
+	}
+	deliveries, err := td.store.Deliveries.ListByWebhook(context.Background(), td.webhook.ID, "", 0)
+	if err != nil || len(deliveries) != 10 {
+		t.Fatalf("доставок в хранилище %d, ошибка %v, ожидалось 10", len(deliveries), err)
+	}
+	if len(td.events) != queueSize {
+		t.Fatalf("в буфере %d событий, ожидалось %d", len(td.events), queueSize)
+	}
+
+	var text strings.Builder
+	if err := metrics.Default.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "webhook_events_overflow_total 10\n") {
+		t.Fatal("события сверх буфера не учтены в webhook_events_overflow_total")
+	}
+}